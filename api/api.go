@@ -0,0 +1,457 @@
+// Package api serves games over HTTP with JSON bodies, for web tools and
+// bots.
+//
+//	POST /games               create a game
+//	POST /games/load          load a saved game into a new session
+//	GET  /games/{id}          public state: phase, turn and whose turn it is
+//	POST /games/{id}/join     take a player's seat and get its token
+//	POST /games/{id}/actions  submit the current player's command
+//	GET  /games/{id}/log      what the player has been told so far
+//	GET  /games/{id}/reveal   the whole game in the JSON save format, once it is over
+//	POST /games/{id}/save     save the game under SaveDir
+//
+// Creating a game takes the board size, at most MaxSize, and the players'
+// names, which must be distinct and not blank; see createRequest for the rest.
+//
+// Joining takes {"player": "P1"} and answers {"player": "P1", "token": "..."}.
+// Every seat can be joined once. Actions and logs are for the player whose
+// token is sent as "Authorization: Bearer TOKEN"; without a valid one the
+// server answers 401 Unauthorized.
+//
+// An action must carry the turn number it was decided on. If another action
+// got in first the server answers 409 Conflict with the current turn, so two
+// clients cannot both act on the same turn. An action out of turn is 403
+// Forbidden.
+//
+// Errors are answered as {"error": "..."} with a matching status code.
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"maze-game/game"
+)
+
+// GenerationTimeout bounds how long creating a game may search for a maze.
+const GenerationTimeout = 10 * time.Second
+
+// MaxSize is the largest board a game can be created with, so that one
+// request cannot make the server build a huge maze.
+const MaxSize = 20
+
+// Server is an http.Handler for the API.
+type Server struct {
+	store Store
+	mux   *http.ServeMux
+}
+
+func NewServer(store Store) *Server {
+	s := &Server{store: store, mux: http.NewServeMux()}
+	s.mux.HandleFunc("POST /games", s.createGame)
+	s.mux.HandleFunc("POST /games/load", s.loadGame)
+	s.mux.HandleFunc("GET /games/{id}", s.getGame)
+	s.mux.HandleFunc("POST /games/{id}/join", s.joinGame)
+	s.mux.HandleFunc("POST /games/{id}/actions", s.postAction)
+	s.mux.HandleFunc("GET /games/{id}/log", s.getLog)
+	s.mux.HandleFunc("GET /games/{id}/reveal", s.getReveal)
+	s.mux.HandleFunc("POST /games/{id}/save", s.saveGame)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+type createRequest struct {
+	Size        int      `json:"size"`
+	Holes       int      `json:"holes"`
+	RiverLength int      `json:"river_length"`
+	RiverPush   int      `json:"river_push"`
+	Topology    string   `json:"topology"` // a maze.TopologyNames entry, square if empty
+	Players     []string `json:"players"`
+	Monsters    []string `json:"monsters"` // specs as read by game.ParseMonsterSpec
+}
+
+type playerJSON struct {
+	ID         string `json:"id"`
+	Eliminated bool   `json:"eliminated"`
+}
+
+type gameJSON struct {
+	ID        string       `json:"id"`
+	Phase     string       `json:"phase"`
+	Turn      int          `json:"turn"`
+	Current   string       `json:"current,omitempty"`
+	Players   []playerJSON `json:"players"`
+	Winner    string       `json:"winner,omitempty"`
+	EndReason string       `json:"end_reason,omitempty"`
+}
+
+type joinRequest struct {
+	Player string `json:"player"`
+}
+
+type joinResponse struct {
+	Player string `json:"player"`
+	Token  string `json:"token"`
+}
+
+type actionRequest struct {
+	Command string `json:"command"`
+	Turn    *int   `json:"turn"`
+}
+
+type actionResponse struct {
+	Message  string   `json:"message"`
+	Events   []string `json:"events"`
+	UsedTurn bool     `json:"used_turn"`
+	Game     gameJSON `json:"game"`
+}
+
+type logEntryJSON struct {
+	Turn    int    `json:"turn"`
+	Player  string `json:"player"`
+	Command string `json:"command"`
+	Message string `json:"message"`
+}
+
+type fileRequest struct {
+	Name string `json:"name"`
+}
+
+// httpError carries a status code up to writeError.
+type httpError struct {
+	status int
+	msg    string
+	turn   *int
+}
+
+func (e *httpError) Error() string { return e.msg }
+
+func errorf(status int, format string, args ...any) error {
+	return &httpError{status: status, msg: fmt.Sprintf(format, args...)}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	body := map[string]any{"error": err.Error()}
+	status := http.StatusInternalServerError
+	var he *httpError
+	switch {
+	case errors.As(err, &he):
+		status = he.status
+		if he.turn != nil {
+			body["turn"] = *he.turn
+		}
+	case errors.Is(err, ErrNotFound):
+		status = http.StatusNotFound
+	}
+	writeJSON(w, status, body)
+}
+
+func readJSON(r *http.Request, v any) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return errorf(http.StatusBadRequest, "bad request body: %v", err)
+	}
+	return nil
+}
+
+func stateJSON(id string, g *game.Game) gameJSON {
+	out := gameJSON{
+		ID:        id,
+		Phase:     g.Phase.String(),
+		Turn:      g.Turn,
+		Winner:    g.Winner,
+		EndReason: g.EndReason,
+	}
+	if !g.IsOver() {
+		out.Current = g.CurrentPlayer().ID
+	}
+	for _, p := range g.Players {
+		out.Players = append(out.Players, playerJSON{ID: p.ID, Eliminated: p.Eliminated})
+	}
+	return out
+}
+
+func (s *Server) createGame(w http.ResponseWriter, r *http.Request) {
+	req := createRequest{Size: 7, Holes: 2, RiverPush: 2, Players: []string{"P1", "P2"}}
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if req.Size < 1 || req.Size > MaxSize {
+		writeError(w, errorf(http.StatusBadRequest, "size must be between 1 and %d", MaxSize))
+		return
+	}
+	if err := game.CheckNames(req.Players); err != nil {
+		writeError(w, errorf(http.StatusBadRequest, "%v", err))
+		return
+	}
+	if req.RiverLength == 0 {
+		req.RiverLength = req.Size + 2
+	}
+	var specs []game.MonsterSpec
+	for _, m := range req.Monsters {
+		spec, err := game.ParseMonsterSpec(m)
+		if err != nil {
+			writeError(w, errorf(http.StatusBadRequest, "%v", err))
+			return
+		}
+		specs = append(specs, spec)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), GenerationTimeout)
+	defer cancel()
+	g, err := game.NewGameWithConfig(ctx, req.Size, req.Holes, req.RiverLength, req.RiverPush, req.Topology, req.Players)
+	if err == nil {
+		err = g.PlaceMonsters(specs)
+	}
+	if err != nil {
+		writeError(w, errorf(http.StatusUnprocessableEntity, "%v", err))
+		return
+	}
+	s.addGame(w, g)
+}
+
+func (s *Server) loadGame(w http.ResponseWriter, r *http.Request) {
+	var req fileRequest
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := checkFileName(req.Name); err != nil {
+		writeError(w, err)
+		return
+	}
+	g, err := game.LoadFromFile(req.Name)
+	if err != nil {
+		writeError(w, errorf(http.StatusUnprocessableEntity, "%v", err))
+		return
+	}
+	s.addGame(w, g)
+}
+
+func (s *Server) addGame(w http.ResponseWriter, g *game.Game) {
+	id, err := s.store.Add(NewSession(g))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/games/"+id)
+	writeJSON(w, http.StatusCreated, stateJSON(id, g))
+}
+
+// session looks up the session named in the path and locks it. The caller
+// must unlock it.
+func (s *Server) session(r *http.Request) (*Session, error) {
+	sess, err := s.store.Get(r.PathValue("id"))
+	if err != nil {
+		return nil, err
+	}
+	sess.mu.Lock()
+	return sess, nil
+}
+
+// player returns the player whose token the request carries.
+func (s *Server) player(r *http.Request, sess *Session) (string, error) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return "", errorf(http.StatusUnauthorized, "missing player token")
+	}
+	player, ok := sess.playerOf(token)
+	if !ok {
+		return "", errorf(http.StatusUnauthorized, "invalid player token")
+	}
+	return player, nil
+}
+
+func (s *Server) joinGame(w http.ResponseWriter, r *http.Request) {
+	var req joinRequest
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	sess, err := s.session(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer sess.mu.Unlock()
+
+	if !hasPlayer(sess.Game, req.Player) {
+		writeError(w, errorf(http.StatusBadRequest, "unknown player %q", req.Player))
+		return
+	}
+	token, err := sess.join(req.Player)
+	if err != nil {
+		writeError(w, errorf(http.StatusConflict, "%v", err))
+		return
+	}
+	writeJSON(w, http.StatusCreated, joinResponse{Player: req.Player, Token: token})
+}
+
+func hasPlayer(g *game.Game, id string) bool {
+	for _, p := range g.Players {
+		if p.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) getGame(w http.ResponseWriter, r *http.Request) {
+	sess, err := s.session(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer sess.mu.Unlock()
+	writeJSON(w, http.StatusOK, stateJSON(r.PathValue("id"), sess.Game))
+}
+
+func (s *Server) postAction(w http.ResponseWriter, r *http.Request) {
+	var req actionRequest
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if req.Turn == nil {
+		writeError(w, errorf(http.StatusBadRequest, "turn is required"))
+		return
+	}
+
+	sess, err := s.session(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer sess.mu.Unlock()
+	g := sess.Game
+	player, err := s.player(r, sess)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if *req.Turn != g.Turn {
+		turn := g.Turn
+		writeError(w, &httpError{status: http.StatusConflict, msg: fmt.Sprintf("turn is %d, not %d", g.Turn, *req.Turn), turn: &turn})
+		return
+	}
+	if g.IsOver() {
+		writeError(w, errorf(http.StatusConflict, "game is over"))
+		return
+	}
+	if current := g.CurrentPlayer().ID; player != current {
+		writeError(w, errorf(http.StatusForbidden, "it is %s's turn", current))
+		return
+	}
+
+	out, err := g.PerformAction(req.Command)
+	if err != nil {
+		writeError(w, errorf(http.StatusConflict, "%v", err))
+		return
+	}
+
+	resp := actionResponse{
+		Message:  out.Message(),
+		Events:   []string{},
+		UsedTurn: out.UsedTurn,
+		Game:     stateJSON(r.PathValue("id"), g),
+	}
+	for _, e := range out.Events {
+		resp.Events = append(resp.Events, e.Kind.String())
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) getLog(w http.ResponseWriter, r *http.Request) {
+	sess, err := s.session(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer sess.mu.Unlock()
+	player, err := s.player(r, sess)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	entries := []logEntryJSON{}
+	for _, e := range sess.Game.Log {
+		msg := e.Outcome.MessageFor(player)
+		if msg == "" {
+			continue
+		}
+		entries = append(entries, logEntryJSON{
+			Turn:    e.Turn,
+			Player:  e.Outcome.PlayerID,
+			Command: e.Outcome.Command,
+			Message: msg,
+		})
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+func (s *Server) getReveal(w http.ResponseWriter, r *http.Request) {
+	sess, err := s.session(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer sess.mu.Unlock()
+
+	if !sess.Game.IsOver() {
+		writeError(w, errorf(http.StatusConflict, "the maze is revealed once the game is over"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	sess.Game.WriteJSON(w)
+}
+
+func (s *Server) saveGame(w http.ResponseWriter, r *http.Request) {
+	var req fileRequest
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := checkFileName(req.Name); err != nil {
+		writeError(w, err)
+		return
+	}
+	sess, err := s.session(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer sess.mu.Unlock()
+
+	if err := sess.Game.SaveToFile(req.Name); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"name": req.Name})
+}
+
+// checkFileName only allows plain file names, so clients stay inside SaveDir.
+func checkFileName(name string) error {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return errorf(http.StatusBadRequest, "invalid file name %q", name)
+	}
+	return nil
+}
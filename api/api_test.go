@@ -0,0 +1,192 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// client calls a test server and decodes the JSON answers.
+type client struct {
+	t   *testing.T
+	srv *httptest.Server
+}
+
+func newClient(t *testing.T) *client {
+	srv := httptest.NewServer(NewServer(NewMemoryStore()))
+	t.Cleanup(srv.Close)
+	return &client{t: t, srv: srv}
+}
+
+func (c *client) do(method, path, token string, body any, out any) int {
+	c.t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			c.t.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest(method, c.srv.URL+path, &buf)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			c.t.Fatalf("%s %s: decoding answer: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// newGame creates a two player game and joins both seats, returning the
+// game's state and the tokens by player.
+func (c *client) newGame() (gameJSON, map[string]string) {
+	c.t.Helper()
+	var g gameJSON
+	if status := c.do("POST", "/games", "", createRequest{Size: 7, Holes: 2, RiverPush: 2, Players: []string{"P1", "P2"}}, &g); status != http.StatusCreated {
+		c.t.Fatalf("creating a game: status %d", status)
+	}
+	tokens := map[string]string{}
+	for _, p := range []string{"P1", "P2"} {
+		var joined joinResponse
+		if status := c.do("POST", "/games/"+g.ID+"/join", "", joinRequest{Player: p}, &joined); status != http.StatusCreated {
+			c.t.Fatalf("joining as %s: status %d", p, status)
+		}
+		tokens[p] = joined.Token
+	}
+	return g, tokens
+}
+
+func TestJoin(t *testing.T) {
+	c := newClient(t)
+	g, _ := c.newGame()
+
+	tests := []struct {
+		name   string
+		player string
+		want   int
+	}{
+		{"seat taken", "P1", http.StatusConflict},
+		{"unknown player", "P3", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.do("POST", "/games/"+g.ID+"/join", "", joinRequest{Player: tt.player}, nil); got != tt.want {
+				t.Errorf("status %d, want %d", got, tt.want)
+			}
+		})
+	}
+	if got := c.do("POST", "/games/nope/join", "", joinRequest{Player: "P1"}, nil); got != http.StatusNotFound {
+		t.Errorf("joining an unknown game: status %d, want %d", got, http.StatusNotFound)
+	}
+}
+
+func TestActionChecks(t *testing.T) {
+	c := newClient(t)
+	g, tokens := c.newGame()
+	current, other := g.Current, "P2"
+	if current == "P2" {
+		other = "P1"
+	}
+	turn, stale := g.Turn, g.Turn+1
+	_, otherTokens := c.newGame()
+
+	tests := []struct {
+		name  string
+		token string
+		turn  *int
+		want  int
+	}{
+		{"no token", "", &turn, http.StatusUnauthorized},
+		{"unknown token", "0123", &turn, http.StatusUnauthorized},
+		{"token of another game", otherTokens[current], &turn, http.StatusUnauthorized},
+		{"out of turn", tokens[other], &turn, http.StatusForbidden},
+		{"stale turn", tokens[current], &stale, http.StatusConflict},
+		{"no turn", tokens[current], nil, http.StatusBadRequest},
+		{"current player", tokens[current], &turn, http.StatusOK},
+		{"same turn again", tokens[current], &turn, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out map[string]any
+			got := c.do("POST", "/games/"+g.ID+"/actions", tt.token, actionRequest{Command: "UP", Turn: tt.turn}, &out)
+			if got != tt.want {
+				t.Fatalf("status %d, want %d: %v", got, tt.want, out)
+			}
+			if got == http.StatusConflict && out["turn"] == nil {
+				t.Errorf("conflict without the current turn: %v", out)
+			}
+		})
+	}
+}
+
+func TestLogIsPrivate(t *testing.T) {
+	c := newClient(t)
+	g, tokens := c.newGame()
+	if status := c.do("POST", "/games/"+g.ID+"/actions", tokens[g.Current], actionRequest{Command: "UP", Turn: &g.Turn}, nil); status != http.StatusOK {
+		t.Fatalf("acting: status %d", status)
+	}
+
+	if got := c.do("GET", "/games/"+g.ID+"/log?player="+g.Current, "", nil, nil); got != http.StatusUnauthorized {
+		t.Errorf("log without a token: status %d, want %d", got, http.StatusUnauthorized)
+	}
+	var entries []logEntryJSON
+	if got := c.do("GET", "/games/"+g.ID+"/log", tokens[g.Current], nil, &entries); got != http.StatusOK {
+		t.Fatalf("log: status %d", got)
+	}
+	if len(entries) == 0 || entries[0].Player != g.Current {
+		t.Errorf("log of %s is %v, want their move", g.Current, entries)
+	}
+}
+
+func TestRevealWaitsForTheEnd(t *testing.T) {
+	c := newClient(t)
+	g, _ := c.newGame()
+	if got := c.do("GET", "/games/"+g.ID+"/reveal", "", nil, nil); got != http.StatusConflict {
+		t.Errorf("reveal during play: status %d, want %d", got, http.StatusConflict)
+	}
+}
+
+func TestCreateSmallBoards(t *testing.T) {
+	c := newClient(t)
+	for size := 4; size <= 9; size++ {
+		var out map[string]any
+		if got := c.do("POST", "/games", "", createRequest{Size: size, Holes: 1, RiverPush: 2, Players: []string{"P1", "P2"}}, &out); got != http.StatusCreated {
+			t.Errorf("size %d: status %d: %v", size, got, out)
+		}
+	}
+}
+
+func TestCreateChecks(t *testing.T) {
+	c := newClient(t)
+	tests := []struct {
+		name string
+		req  createRequest
+		want int
+	}{
+		{"no players", createRequest{Size: 7, RiverPush: 2}, http.StatusBadRequest},
+		{"blank player", createRequest{Size: 7, RiverPush: 2, Players: []string{"P1", " "}}, http.StatusBadRequest},
+		{"duplicate player", createRequest{Size: 7, RiverPush: 2, Players: []string{"A", "A"}}, http.StatusBadRequest},
+		{"no size", createRequest{RiverPush: 2, Players: []string{"P1"}}, http.StatusBadRequest},
+		{"huge size", createRequest{Size: 100000, RiverPush: 2, Players: []string{"P1"}}, http.StatusBadRequest},
+		{"largest size", createRequest{Size: MaxSize, Holes: 2, RiverPush: 2, Players: []string{"P1"}}, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out map[string]any
+			if got := c.do("POST", "/games", "", tt.req, &out); got != tt.want {
+				t.Errorf("status %d, want %d: %v", got, tt.want, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+
+	"maze-game/game"
+)
+
+// ErrNotFound is returned by a Store for an unknown session ID.
+var ErrNotFound = errors.New("game not found")
+
+// Store keeps the sessions of a Server. Implementations must be safe for
+// concurrent use; the sessions themselves do their own locking.
+type Store interface {
+	Add(s *Session) (id string, err error)
+	Get(id string) (*Session, error)
+}
+
+// Session is a game hosted by the API. What each player has been told so far
+// comes from the game's log.
+type Session struct {
+	mu     sync.Mutex
+	Game   *game.Game
+	tokens map[string]string // player ID by token, for the players who joined
+}
+
+func NewSession(g *game.Game) *Session {
+	return &Session{Game: g, tokens: map[string]string{}}
+}
+
+// join gives player a token to act with, once.
+func (s *Session) join(player string) (string, error) {
+	for _, id := range s.tokens {
+		if id == player {
+			return "", fmt.Errorf("%s has already joined", player)
+		}
+	}
+	token, err := randomHex(16)
+	if err != nil {
+		return "", err
+	}
+	s.tokens[token] = player
+	return token, nil
+}
+
+// playerOf returns the player a token was given to.
+func (s *Session) playerOf(token string) (string, bool) {
+	player, ok := s.tokens[token]
+	return player, ok && token != ""
+}
+
+// randomHex returns n random bytes written as hex.
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// MemoryStore keeps sessions in memory for as long as the process runs.
+type MemoryStore struct {
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: map[string]*Session{}}
+}
+
+func (st *MemoryStore) Add(s *Session) (string, error) {
+	id, err := randomHex(8)
+	if err != nil {
+		return "", err
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.sessions[id] = s
+	return id, nil
+}
+
+func (st *MemoryStore) Get(id string) (*Session, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	s, ok := st.sessions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return s, nil
+}
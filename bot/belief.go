@@ -0,0 +1,401 @@
+package bot
+
+import (
+	"container/heap"
+	"math/rand"
+	"sort"
+
+	"maze-game/game"
+	"maze-game/maze"
+)
+
+// pos is a cell in the bot's own coordinates, relative to where it started
+// or last lost track of itself.
+type pos struct{ r, c int }
+
+type wallState int8
+
+const (
+	wallUnknown wallState = iota
+	wallOpen
+	wallClosed
+)
+
+type cellInfo struct {
+	visited bool
+	typ     maze.CellType
+	walls   [maze.NumDirections]wallState
+}
+
+// belief is a bot's map of the maze, built only from feedback. Holes and the
+// river move the bot somewhere it cannot place on its map, so it starts a new
+// map there. Both always send the bot from the same cell to the same place,
+// so the second time it recognises where it landed.
+type belief struct {
+	topo        maze.Topology
+	at          pos
+	area        *area
+	hurt        bool
+	hasTreasure bool
+	bullet      bool
+	keys        game.KeySet
+	exitKey     int // key the exit was found to need
+
+	// where each hole or river cell sent the bot
+	jumps map[place]place
+
+	// Set for the bot's last own action
+	sawDragon   bool
+	sawTreasure bool
+}
+
+// area is one connected map in the bot's own coordinates.
+type area struct {
+	cells         map[pos]*cellInfo
+	treasure      *pos // where the treasure lies, if known
+	treasureStart *pos
+	exit          *pos
+	hospital      *pos
+	doors         map[door]int // locked doors found, by key
+}
+
+// door is one side of a cell.
+type door struct {
+	at  pos
+	dir maze.Direction
+}
+
+type place struct {
+	area *area
+	at   pos
+}
+
+func newBelief() *belief {
+	b := &belief{topo: maze.Square{}, bullet: true, jumps: map[place]place{}}
+	b.newArea()
+	b.visit(maze.Empty)
+	return b
+}
+
+func (b *belief) newArea() {
+	b.at = pos{}
+	b.area = &area{cells: map[pos]*cellInfo{}, doors: map[door]int{}}
+}
+
+// jump moves the bot after a teleport or river push from its current cell
+// onto a cell of type t.
+func (b *belief) jump(t maze.CellType) {
+	from := place{b.area, b.at}
+	if to, ok := b.jumps[from]; ok {
+		b.area, b.at = to.area, to.at
+	} else {
+		b.newArea()
+		b.jumps[from] = place{b.area, b.at}
+	}
+	b.visit(t)
+}
+
+func (b *belief) step(p pos, d maze.Direction) pos {
+	dr, dc := b.topo.Delta(d)
+	return pos{p.r + dr, p.c + dc}
+}
+
+// dirs lists the directions the bot can move in.
+func (b *belief) dirs() []maze.Direction {
+	return b.topo.Directions()
+}
+
+func (b *belief) cell(p pos) *cellInfo {
+	ci, ok := b.area.cells[p]
+	if !ok {
+		ci = &cellInfo{}
+		b.area.cells[p] = ci
+	}
+	return ci
+}
+
+func (b *belief) visit(t maze.CellType) {
+	ci := b.cell(b.at)
+	ci.visited = true
+	ci.typ = t
+}
+
+func (b *belief) setWall(p pos, d maze.Direction, s wallState) {
+	b.cell(p).walls[d] = s
+	// Walls can be one-way, so a side found closed stays closed
+	if back := b.cell(b.step(p, d)); s == wallOpen && back.walls[b.topo.Opposite(d)] != wallClosed {
+		back.walls[b.topo.Opposite(d)] = s
+	}
+}
+
+// wall reports what the bot knows about the wall on side d of p.
+func (b *belief) wall(p pos, d maze.Direction) wallState {
+	if ci, ok := b.area.cells[p]; ok {
+		return ci.walls[d]
+	}
+	return wallUnknown
+}
+
+func (b *belief) visited(p pos) bool {
+	ci, ok := b.area.cells[p]
+	return ok && ci.visited
+}
+
+// update applies feedback to the map.
+func (b *belief) update(fb Feedback) {
+	if !fb.Own {
+		for _, s := range fb.Signals {
+			switch s.Kind {
+			case game.EventShotHit:
+				b.hurt = true
+				if s.Changed {
+					b.hasTreasure = false
+					here := b.at
+					b.area.treasure = &here
+				}
+			case game.EventDamage:
+				b.hurt = true
+			case game.EventTreasureLost:
+				b.hasTreasure = false
+				b.area.treasure = b.area.treasureStart
+			}
+		}
+		return
+	}
+
+	b.sawDragon, b.sawTreasure = false, false
+	for _, s := range fb.Signals {
+		switch s.Kind {
+		case game.EventMoved:
+			b.setWall(b.at, s.Dir, wallOpen)
+			b.at = b.step(b.at, s.Dir)
+			b.visit(s.Cell)
+		case game.EventBlocked:
+			b.setWall(b.at, s.Dir, wallClosed)
+		case game.EventLocked:
+			b.setWall(b.at, s.Dir, wallClosed)
+			b.area.doors[door{b.at, s.Dir}] = s.Key
+		case game.EventTeleported:
+			b.jump(maze.Hole)
+		case game.EventClimbed:
+			b.jump(maze.Stairs)
+		case game.EventDropped:
+			b.jump(s.Cell)
+		case game.EventRiverPush:
+			b.jump(s.Cell)
+		case game.EventDamage:
+			if s.Monster == "" {
+				b.visit(maze.Dragon)
+			}
+			b.hurt = true
+		case game.EventTreasureLost:
+			b.hasTreasure = false
+			b.area.treasure = b.area.treasureStart
+		case game.EventHealed:
+			b.visit(maze.Hospital)
+			here := b.at
+			b.area.hospital = &here
+			b.hurt = false
+		case game.EventPickup:
+			here := b.at
+			if s.Item == game.ItemKey {
+				b.visit(maze.Key)
+				b.pickUpKey(s.Key)
+			} else if s.Item == game.ItemBullet {
+				b.visit(maze.Armory)
+				b.bullet = true
+			} else if s.Changed {
+				b.hasTreasure = true
+				b.area.treasure = nil
+				if b.area.treasureStart == nil {
+					b.area.treasureStart = &here
+				}
+			} else {
+				b.area.treasure = &here
+			}
+		case game.EventExitDenied, game.EventWin:
+			here := b.at
+			b.area.exit = &here
+		case game.EventExitLocked:
+			here := b.at
+			b.area.exit = &here
+			b.exitKey = s.Key
+		case game.EventShotHit, game.EventShotMiss, game.EventShotMonster, game.EventNoBullet:
+			b.bullet = false
+		case game.EventDragonSeen:
+			b.sawDragon = true
+		case game.EventTreasureSeen:
+			b.sawTreasure = true
+		}
+	}
+}
+
+// pickUpKey adds a key and forgets that the doors it opens were closed, so
+// they get tried again.
+func (b *belief) pickUpKey(key int) {
+	b.keys = b.keys.With(key)
+	for _, a := range b.areas() {
+		for d, k := range a.doors {
+			if k == key {
+				a.cells[d.at].walls[d.dir] = wallUnknown
+				delete(a.doors, d)
+			}
+		}
+	}
+}
+
+// areas lists every map the bot has started.
+func (b *belief) areas() []*area {
+	seen := map[*area]bool{b.area: true}
+	list := []*area{b.area}
+	for from, to := range b.jumps {
+		for _, a := range []*area{from.area, to.area} {
+			if !seen[a] {
+				seen[a] = true
+				list = append(list, a)
+			}
+		}
+	}
+	return list
+}
+
+// goal is where the bot should head for if it knows the way: the hospital
+// when hurt, the exit when carrying the treasure and any key it needs, else
+// the treasure.
+func (b *belief) goal() *pos {
+	switch {
+	case b.hurt:
+		return b.area.hospital
+	case b.hasTreasure:
+		if b.exitKey > 0 && !b.keys.Has(b.exitKey) {
+			return nil
+		}
+		return b.area.exit
+	default:
+		return b.area.treasure
+	}
+}
+
+// stepCost is the cost of entering a known cell. Rivers, holes and stairs
+// move the bot somewhere unknown, and dragons hurt it, so routes avoid them.
+func (b *belief) stepCost(p pos) float64 {
+	ci, ok := b.area.cells[p]
+	if !ok || !ci.visited {
+		return 1
+	}
+	switch ci.typ {
+	case maze.Dragon:
+		return 100
+	case maze.River, maze.Waterfall, maze.Hole, maze.Stairs:
+		return 50
+	}
+	return 1
+}
+
+// routes finds the cheapest way through known open walls to every visited
+// cell. first holds the direction of the first step towards each cell.
+func (b *belief) routes() (dist map[pos]float64, first map[pos]maze.Direction) {
+	dist = map[pos]float64{b.at: 0}
+	first = map[pos]maze.Direction{}
+	q := &posQueue{{b.at, 0}}
+	for q.Len() > 0 {
+		cur := heap.Pop(q).(queued)
+		if cur.cost > dist[cur.p] {
+			continue
+		}
+		for _, d := range b.dirs() {
+			n := b.step(cur.p, d)
+			if b.wall(cur.p, d) != wallOpen || !b.visited(n) {
+				continue
+			}
+			cost := cur.cost + b.stepCost(n)
+			if old, ok := dist[n]; ok && old <= cost {
+				continue
+			}
+			dist[n] = cost
+			if cur.p == b.at {
+				first[n] = d
+			} else {
+				first[n] = first[cur.p]
+			}
+			heap.Push(q, queued{n, cost})
+		}
+	}
+	return dist, first
+}
+
+// frontierMove picks a step into the unknown: trying an unknown wall or
+// entering a cell never visited. score rates an option by the cell it leads
+// into; the cheapest route plus score wins. Ties between directions are broken
+// at random, so a bot that keeps losing its map does not repeat itself.
+func (b *belief) frontierMove(rng *rand.Rand, score func(n pos) float64) (maze.Direction, bool) {
+	dist, first := b.routes()
+	best, found := 0.0, false
+	var bestDir maze.Direction
+	dirs := append([]maze.Direction(nil), b.dirs()...)
+	rng.Shuffle(len(dirs), func(i, j int) { dirs[i], dirs[j] = dirs[j], dirs[i] })
+	for _, c := range sortedPositions(dist) {
+		cost := dist[c]
+		for _, d := range dirs {
+			n := b.step(c, d)
+			w := b.wall(c, d)
+			if w == wallClosed || (w == wallOpen && b.visited(n)) {
+				continue
+			}
+			total := cost + score(n)
+			if !found || total < best {
+				best, found = total, true
+				bestDir = d
+				if c != b.at {
+					bestDir = first[c]
+				}
+			}
+		}
+	}
+	return bestDir, found
+}
+
+// goalMove steps towards the current goal if there is a known way.
+func (b *belief) goalMove() (maze.Direction, bool) {
+	g := b.goal()
+	if g == nil || *g == b.at {
+		return 0, false
+	}
+	_, first := b.routes()
+	d, ok := first[*g]
+	return d, ok
+}
+
+// sortedPositions lists the keys of m in row-major order, so that bots with
+// the same seed make the same choices.
+func sortedPositions(m map[pos]float64) []pos {
+	ps := make([]pos, 0, len(m))
+	for p := range m {
+		ps = append(ps, p)
+	}
+	sort.Slice(ps, func(i, j int) bool {
+		if ps[i].r != ps[j].r {
+			return ps[i].r < ps[j].r
+		}
+		return ps[i].c < ps[j].c
+	})
+	return ps
+}
+
+type queued struct {
+	p    pos
+	cost float64
+}
+
+type posQueue []queued
+
+func (q posQueue) Len() int           { return len(q) }
+func (q posQueue) Less(i, j int) bool { return q[i].cost < q[j].cost }
+func (q posQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *posQueue) Push(x any)        { *q = append(*q, x.(queued)) }
+func (q *posQueue) Pop() any {
+	old := *q
+	x := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return x
+}
@@ -0,0 +1,136 @@
+// Package bot provides computer players. A bot plays blind, like a human:
+// it only sees the feedback its player would be told, never the maze or its
+// own coordinates, and keeps its own map of what it has learned.
+package bot
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+
+	"maze-game/game"
+	"maze-game/maze"
+)
+
+// Bot chooses commands for one player.
+type Bot interface {
+	// Act returns the next command, e.g. "UP" or "SHOOT LEFT".
+	Act() string
+	// Observe is called after every action in the game, the bot's own and
+	// everyone else's.
+	Observe(fb Feedback)
+}
+
+// Feedback is what one player learns from an outcome.
+type Feedback struct {
+	Own     bool   // the bot's own action
+	Player  string // who acted
+	Command string
+	Message string // as game.Outcome.MessageFor tells it
+	Signals []Signal
+}
+
+// Signal is a game.Event without its coordinates, which players are never
+// told. Signals hold the events of the bot's own actions and those that
+// happen to the bot, such as shots that hit it or monsters catching it, but
+// not what befalls other players or where monsters move.
+type Signal struct {
+	Kind    game.EventKind
+	Cell    maze.CellType
+	Dir     maze.Direction
+	Item    game.Item
+	Target  string
+	Monster string
+	Key     int
+	Floor   int
+	Changed bool
+}
+
+// FeedbackFor extracts what player id learns from out.
+func FeedbackFor(out game.Outcome, id string) Feedback {
+	fb := Feedback{
+		Own:     out.PlayerID == id,
+		Player:  out.PlayerID,
+		Command: out.Command,
+		Message: out.MessageFor(id),
+	}
+	for _, e := range out.Events {
+		if e.Kind == game.EventMonsterMoved {
+			continue
+		}
+		if (fb.Own && (e.Target == "" || e.Kind == game.EventShotHit)) || e.Target == id {
+			fb.Signals = append(fb.Signals, Signal{
+				Kind:    e.Kind,
+				Cell:    e.Cell,
+				Dir:     e.Dir,
+				Item:    e.Item,
+				Target:  e.Target,
+				Monster: e.Monster,
+				Key:     e.Key,
+				Floor:   e.Floor,
+				Changed: e.Changed,
+			})
+		}
+	}
+	return fb
+}
+
+// Deliver passes an outcome to every bot, keyed by player ID.
+func Deliver(bots map[string]Bot, out game.Outcome) {
+	for id, b := range bots {
+		b.Observe(FeedbackFor(out, id))
+	}
+}
+
+// PlayTurn lets the bot controlling the current player act and delivers the
+// outcome to all bots. It reports false if the current player is not a bot.
+func PlayTurn(g *game.Game, bots map[string]Bot) (game.Outcome, bool, error) {
+	b, ok := bots[g.CurrentPlayer().ID]
+	if !ok {
+		return game.Outcome{}, false, nil
+	}
+	out, err := g.PerformAction(b.Act())
+	if err != nil {
+		return out, true, err
+	}
+	Deliver(bots, out)
+	return out, true, nil
+}
+
+var kinds = map[string]func(rng *rand.Rand) Bot{
+	"random":   func(rng *rand.Rand) Bot { return NewRandom(rng) },
+	"explorer": func(rng *rand.Rand) Bot { return NewExplorer(rng) },
+	"reasoner": func(rng *rand.Rand) Bot { return NewReasoner(rng) },
+}
+
+// Kinds lists the bots New can create.
+func Kinds() []string {
+	names := make([]string, 0, len(kinds))
+	for name := range kinds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New creates a bot by kind name.
+func New(kind string, rng *rand.Rand) (Bot, error) {
+	newBot, ok := kinds[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown bot %q", kind)
+	}
+	return newBot(rng), nil
+}
+
+// mapper is a bot that keeps a belief.
+type mapper interface {
+	notes() *belief
+}
+
+// UseTopology tells a bot the shape of the maze's cells, which players see
+// on the board. Bots assume square cells until told otherwise.
+func UseTopology(b Bot, t maze.Topology) {
+	if m, ok := b.(mapper); ok {
+		m.notes().topo = t
+	}
+}
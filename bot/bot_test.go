@@ -0,0 +1,73 @@
+package bot
+
+import (
+	"context"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"maze-game/game"
+)
+
+func TestFeedbackFor(t *testing.T) {
+	// P1 moves and shoots P2 while a monster moves and catches P3
+	out := game.Outcome{
+		PlayerID: "P1",
+		Command:  "SHOOT RIGHT",
+		Events: []game.Event{
+			{Kind: game.EventMoved, Row: 1, Col: 2},
+			{Kind: game.EventShotHit, Row: 1, Col: 4, Target: "P2"},
+			{Kind: game.EventMonsterMoved, Row: 3, Col: 3, Monster: "M1"},
+			{Kind: game.EventMonsterAttack, Row: 3, Col: 4, Target: "P3", Monster: "M1"},
+		},
+	}
+	tests := []struct {
+		id   string
+		own  bool
+		want []Signal
+	}{
+		{"P1", true, []Signal{{Kind: game.EventMoved}, {Kind: game.EventShotHit, Target: "P2"}}},
+		{"P2", false, []Signal{{Kind: game.EventShotHit, Target: "P2"}}},
+		{"P3", false, []Signal{{Kind: game.EventMonsterAttack, Target: "P3", Monster: "M1"}}},
+		{"P4", false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			fb := FeedbackFor(out, tt.id)
+			if fb.Own != tt.own || fb.Player != "P1" || fb.Command != "SHOOT RIGHT" {
+				t.Errorf("feedback %+v is not from P1's shot", fb)
+			}
+			if !reflect.DeepEqual(fb.Signals, tt.want) {
+				t.Errorf("signals %+v, want %+v", fb.Signals, tt.want)
+			}
+		})
+	}
+}
+
+// TestKindsFinish plays a small game with each kind of bot in every seat.
+func TestKindsFinish(t *testing.T) {
+	for _, kind := range Kinds() {
+		t.Run(kind, func(t *testing.T) {
+			cfg := game.DefaultConfig(5)
+			cfg.Seed = 3
+			g, err := game.NewGameFromConfig(context.Background(), cfg, 2, []string{"A", "B"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			g.AddEndCondition(game.TurnLimit(50))
+			bots := map[string]Bot{}
+			for i, id := range []string{"A", "B"} {
+				bots[id], _ = New(kind, rand.New(rand.NewSource(int64(i))))
+				UseTopology(bots[id], g.Maze.Topology())
+			}
+			for i := 0; i < 1000 && !g.IsOver(); i++ {
+				if _, ok, err := PlayTurn(g, bots); !ok || err != nil {
+					t.Fatalf("turn %d: bot played %v, error %v", g.Turn, ok, err)
+				}
+			}
+			if !g.IsOver() || g.Turn > 50 {
+				t.Errorf("game over %v on turn %d", g.IsOver(), g.Turn)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package bot
+
+import (
+	"math/rand"
+
+	"maze-game/game"
+)
+
+// Explorer maps the maze systematically. It heads for the hospital, exit or
+// treasure once it knows the way, and otherwise walks to the nearest wall it
+// has not tried yet, avoiding dragons, rivers and holes it has found.
+type Explorer struct {
+	belief *belief
+	rng    *rand.Rand
+}
+
+func NewExplorer(rng *rand.Rand) *Explorer {
+	return &Explorer{belief: newBelief(), rng: rng}
+}
+
+func (e *Explorer) Observe(fb Feedback) {
+	e.belief.update(fb)
+}
+
+func (e *Explorer) notes() *belief { return e.belief }
+
+func (e *Explorer) Act() string {
+	b := e.belief
+	if d, ok := b.goalMove(); ok {
+		return game.Command(d)
+	}
+	if d, ok := b.frontierMove(e.rng, func(pos) float64 { return 0 }); ok {
+		return game.Command(d)
+	}
+	return game.Command(b.dirs()[e.rng.Intn(len(b.dirs()))])
+}
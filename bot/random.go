@@ -0,0 +1,44 @@
+package bot
+
+import (
+	"math/rand"
+
+	"maze-game/game"
+	"maze-game/maze"
+)
+
+// Random moves in a random direction it has not yet found blocked from where
+// it stands, and now and then fires its bullet in a random direction.
+type Random struct {
+	belief    *belief
+	rng       *rand.Rand
+	ShootRate float64
+}
+
+func NewRandom(rng *rand.Rand) *Random {
+	return &Random{belief: newBelief(), rng: rng, ShootRate: 0.05}
+}
+
+func (r *Random) Observe(fb Feedback) {
+	r.belief.update(fb)
+}
+
+func (r *Random) notes() *belief { return r.belief }
+
+func (r *Random) Act() string {
+	b := r.belief
+	if b.bullet && r.rng.Float64() < r.ShootRate {
+		return "SHOOT " + game.Command(b.dirs()[r.rng.Intn(len(b.dirs()))])
+	}
+
+	var dirs []maze.Direction
+	for _, d := range b.dirs() {
+		if b.wall(b.at, d) != wallClosed {
+			dirs = append(dirs, d)
+		}
+	}
+	if len(dirs) == 0 {
+		dirs = b.dirs()
+	}
+	return game.Command(dirs[r.rng.Intn(len(dirs))])
+}
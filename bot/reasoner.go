@@ -0,0 +1,154 @@
+package bot
+
+import (
+	"math/rand"
+
+	"maze-game/game"
+	"maze-game/maze"
+)
+
+// Reasoner explores like Explorer but also weighs where the dragon and the
+// treasure probably are. "The dragon sees you" and "You see the treasure"
+// mean one of them lies in a straight unwalled line from the bot, so every
+// cell on those lines gains weight; a turn without the message clears the
+// lines the bot knows to be open. It steers towards likely treasure and away
+// from likely dragons.
+type Reasoner struct {
+	belief *belief
+	rng    *rand.Rand
+
+	// MaxRange is how far along a line of sight a sighting is spread.
+	MaxRange int
+	// DragonCost is the extra cost of stepping onto a cell that surely holds a
+	// dragon; less likely cells cost proportionally less.
+	DragonCost float64
+
+	areas map[*area]*weights
+}
+
+// weights are the sighting weights for one area of the bot's map.
+type weights struct {
+	dragon     map[pos]float64
+	treasure   map[pos]float64
+	noDragon   map[pos]bool
+	noTreasure map[pos]bool
+}
+
+func NewReasoner(rng *rand.Rand) *Reasoner {
+	return &Reasoner{belief: newBelief(), rng: rng, MaxRange: 6, DragonCost: 10, areas: map[*area]*weights{}}
+}
+
+// weights returns the weights for the area the bot is in.
+func (r *Reasoner) weights() *weights {
+	w, ok := r.areas[r.belief.area]
+	if !ok {
+		w = &weights{
+			dragon:     map[pos]float64{},
+			treasure:   map[pos]float64{},
+			noDragon:   map[pos]bool{},
+			noTreasure: map[pos]bool{},
+		}
+		r.areas[r.belief.area] = w
+	}
+	return w
+}
+
+func (r *Reasoner) notes() *belief { return r.belief }
+
+func (r *Reasoner) Observe(fb Feedback) {
+	b := r.belief
+	b.update(fb)
+	if !fb.Own {
+		return
+	}
+	w := r.weights()
+
+	here := b.area.cells[b.at]
+	if here.typ == maze.Dragon {
+		w.dragon[b.at] = 1
+	} else {
+		w.noDragon[b.at] = true
+		delete(w.dragon, b.at)
+	}
+	if b.area.treasure == nil || *b.area.treasure != b.at {
+		w.noTreasure[b.at] = true
+		delete(w.treasure, b.at)
+	}
+
+	r.sighting(w.dragon, w.noDragon, b.sawDragon)
+	// Only a treasure lying on the maze can be seen
+	if !b.hasTreasure && b.area.treasure == nil {
+		r.sighting(w.treasure, w.noTreasure, b.sawTreasure)
+	}
+}
+
+// sighting updates one weight map after a turn. When seen, the weight is
+// spread over the cells that could be in sight; otherwise the cells known to
+// be in sight are ruled out.
+func (r *Reasoner) sighting(weight map[pos]float64, ruledOut map[pos]bool, seen bool) {
+	b := r.belief
+	var candidates []pos
+	for _, d := range b.dirs() {
+		p := b.at
+		for i := 0; i < r.MaxRange; i++ {
+			w := b.wall(p, d)
+			if w == wallClosed || (!seen && w != wallOpen) {
+				break
+			}
+			p = b.step(p, d)
+			if seen {
+				if !ruledOut[p] {
+					candidates = append(candidates, p)
+				}
+			} else {
+				ruledOut[p] = true
+				delete(weight, p)
+			}
+		}
+	}
+	for _, p := range candidates {
+		weight[p] += 1 / float64(len(candidates))
+	}
+}
+
+func (r *Reasoner) Act() string {
+	b := r.belief
+	if d, ok := b.goalMove(); ok {
+		return game.Command(d)
+	}
+
+	w := r.weights()
+	target, hunting := w.likelyTreasure()
+	hunting = hunting && !b.hurt && !b.hasTreasure
+	score := func(n pos) float64 {
+		s := r.DragonCost * min(1, w.dragon[n])
+		if hunting {
+			s += float64(abs(n.r-target.r) + abs(n.c-target.c))
+		}
+		return s
+	}
+	if d, ok := b.frontierMove(r.rng, score); ok {
+		return game.Command(d)
+	}
+	return game.Command(b.dirs()[r.rng.Intn(len(b.dirs()))])
+}
+
+// likelyTreasure returns the cell most likely to hold the treasure, if any
+// sighting points somewhere.
+func (w *weights) likelyTreasure() (pos, bool) {
+	var best pos
+	bestWeight := 0.0
+	for _, p := range sortedPositions(w.treasure) {
+		if w := w.treasure[p]; w > bestWeight {
+			best, bestWeight = p, w
+		}
+	}
+	return best, bestWeight > 0
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
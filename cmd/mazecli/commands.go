@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image/png"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"maze-game/bot"
+	"maze-game/game"
+	"maze-game/maze"
+	"maze-game/ui"
+)
+
+func runPlay(args []string) error {
+	fs := flag.NewFlagSet("play", flag.ExitOnError)
+	gen := addGenFlags(fs)
+	load := fs.String("load", "", "continue a save or play a text maze instead of generating one")
+	botFlag := fs.String("bots", "", "players run by bots, e.g. P2=reasoner,P3=random; kinds: "+strings.Join(bot.Kinds(), ", "))
+	logFile := fs.String("log", "", "append every action to this file as a line of JSON")
+	fs.Parse(args)
+
+	var g *game.Game
+	var err error
+	if *load != "" {
+		g, err = loadGame(*load, gen.push)
+	} else {
+		g, err = gen.newGame()
+	}
+	if err != nil {
+		return err
+	}
+	bots, err := parseBots(*botFlag, g.Seed)
+	if err != nil {
+		return err
+	}
+	for _, b := range bots {
+		bot.UseTopology(b, g.Maze.Topology())
+	}
+	if *logFile != "" {
+		f, err := os.OpenFile(*logFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		enc := json.NewEncoder(f)
+		g.Subscribe(func(e game.LogEntry) {
+			if err := enc.Encode(e); err != nil {
+				fmt.Fprintln(os.Stderr, "writing log:", err)
+			}
+		})
+	}
+	ui.RunCLIWithBots(g, bots, gen.newGame)
+	return nil
+}
+
+// parseBots reads a list of PLAYER=KIND pairs.
+func parseBots(spec string, seed int64) (map[string]bot.Bot, error) {
+	bots := map[string]bot.Bot{}
+	if spec == "" {
+		return bots, nil
+	}
+	for i, pair := range strings.Split(spec, ",") {
+		id, kind, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("bad bot %q, want PLAYER=KIND", pair)
+		}
+		b, err := bot.New(kind, rand.New(rand.NewSource(seed+int64(i))))
+		if err != nil {
+			return nil, err
+		}
+		bots[id] = b
+	}
+	return bots, nil
+}
+
+func runGenerate(args []string) error {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	gen := addGenFlags(fs)
+	count := fs.Int("count", 1, "number of mazes to generate; seeds count up from -seed")
+	format := fs.String("format", "text", "output format: text or json")
+	out := fs.String("o", "", "output file (default stdout); with -count above 1 the seed is added to the name")
+	fs.Parse(args)
+
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+
+	for i := 0; i < *count; i++ {
+		g, err := gen.newGame()
+		if err != nil {
+			return err
+		}
+		// Pin the seed so the next maze follows on from this one
+		if gen.cfg.Seed == 0 {
+			gen.cfg.Seed = g.Seed
+		}
+		gen.cfg.Seed++
+		fmt.Fprintf(os.Stderr, "generated maze with seed %d\n", g.Seed)
+
+		var f *os.File
+		w := io.Writer(os.Stdout)
+		if *out != "" {
+			path := *out
+			if *count > 1 {
+				ext := filepath.Ext(path)
+				path = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(path, ext), g.Seed, ext)
+			}
+			if f, err = os.Create(path); err != nil {
+				return err
+			}
+			w = f
+		} else if i > 0 {
+			fmt.Println()
+		}
+
+		if *format == "json" {
+			err = g.WriteJSON(w)
+		} else {
+			fmt.Fprintf(w, "# seed %d\n", g.Seed)
+			err = g.WriteMazeText(w)
+		}
+		if f != nil {
+			f.Close()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runSolve(args []string) error {
+	fs := flag.NewFlagSet("solve", flag.ExitOnError)
+	push := fs.Int("push", 2, "cells the river pushes a player (text mazes only)")
+	paths := fs.Bool("paths", false, "print the winning commands for each player")
+	fs.Parse(args)
+	path, err := fileArg(fs)
+	if err != nil {
+		return err
+	}
+
+	m, players, riverPush, err := loadMaze(path, *push)
+	if err != nil {
+		return err
+	}
+	solver := game.NewSolver(m, riverPush)
+
+	fmt.Printf("maze %dx%d, river push %d\n", m.Rows, m.Cols, riverPush)
+	if r, c, found := maze.FindExit(m); found {
+		fmt.Printf("exit at (%d,%d)\n", r, c)
+	} else {
+		fmt.Println("no exit")
+	}
+	if m.TreasureOnMap {
+		fmt.Printf("treasure at (%d,%d)\n", m.TreasureRow, m.TreasureCol)
+	}
+	// Assume every key has been found by the time a player needs them
+	keys := game.MazeKeys(m)
+	fmt.Printf("treasure reachable from estuary: %s\n", yesNo(game.CanReachTreasureFromEstuary(m, riverPush, keys)))
+	fmt.Printf("hospital and exit reachable from each other: %s\n", yesNo(game.HospitalReachableFromExit(m, riverPush, keys)))
+
+	for _, p := range players {
+		st := game.PlayerState{Row: p.Row, Col: p.Col, Hurt: p.Hurt, HasTreasure: p.HasTreasure, Bullet: p.Bullet, Keys: p.Keys}
+		moves, ok := solver.PathToWin(st)
+		if !ok {
+			fmt.Printf("%s at (%d,%d): cannot win\n", p.ID, p.Row, p.Col)
+			continue
+		}
+		fmt.Printf("%s at (%d,%d): wins in %d moves\n", p.ID, p.Row, p.Col, len(moves))
+		if *paths {
+			fmt.Printf("  %s\n", strings.Join(moves, ", "))
+		}
+	}
+	return nil
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func runRender(args []string) error {
+	fs := flag.NewFlagSet("render", flag.ExitOnError)
+	format := fs.String("format", "ascii", "output format: ascii, text or png")
+	out := fs.String("o", "", "output file (default stdout)")
+	cell := fs.Int("cell", 32, "cell size in pixels for png")
+	fs.Parse(args)
+	path, err := fileArg(fs)
+	if err != nil {
+		return err
+	}
+
+	var m *maze.Maze
+	var players []*game.Player
+	var monsters []*game.Monster
+	if isTextMaze(path) {
+		m, players, _, err = loadMaze(path, 0)
+	} else {
+		var g *game.Game
+		if g, err = readSave(path); err == nil {
+			m, players, monsters = g.Maze, g.Players, g.Monsters
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	switch *format {
+	case "ascii":
+		ui.WriteMap(w, m, players, monsters)
+	case "text":
+		starts := make([]maze.PlayerStart, len(players))
+		for i, p := range players {
+			starts[i] = maze.PlayerStart{ID: p.ID, Row: p.Row, Col: p.Col}
+		}
+		return maze.WriteText(w, m, starts)
+	case "png":
+		return png.Encode(w, ui.RenderImage(m, players, monsters, *cell))
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+	return nil
+}
+
+func runReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	step := fs.Bool("step", false, "wait for Enter after each move")
+	fs.Parse(args)
+	path, err := fileArg(fs)
+	if err != nil {
+		return err
+	}
+
+	final, err := readSave(path)
+	if err != nil {
+		return err
+	}
+	g, err := final.InitialState()
+	if err != nil {
+		return err
+	}
+
+	stdin := bufio.NewScanner(os.Stdin)
+	ui.ShowMap(g)
+	for i, move := range final.MoveHistory {
+		if *step {
+			fmt.Print("Press Enter for the next move...")
+			if !stdin.Scan() {
+				return nil
+			}
+		}
+		out, err := g.PerformAction(move)
+		if err != nil {
+			return fmt.Errorf("move %d (%s): %w", i+1, move, err)
+		}
+		fmt.Printf("\nMove %d: %s\n", i+1, out.Message())
+		ui.ShowMap(g)
+	}
+	if g.IsOver() && g.Winner != "" {
+		fmt.Printf("%s wins: %s.\n", g.Winner, g.EndReason)
+	}
+	return nil
+}
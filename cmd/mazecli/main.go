@@ -0,0 +1,226 @@
+// Command mazecli runs the maze game without a window.
+//
+//	mazecli play     [flags]        play in the terminal
+//	mazecli generate [flags]        write new mazes as text or JSON
+//	mazecli solve    [flags] FILE   report reachability and optimal paths
+//	mazecli render   [flags] FILE   draw a maze as ASCII or PNG
+//	mazecli replay   [flags] FILE   step through the moves of a save
+//	mazecli serve    [flags]        host network games
+//	mazecli connect  ADDR SESSION PLAYER   join a network game
+//	mazecli http     [flags]        serve the HTTP/JSON API
+//	mazecli simulate [flags]        play bot games and report balance statistics
+//
+// FILE is a save (JSON or gob) or a text maze ending in .txt.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"maze-game/game"
+	"maze-game/maze"
+	"maze-game/mazegen"
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+var commands = []command{
+	{"play", "play in the terminal", runPlay},
+	{"generate", "write new mazes as text or JSON", runGenerate},
+	{"solve", "report reachability and optimal paths", runSolve},
+	{"render", "draw a maze as ASCII or PNG", runRender},
+	{"replay", "step through the moves of a save", runReplay},
+	{"serve", "host network games", runServe},
+	{"connect", "join a network game", runConnect},
+	{"http", "serve the HTTP/JSON API", runHTTP},
+	{"simulate", "play bot games and report balance statistics", runSimulate},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	for _, cmd := range commands {
+		if cmd.name == os.Args[1] {
+			if err := cmd.run(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "mazecli %s: %v\n", cmd.name, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: mazecli <command> [flags]")
+	fmt.Fprintln(os.Stderr, "\ncommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-9s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(os.Stderr, "\nRun mazecli <command> -h for its flags.")
+}
+
+// genFlags holds the flags shared by the commands that generate games.
+type genFlags struct {
+	cfg      mazegen.MazeConfig
+	push     int
+	players  string
+	monsters string
+	timeout  time.Duration
+}
+
+var monstersUsage = "comma-separated monsters as BEHAVIOR[:DAMAGE[:STUN]], e.g. chase:kill,patrol:hurt:2; behaviours: " +
+	strings.Join(game.BehaviorNames(), ", ") + "; damage: hurt, steal, kill; STUN is the rounds a shot stuns it for, 0 to kill"
+
+func addGenFlags(fs *flag.FlagSet) *genFlags {
+	f := &genFlags{}
+	fs.IntVar(&f.cfg.Size, "size", 7, "board size for a square maze")
+	fs.IntVar(&f.cfg.Rows, "rows", 0, "board rows (overrides -size)")
+	fs.IntVar(&f.cfg.Cols, "cols", 0, "board columns (overrides -size)")
+	fs.StringVar(&f.cfg.Shape, "shape", "", "board outline: "+strings.Join(mazegen.ShapeNames, ", "))
+	fs.StringVar(&f.cfg.Topology, "topology", "", "cell shape: "+strings.Join(maze.TopologyNames(), ", "))
+	fs.StringVar(&f.cfg.Wrap, "wrap", "", "edges that lead round to the opposite one: "+strings.Join(maze.WrapNames(), ", "))
+	fs.StringVar(&f.cfg.Algorithm, "algorithm", "", "carving algorithm: "+strings.Join(mazegen.CarverNames(), ", "))
+	fs.IntVar(&f.cfg.NumHoles, "holes", 2, "number of holes")
+	fs.IntVar(&f.cfg.NumArmories, "armories", 1, "number of armories")
+	fs.IntVar(&f.cfg.NumHospitals, "hospitals", 1, "number of hospitals")
+	fs.IntVar(&f.cfg.NumDragons, "dragons", 1, "number of dragons")
+	fs.IntVar(&f.cfg.RiverLength, "river", 0, "river length (0 for the board size plus 2)")
+	fs.IntVar(&f.cfg.Rivers, "rivers", 1, "number of rivers, each with its own estuary")
+	fs.IntVar(&f.cfg.Tributaries, "tributaries", 0, "number of shorter rivers flowing into another one")
+	fs.IntVar(&f.cfg.Lakes, "lakes", 0, "number of river cells that become lakes, where the flow stops")
+	fs.IntVar(&f.cfg.Waterfalls, "waterfalls", 0, "number of river cells that become waterfalls, which push you further")
+	fs.IntVar(&f.cfg.NumKeys, "keys", 0, "number of locked doors, each with a key to find")
+	fs.IntVar(&f.cfg.NumOneWay, "one-way", 0, "number of passages that can only be walked one way")
+	fs.BoolVar(&f.cfg.LockedExit, "locked-exit", false, "lock the exit with a key of its own")
+	fs.IntVar(&f.cfg.Floors, "floors", 1, "number of floors, linked by stairs")
+	fs.BoolVar(&f.cfg.HolesDrop, "holes-drop", false, "holes drop to the floor below instead of to the next hole")
+	fs.IntVar(&f.cfg.ExtraOpenings, "openings", 0, "extra walls to knock down")
+	fs.IntVar(&f.cfg.MinTreasureExitDistance, "treasure-distance", -1, "minimum treasure to exit distance (-1 for the board size minus 2)")
+	fs.Int64Var(&f.cfg.Seed, "seed", 0, "maze seed (0 for a random one)")
+	fs.IntVar(&f.cfg.MaxAttempts, "attempts", 0, "mazes to try before giving up (0 for the default)")
+	fs.IntVar(&f.push, "push", 2, "cells the river pushes a player")
+	fs.StringVar(&f.players, "players", "P1,P2", "comma-separated player names")
+	fs.StringVar(&f.monsters, "monsters", "", monstersUsage)
+	fs.DurationVar(&f.timeout, "timeout", 10*time.Second, "time limit for generating a maze")
+	return f
+}
+
+func (f *genFlags) newGame() (*game.Game, error) {
+	return f.newGameFor(strings.Split(f.players, ","))
+}
+
+func (f *genFlags) newGameFor(names []string) (*game.Game, error) {
+	cfg := f.cfg
+	rows, cols := cfg.Dimensions()
+	size := rows
+	if cols < size {
+		size = cols
+	}
+	if cfg.RiverLength == 0 {
+		cfg.RiverLength = size + 2
+	}
+	if cfg.MinTreasureExitDistance < 0 {
+		cfg.MinTreasureExitDistance = size - 2
+	}
+
+	specs, err := game.ParseMonsterSpecs(f.monsters)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), f.timeout)
+	defer cancel()
+	g, err := game.NewGameFromConfig(ctx, cfg, f.push, names)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.PlaceMonsters(specs); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// loadMaze reads a text maze or a save. Players of a text maze are placed on
+// their starts; riverPush is only used for text mazes, saves keep their own.
+func loadMaze(path string, riverPush int) (*maze.Maze, []*game.Player, int, error) {
+	if isTextMaze(path) {
+		m, starts, err := readTextMaze(path)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		players := make([]*game.Player, len(starts))
+		for i, s := range starts {
+			players[i] = &game.Player{ID: s.ID, Row: s.Row, Col: s.Col, Bullet: true}
+		}
+		return m, players, riverPush, nil
+	}
+
+	g, err := readSave(path)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	return g.Maze, g.Players, g.RiverMoveLength, nil
+}
+
+// loadGame reads a save, or starts a new game on a text maze.
+func loadGame(path string, riverPush int) (*game.Game, error) {
+	if isTextMaze(path) {
+		m, starts, err := readTextMaze(path)
+		if err != nil {
+			return nil, err
+		}
+		return game.NewGameFromMaze(m, starts, riverPush)
+	}
+	return readSave(path)
+}
+
+func isTextMaze(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".txt")
+}
+
+func readTextMaze(path string) (*maze.Maze, []maze.PlayerStart, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	m, starts, err := maze.ParseText(f)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, starts, nil
+}
+
+func readSave(path string) (*game.Game, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	g, err := game.ReadGame(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return g, nil
+}
+
+// fileArg returns the single file argument of a command.
+func fileArg(fs *flag.FlagSet) (string, error) {
+	if fs.NArg() != 1 {
+		return "", fmt.Errorf("expected one FILE argument, got %d", fs.NArg())
+	}
+	return fs.Arg(0), nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+
+	"maze-game/api"
+	"maze-game/game"
+
+	"maze-game/server"
+)
+
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	gen := addGenFlags(fs)
+	addr := fs.String("addr", ":4000", "address to listen on")
+	seats := fs.Int("seats", 2, "players per game")
+	fs.Parse(args)
+
+	srv := server.New(*seats, gen.newGameFor)
+	log.Printf("serving %d-player games on %s", *seats, *addr)
+	return srv.ListenAndServe(*addr)
+}
+
+// runConnect is a minimal client: it joins a session and then copies lines
+// between the terminal and the server.
+func runConnect(args []string) error {
+	if len(args) != 3 {
+		return fmt.Errorf("usage: mazecli connect ADDR SESSION PLAYER")
+	}
+	conn, err := net.Dial("tcp", args[0])
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	fmt.Fprintf(conn, "JOIN %s %s\n", args[1], args[2])
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			fmt.Fprintln(conn, scanner.Text())
+		}
+		fmt.Fprintln(conn, "QUIT")
+	}()
+	_, err = io.Copy(os.Stdout, conn)
+	return err
+}
+
+func runHTTP(args []string) error {
+	fs := flag.NewFlagSet("http", flag.ExitOnError)
+	addr := fs.String("addr", ":8080", "address to listen on")
+	saveDir := fs.String("save-dir", game.SaveDir, "directory for saved games")
+	fs.Parse(args)
+
+	game.SaveDir = *saveDir
+	log.Printf("serving the HTTP API on %s", *addr)
+	return http.ListenAndServe(*addr, api.NewServer(api.NewMemoryStore()))
+}
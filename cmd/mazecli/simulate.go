@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"maze-game/bot"
+	"maze-game/game"
+	"maze-game/sim"
+)
+
+func runSimulate(args []string) error {
+	fs := flag.NewFlagSet("simulate", flag.ExitOnError)
+	seats := fs.String("seats", "reasoner,reasoner", "bot kind for each seat in turn order; kinds: "+strings.Join(bot.Kinds(), ", "))
+	games := fs.Int("games", 100, "games per variant")
+	seed := fs.Int64("seed", 1, "seed of the first game (0 for a random one)")
+	turns := fs.Int("turns", 500, "turn limit per game")
+	workers := fs.Int("workers", 0, "games played at once (0 for one per CPU)")
+	format := fs.String("format", "csv", "output format: csv or json")
+	perGame := fs.Bool("games-out", false, "write one record per game instead of per variant")
+	out := fs.String("o", "", "output file (default stdout)")
+	monsters := fs.String("monsters", "", monstersUsage)
+
+	var grid sim.Grid
+	lists := []struct {
+		name, usage string
+		values      *[]int
+	}{
+		{"size", "board sizes", &grid.Sizes},
+		{"holes", "numbers of holes", &grid.Holes},
+		{"river", "river lengths (0 for the board size plus 2)", &grid.RiverLengths},
+		{"push", "cells the river pushes a player", &grid.RiverPushes},
+		{"openings", "extra walls to knock down", &grid.ExtraOpenings},
+		{"dragons", "numbers of dragons", &grid.Dragons},
+		{"treasure-distance", "minimum treasure to exit distances (-1 for the board size minus 2)", &grid.TreasureDistances},
+	}
+	for _, l := range lists {
+		fs.Var((*intList)(l.values), l.name, "comma-separated "+l.usage)
+	}
+	fs.Parse(args)
+
+	if *format != "csv" && *format != "json" {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+	specs, err := game.ParseMonsterSpecs(*monsters)
+	if err != nil {
+		return err
+	}
+	opts := sim.Options{
+		Variants: grid.Variants(),
+		Seats:    strings.Split(*seats, ","),
+		Games:    *games,
+		Seed:     *seed,
+		MaxTurns: *turns,
+		Workers:  *workers,
+		Monsters: specs,
+	}
+	results, err := sim.Run(context.Background(), opts)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	n := len(opts.Seats)
+	switch {
+	case *perGame && *format == "json":
+		return sim.WriteResultsJSON(w, results)
+	case *perGame:
+		return sim.WriteResultsCSV(w, results, n)
+	case *format == "json":
+		return sim.WriteJSON(w, sim.Summarize(opts.Variants, n, results))
+	default:
+		return sim.WriteCSV(w, sim.Summarize(opts.Variants, n, results), n)
+	}
+}
+
+// intList is a flag holding comma-separated integers.
+type intList []int
+
+func (l *intList) String() string {
+	if l == nil {
+		return ""
+	}
+	s := make([]string, len(*l))
+	for i, v := range *l {
+		s[i] = strconv.Itoa(v)
+	}
+	return strings.Join(s, ",")
+}
+
+func (l *intList) Set(value string) error {
+	*l = nil
+	for _, field := range strings.Split(value, ",") {
+		v, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return err
+		}
+		*l = append(*l, v)
+	}
+	return nil
+}
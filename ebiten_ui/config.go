@@ -3,6 +3,7 @@ package ebiten_ui
 import (
 	"fmt"
 	"image/color"
+	"maze-game/maze"
 	"strconv"
 	"strings"
 	"unicode"
@@ -22,6 +23,7 @@ type ConfigScreen struct {
 	numPlayers     int
 
 	enterPressedLastFrame bool
+	errMessage            string
 
 	Background *ebiten.Image
 }
@@ -31,11 +33,12 @@ func NewConfigScreen() *ConfigScreen {
 	return &ConfigScreen{
 		Done: false,
 		inputs: []string{
-			"6", // Maze size
-			"3", // Num holes
-			"2", // Num players
-			"2", // River push
-			"8", // River lengt
+			"6",      // Maze size
+			"3",      // Num holes
+			"2",      // Num players
+			"2",      // River push
+			"8",      // River lengt
+			"square", // Grid
 		},
 		fieldLabels: []string{
 			"Maze Size:",
@@ -43,6 +46,7 @@ func NewConfigScreen() *ConfigScreen {
 			"Number of Players:",
 			"River Push Distance:",
 			"River Length:",
+			"Grid (" + strings.Join(maze.TopologyNames(), ", ") + "):",
 		},
 		currentField: 0,
 		Background:   bgImage,
@@ -105,6 +109,15 @@ func (c *ConfigScreen) Update() {
 	}
 }
 
+// ShowError sends the user back to the numeric fields with the reason the
+// last config could not be used.
+func (c *ConfigScreen) ShowError(err error) {
+	c.errMessage = "Could not create a maze: " + err.Error()
+	c.Done = false
+	c.inputtingNames = false
+	c.currentField = 0
+}
+
 func (c *ConfigScreen) Draw(screen *ebiten.Image) {
 	var _ = ebitenutil.DebugPrintAt
 	if c.Background != nil {
@@ -133,13 +146,19 @@ func (c *ConfigScreen) Draw(screen *ebiten.Image) {
 			text.Draw(screen, line, MainFont, xMargin+2*HeadlineHeight, yMargin+HeadlineHeight+lineHeight+(i*lineHeight), color.White)
 		}
 	}
+
+	if c.errMessage != "" {
+		errY := yMargin + HeadlineHeight + (len(c.fieldLabels)+2)*lineHeight
+		text.Draw(screen, c.errMessage, MainFont, xMargin+HeadlineHeight, errY, color.RGBA{255, 80, 80, 255})
+	}
 }
 
-func (c *ConfigScreen) GetConfig() (size, holes, riverLength, riverPush int, names []string) {
+func (c *ConfigScreen) GetConfig() (size, holes, riverLength, riverPush int, topology string, names []string) {
 	size, _ = strconv.Atoi(c.inputs[0])
 	holes, _ = strconv.Atoi(c.inputs[1])
 	riverPush, _ = strconv.Atoi(c.inputs[3])
 	riverLength, _ = strconv.Atoi(c.inputs[4])
+	topology = strings.TrimSpace(c.inputs[5])
 
 	names = make([]string, len(c.playerNames))
 	for i, name := range c.playerNames {
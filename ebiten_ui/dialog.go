@@ -1,10 +1,14 @@
 package ebiten_ui
 
 import (
+	"context"
 	"fmt"
 	"image/color"
 	"maze-game/game"
+	"maze-game/ui"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/text"
@@ -13,6 +17,11 @@ import (
 const (
 	dialogExitButtonX = screenWidth - 130
 	dialogExitButtonY = screenHeight - 150
+
+	notesCellSize = 20
+
+	// generationTimeout keeps an impossible config from freezing the window
+	generationTimeout = 10 * time.Second
 )
 
 type DialogScreen struct {
@@ -26,15 +35,21 @@ type DialogScreen struct {
 
 	Background *ebiten.Image
 	ExitButton *ebiten.Image
+	Notes      *ebiten.Image // the current player's own map
 }
 
-func NewDialogScreen(size, holes, riverLength, riverPush int, names []string) *DialogScreen {
-	g := game.NewGameWithConfig(size, holes, riverLength, riverPush, names)
+func NewDialogScreen(size, holes, riverLength, riverPush int, topology string, names []string) (*DialogScreen, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), generationTimeout)
+	defer cancel()
+	g, err := game.NewGameWithConfig(ctx, size, holes, riverLength, riverPush, topology, names)
+	if err != nil {
+		return nil, err
+	}
 	bgImage := loadImageFromEmbed("backgrounds/background.png")
 	exitImage := loadImageFromEmbed("buttons/dialog_button_exit.png")
-	return &DialogScreen{
+	d := &DialogScreen{
 		Game:      g,
-		Messages:  []string{"Game started. Use commands like: UP, DOWN, LEFT, RIGHT, SHOOT <dir>, EXIT"},
+		Messages:  []string{fmt.Sprintf("Game started. Use commands like: %s, SHOOT <dir>, EXIT", strings.Join(g.MoveCommands(), ", ")), fmt.Sprintf("Maze seed: %d", g.Seed)},
 		startGame: *g.Copy(),
 		KeyWasDown: map[ebiten.Key]bool{
 			ebiten.KeyArrowUp:    false,
@@ -46,6 +61,29 @@ func NewDialogScreen(size, holes, riverLength, riverPush int, names []string) *D
 		Background: bgImage,
 		ExitButton: exitImage,
 	}
+	d.refreshNotes()
+	return d, nil
+}
+
+// refreshNotes redraws the map of the player whose turn it is, so each player
+// only ever sees their own notes.
+func (d *DialogScreen) refreshNotes() {
+	if d.Game.IsOver() {
+		d.Notes = nil
+		return
+	}
+	p := d.Game.CurrentPlayer()
+	k, err := d.Game.Knowledge(p.ID)
+	if err != nil {
+		d.Notes = nil
+		return
+	}
+	// Only the floor the player is on is shown
+	img := ui.RenderKnowledge(k, p, notesCellSize)
+	if k.Floors > 1 {
+		img = ui.CropFloor(img, k.Topology, k.Rows, k.Cols, k.Floors, p.Row/(k.Rows/k.Floors), notesCellSize)
+	}
+	d.Notes = ebiten.NewImageFromImage(img)
 }
 
 func (d *DialogScreen) Update(u *UIManager) {
@@ -72,11 +110,16 @@ func (d *DialogScreen) Update(u *UIManager) {
 		d.KeyWasDown[ebiten.KeyEnter] = false
 	}
 
-	// Arrow keys as movement commands
+	// Arrow keys as movement commands, and Home, End, Page Up and Page Down
+	// for the slanted sides of hexagons
 	d.checkArrowKey(ebiten.KeyArrowUp, "UP")
 	d.checkArrowKey(ebiten.KeyArrowDown, "DOWN")
 	d.checkArrowKey(ebiten.KeyArrowLeft, "LEFT")
 	d.checkArrowKey(ebiten.KeyArrowRight, "RIGHT")
+	d.checkArrowKey(ebiten.KeyHome, "UP-LEFT")
+	d.checkArrowKey(ebiten.KeyPageUp, "UP-RIGHT")
+	d.checkArrowKey(ebiten.KeyEnd, "DOWN-LEFT")
+	d.checkArrowKey(ebiten.KeyPageDown, "DOWN-RIGHT")
 
 	mouseDown := ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft)
 	x, y := ebiten.CursorPosition()
@@ -93,7 +136,7 @@ func (d *DialogScreen) Update(u *UIManager) {
 
 func (d *DialogScreen) checkArrowKey(key ebiten.Key, command string) {
 	if ebiten.IsKeyPressed(key) {
-		if !d.KeyWasDown[key] {
+		if !d.KeyWasDown[key] && d.Game.IsDirection(command) {
 			d.processCommand(command)
 		}
 		d.KeyWasDown[key] = true
@@ -107,7 +150,8 @@ func (d *DialogScreen) processCommand(input string) {
 	input = strings.TrimSpace(input)
 	parts := strings.Fields(input)
 
-	var result string
+	var out game.Outcome
+	var err error
 
 	if len(parts) == 1 {
 		cmd := strings.ToUpper(parts[0])
@@ -116,15 +160,24 @@ func (d *DialogScreen) processCommand(input string) {
 			d.appendMessage("Game ended.")
 			d.Done = true
 			return
-		case "SKIP":
-			d.Game.NextPlayer()
-			d.appendMessage("Turn skipped.")
+		case "UNDO", "REDO":
+			undo := d.Game.Undo
+			if cmd == "REDO" {
+				undo = d.Game.Redo
+			}
+			if err := undo(); err != nil {
+				d.appendMessage("Error: " + err.Error())
+			} else {
+				d.refreshNotes()
+				d.appendMessage(fmt.Sprintf("%s: turn %d, %s to play.", strings.ToLower(cmd), d.Game.Turn, d.Game.CurrentPlayer().ID))
+			}
 			return
-		case "UP", "DOWN", "LEFT", "RIGHT":
-			result, _ = d.Game.PerformAction(cmd)
 		default:
-			d.appendMessage("Unknown command.")
-			return
+			if cmd != "SKIP" && !d.Game.IsDirection(cmd) {
+				d.appendMessage("Unknown command.")
+				return
+			}
+			out, err = d.Game.PerformAction(cmd)
 		}
 	} else if len(parts) == 2 {
 		cmd := strings.ToUpper(parts[0])
@@ -133,12 +186,24 @@ func (d *DialogScreen) processCommand(input string) {
 		switch cmd {
 		case "SHOOT":
 			dir := strings.ToUpper(arg)
-			if dir == "UP" || dir == "DOWN" || dir == "LEFT" || dir == "RIGHT" {
-				result, _ = d.Game.PerformAction(fmt.Sprintf("SHOOT %s", dir))
+			if d.Game.IsDirection(dir) {
+				out, err = d.Game.PerformAction(fmt.Sprintf("SHOOT %s", dir))
 			} else {
 				d.appendMessage("Invalid direction for SHOOT.")
 				return
 			}
+		case "GOTO":
+			id, err := strconv.Atoi(arg)
+			if err == nil {
+				err = d.Game.GoTo(id)
+			}
+			if err != nil {
+				d.appendMessage("Error: " + err.Error())
+			} else {
+				d.refreshNotes()
+				d.appendMessage(fmt.Sprintf("goto: turn %d, %s to play.", d.Game.Turn, d.Game.CurrentPlayer().ID))
+			}
+			return
 		case "SAVE":
 			err := d.Game.SaveToFile(arg)
 			if err != nil {
@@ -154,6 +219,7 @@ func (d *DialogScreen) processCommand(input string) {
 			} else {
 				*d.Game = *newGame
 				d.startGame = *newGame.Copy()
+				d.refreshNotes()
 				d.appendMessage("Game loaded from " + arg)
 			}
 			return
@@ -167,10 +233,21 @@ func (d *DialogScreen) processCommand(input string) {
 		return
 	}
 
+	if err != nil {
+		d.appendMessage("Error: " + err.Error())
+		return
+	}
+
 	d.appendMessage(fmt.Sprintf("%s's turn: %s", p.ID, input))
-	d.appendMessage(result)
+	d.appendMessage(out.Message())
+	for _, e := range out.Events {
+		if e.Kind == game.EventMonsterAttack && e.Target != p.ID {
+			d.appendMessage(e.Target + ": " + out.MessageFor(e.Target))
+		}
+	}
+	d.refreshNotes()
 
-	if strings.Contains(strings.ToLower(result), "win") {
+	if d.Game.IsOver() {
 		d.Done = true
 	}
 }
@@ -208,5 +285,11 @@ func (d *DialogScreen) Draw(screen *ebiten.Image) {
 	text.Draw(screen, turnInfo, MainFont, xMargin, height-yMargin-HeadlineHeight, color.RGBA{200, 200, 0, 255})
 	text.Draw(screen, inputLine, MainFont, xMargin, height-yMargin, color.White)
 
+	if d.Notes != nil {
+		op := &ebiten.DrawImageOptions{}
+		op.GeoM.Translate(float64(dialogExitButtonX-20-d.Notes.Bounds().Dx()), yMargin)
+		screen.DrawImage(d.Notes, op)
+	}
+
 	drawButtonWithImage(screen, dialogExitButtonX, dialogExitButtonY, sideButtonWidth, sideButtonHeight, "", d.ExitButton)
 }
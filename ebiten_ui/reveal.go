@@ -1,10 +1,12 @@
 package ebiten_ui
 
 import (
+	"fmt"
 	"image/color"
 	"math"
 	"maze-game/game"
 	"maze-game/maze"
+	"maze-game/ui"
 	"strings"
 
 	"github.com/hajimehoshi/ebiten/v2"
@@ -26,6 +28,9 @@ const (
 	revealExitButtonY = screenHeight - 150
 	revealShowButtonX = screenWidth - 130
 	revealShowButtonY = screenHeight - 250
+	floorTabY         = 20
+	floorTabW         = 100
+	floorTabH         = 40
 )
 
 type RevealScreen struct {
@@ -34,6 +39,7 @@ type RevealScreen struct {
 	Images           map[maze.CellType]*ebiten.Image
 	WallH            *ebiten.Image
 	WallV            *ebiten.Image
+	OneWay           *ebiten.Image // an arrow pointing right
 	Treasure         *ebiten.Image
 	Treasure_big     *ebiten.Image
 	RiverCorner      *ebiten.Image
@@ -45,7 +51,9 @@ type RevealScreen struct {
 	ShowNowButton    *ebiten.Image
 	ShowStartButton  *ebiten.Image
 	PlayerBackground *ebiten.Image
+	Floor            int // the floor shown when the maze has several
 	currentMove      int
+	states           []*game.Game // the game after each move, for stepping
 	KeyWasDown       map[ebiten.Key]bool
 }
 
@@ -54,6 +62,7 @@ func NewRevealScreen(start, final *game.Game) *RevealScreen {
 	playerImages, playerImagePaths := loadPlayerImages()
 	wallH := loadImageFromEmbed("walls/wall_horizontal_long.png")
 	wallV := loadImageFromEmbed("walls/wall_vertical_long.png")
+	oneWay := loadImageFromEmbed("walls/wall_oneway.png")
 	treasure := loadImageFromEmbed("cells/treasure.png")
 	treasure_big := loadImageFromEmbed("cells/cell_treasure_2.png")
 	river_corner := loadImageFromEmbed("cells/cell_river_corner.png")
@@ -63,12 +72,13 @@ func NewRevealScreen(start, final *game.Game) *RevealScreen {
 	showStartButton := loadImageFromEmbed("buttons/reveal_button_showstart.png")
 	playerBackground := loadImageFromEmbed("buttons/reveal_button_playercolor.png")
 
-	return &RevealScreen{
+	r := &RevealScreen{
 		StartGame:        start,
 		FinalGame:        final,
 		Images:           images,
 		WallH:            wallH,
 		WallV:            wallV,
+		OneWay:           oneWay,
 		Treasure:         treasure,
 		Treasure_big:     treasure_big,
 		RiverCorner:      river_corner,
@@ -88,6 +98,29 @@ func NewRevealScreen(start, final *game.Game) *RevealScreen {
 			ebiten.KeyEnter:      false,
 		},
 	}
+	r.states = revealStates(start, final)
+	return r
+}
+
+// revealStates lists the states to step through once, instead of replaying
+// the moves every frame: the path of the final game's timeline, or a replay
+// of its history from start when no timeline was recorded.
+func revealStates(start, final *game.Game) []*game.Game {
+	var states []*game.Game
+	for _, n := range final.Timeline().Path() {
+		states = append(states, n.State())
+	}
+	if len(states) > 1 || len(final.MoveHistory) == 0 {
+		return states
+	}
+
+	states = []*game.Game{start.Copy()}
+	g := start.Copy()
+	for _, move := range final.MoveHistory {
+		g.PerformAction(move)
+		states = append(states, g.Copy())
+	}
+	return states
 }
 
 func (r *RevealScreen) Update(u *UIManager) error {
@@ -103,13 +136,20 @@ func (r *RevealScreen) Update(u *UIManager) error {
 			y >= revealShowButtonY && y <= revealShowButtonY+sideButtonHeight {
 			r.ShowCurrent = !r.ShowCurrent
 		}
+		floors := r.FinalGame.GetMaze().FloorCount()
+		for f := 0; f < floors; f++ {
+			tx := floorTabX(floors, f)
+			if floors > 1 && x >= tx && x <= tx+floorTabW && y >= floorTabY && y <= floorTabY+floorTabH {
+				r.Floor = f
+			}
+		}
 	}
 
 	u.mouseWasDown = mouseDown
 
 	if !r.ShowCurrent && ebiten.IsKeyPressed(ebiten.KeyRight) {
 		if !r.KeyWasDown[ebiten.KeyRight] {
-			if r.currentMove < len(r.FinalGame.MoveHistory) {
+			if r.currentMove < len(r.states)-1 {
 				r.currentMove++
 			}
 		}
@@ -141,12 +181,7 @@ func (r *RevealScreen) Draw(screen *ebiten.Image) {
 	if r.ShowCurrent {
 		r.drawGame(screen, r.FinalGame)
 	} else {
-		copied := r.StartGame.Copy()
-		for i := 0; i < r.currentMove && i < len(r.FinalGame.MoveHistory); i++ {
-			move := r.FinalGame.MoveHistory[i]
-			copied.PerformAction(move)
-		}
-		r.drawGame(screen, copied)
+		r.drawGame(screen, r.states[r.currentMove])
 	}
 
 	if !r.ShowCurrent {
@@ -156,23 +191,76 @@ func (r *RevealScreen) Draw(screen *ebiten.Image) {
 	}
 
 	drawButtonWithImage(screen, revealExitButtonX, revealExitButtonY, sideButtonWidth, sideButtonHeight, "", r.ExitButton)
+	r.drawFloorTabs(screen)
+}
+
+// floorTabX is where the tab of floor f starts, the tabs being centred
+// along the top of the screen.
+func floorTabX(floors, f int) int {
+	return (screenWidth-floors*(floorTabW+10))/2 + f*(floorTabW+10)
+}
+
+// drawFloorTabs draws a tab for every floor of a maze with several, the
+// shown floor marked.
+func (r *RevealScreen) drawFloorTabs(screen *ebiten.Image) {
+	floors := r.FinalGame.GetMaze().FloorCount()
+	if floors < 2 {
+		return
+	}
+	for f := 0; f < floors; f++ {
+		label := fmt.Sprintf("Floor %d", f+1)
+		if f == r.Floor {
+			label = "> " + label
+		}
+		drawButton(screen, floorTabX(floors, f), floorTabY, floorTabW, floorTabH, label)
+	}
 }
 
 func (r *RevealScreen) drawGame(screen *ebiten.Image, g *game.Game) {
 	m := g.GetMaze()
 	players := g.Players
 
-	ox := (screenWidth - m.Size*cellSize) / 2
-	oy := (screenHeight - m.Size*cellSize) / 2
+	// Only the rows of the shown floor are drawn, centred like a whole maze
+	floor := min(r.Floor, m.FloorCount()-1)
+	top, bottom := floor*m.FloorRows(), (floor+1)*m.FloorRows()
+	if _, ok := m.Topology().(maze.Square); !ok {
+		r.drawTiles(screen, g, top, bottom)
+		// With no rows to draw on, only the legend
+		r.drawPlayers(screen, 0, 0, 0, 0, players)
+		return
+	}
+	ox := (screenWidth - m.Cols*cellSize) / 2
+	oy := (screenHeight-m.FloorRows()*cellSize)/2 - top*cellSize
 
-	for row := 0; row < m.Size; row++ {
-		for col := 0; col < m.Size; col++ {
+	for row := top; row < bottom; row++ {
+		for col := 0; col < m.Cols; col++ {
+			if m.IsDisabled(row, col) {
+				continue
+			}
 			r.drawCell(screen, m, row, col, ox, oy)
 		}
 	}
-	r.drawInnerWalls(screen, m, ox, oy)
-	r.drawBorderWalls(screen, m, ox, oy)
-	r.drawPlayers(screen, ox, oy, players)
+	r.drawInnerWalls(screen, m, top, bottom, ox, oy)
+	r.drawBorderWalls(screen, m, top, bottom, ox, oy)
+	r.drawMonsters(screen, top, bottom, ox, oy, g.Monsters)
+	r.drawPlayers(screen, top, bottom, ox, oy, players)
+}
+
+// drawMonsters draws live monsters as small dragons, faded while stunned.
+func (r *RevealScreen) drawMonsters(screen *ebiten.Image, top, bottom, ox, oy int, monsters []*game.Monster) {
+	img := r.Images[maze.Dragon]
+	for _, mon := range monsters {
+		if mon.Dead || mon.Row < top || mon.Row >= bottom {
+			continue
+		}
+		op := &ebiten.DrawImageOptions{}
+		op.GeoM.Scale(0.6, 0.6)
+		op.GeoM.Translate(float64(ox+mon.Col*cellSize)+cellSize*0.2, float64(oy+mon.Row*cellSize)+cellSize*0.2)
+		if mon.Stunned > 0 {
+			op.ColorScale.ScaleAlpha(0.5)
+		}
+		screen.DrawImage(img, op)
+	}
 }
 
 func (r *RevealScreen) drawCell(screen *ebiten.Image, m *maze.Maze, row, col, ox, oy int) {
@@ -182,16 +270,30 @@ func (r *RevealScreen) drawCell(screen *ebiten.Image, m *maze.Maze, row, col, ox
 
 	switch cell.Type {
 	case maze.Exit:
-		r.drawExit(screen, *cell, row, col, x, y, m.Size)
-	case maze.River, maze.Estuary:
+		r.drawExit(screen, *cell, row, col, x, y, m)
+	case maze.River, maze.Waterfall, maze.Estuary:
 		r.drawRiverOrEstuary(screen, *cell, row, col, x, y, m)
 	default:
-		img := r.Images[cell.Type]
+		// Cell types without a sprite of their own look empty
+		img, ok := r.Images[cell.Type]
+		if !ok {
+			img = r.Images[maze.Empty]
+		}
 		op := &ebiten.DrawImageOptions{}
 		op.GeoM.Translate(float64(x), float64(y))
 		screen.DrawImage(img, op)
 	}
 
+	// Keys and locked exits are labelled with their key number, stairs with
+	// the floor they lead to
+	if cell.Type == maze.Key {
+		text.Draw(screen, fmt.Sprintf("K%d", cell.Key), MainFont, x+cellSize/3, y+cellSize*2/3, doorColor)
+	} else if cell.Type == maze.Stairs {
+		text.Draw(screen, stairsLabel(m, row, col), MainFont, x+wallOffset, y+cellSize/3, doorColor)
+	} else if cell.Key > 0 {
+		text.Draw(screen, fmt.Sprint(cell.Key), MainFont, x+wallOffset, y+cellSize/3, doorColor)
+	}
+
 	// Treasure overlay
 	if m.TreasureOnMap && m.TreasureRow == row && m.TreasureCol == col {
 		tOp := &ebiten.DrawImageOptions{}
@@ -204,17 +306,28 @@ func (r *RevealScreen) drawCell(screen *ebiten.Image, m *maze.Maze, row, col, ox
 	}
 }
 
-func (r *RevealScreen) drawExit(screen *ebiten.Image, cell maze.Cell, row, col, x, y, size int) {
+// stairsLabel names the floor the stairs at (row, col) lead to, numbered as
+// on the floor tabs.
+func stairsLabel(m *maze.Maze, row, col int) string {
+	nr, _, ok := m.StairsTo(row, col)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("F%d", m.FloorOf(nr)+1)
+}
+
+func (r *RevealScreen) drawExit(screen *ebiten.Image, cell maze.Cell, row, col, x, y int, m *maze.Maze) {
 	img := r.Images[cell.Type]
 	op := &ebiten.DrawImageOptions{}
 	op.GeoM.Translate(-float64(cellSize)/2, -float64(cellSize)/2)
 
+	// Point the exit at the first side that leaves the maze
 	switch {
-	case row == 0:
+	case !m.InBounds(row-1, col):
 		op.GeoM.Rotate(-math.Pi / 2)
-	case row == size-1:
+	case !m.InBounds(row+1, col):
 		op.GeoM.Rotate(math.Pi / 2)
-	case col == 0:
+	case !m.InBounds(row, col-1):
 		op.GeoM.Rotate(math.Pi)
 	}
 
@@ -222,18 +335,20 @@ func (r *RevealScreen) drawExit(screen *ebiten.Image, cell maze.Cell, row, col,
 	screen.DrawImage(img, op)
 }
 
+// drawRiverOrEstuary draws a river, waterfall or estuary cell turned to its
+// flow. A river cell fed from the side is drawn as a bend; where tributaries
+// join, the first one found is used.
 func (r *RevealScreen) drawRiverOrEstuary(screen *ebiten.Image, cell maze.Cell, row, col, x, y int, m *maze.Maze) {
 	dir := cell.RiverDir
 	var img *ebiten.Image
 	op := &ebiten.DrawImageOptions{}
 
-	if cell.Type == maze.River {
+	if cell.Type != maze.Estuary {
 		var prevDir maze.Direction
 		foundPrev := false
-		for _, d := range []maze.Direction{maze.Up, maze.Right, maze.Down, maze.Left} {
-			dr, dc := maze.Delta(d)
-			pr, pc := row+dr, col+dc
-			if m.InBounds(pr, pc) && m.Grid[pr][pc].Type == maze.River {
+		for _, d := range m.Sides(row, col) {
+			pr, pc := m.Neighbor(row, col, d)
+			if m.InBounds(pr, pc) && m.Grid[pr][pc].Type.Flows() && m.Grid[pr][pc].Type != maze.Estuary {
 				if m.Grid[pr][pc].RiverDir == maze.Opposite(d) {
 					prevDir = d
 					foundPrev = true
@@ -270,7 +385,7 @@ func (r *RevealScreen) drawRiverOrEstuary(screen *ebiten.Image, cell maze.Cell,
 				op.GeoM.Translate(float64(x), float64(y))
 			}
 		}
-	} else if cell.Type == maze.Estuary {
+	} else {
 		img = r.Images[cell.Type]
 		op.GeoM.Translate(-float64(cellSize)/2, -float64(cellSize)/2)
 		switch dir {
@@ -287,55 +402,123 @@ func (r *RevealScreen) drawRiverOrEstuary(screen *ebiten.Image, cell maze.Cell,
 	screen.DrawImage(img, op)
 }
 
-func (r *RevealScreen) drawInnerWalls(screen *ebiten.Image, m *maze.Maze, ox, oy int) {
+func (r *RevealScreen) drawInnerWalls(screen *ebiten.Image, m *maze.Maze, top, bottom, ox, oy int) {
 	halfWall := float64(wallOffset) / 2
-	for row := 0; row < m.Size; row++ {
-		for col := 0; col < m.Size; col++ {
+	for row := top; row < bottom; row++ {
+		for col := 0; col < m.Cols; col++ {
+			if m.IsDisabled(row, col) {
+				continue
+			}
 			x := ox + col*cellSize
 			y := oy + row*cellSize
 			cell := m.Grid[row][col]
 
-			if cell.Walls[maze.Right] && col < m.Size-1 {
+			if oneWay, _ := m.IsOneWay(row, col, maze.Right); oneWay && m.InBounds(row, col+1) {
+				r.drawSquareOneWay(screen, m, row, col, maze.Right, ox, oy)
+			} else if cell.Walls[maze.Right] && m.InBounds(row, col+1) {
 				op := &ebiten.DrawImageOptions{}
 				op.GeoM.Translate(float64(x+cellSize)-halfWall, float64(y)-halfWall)
+				tintDoor(op, cell.Door(maze.Right))
 				screen.DrawImage(r.WallV, op)
 			}
-			if cell.Walls[maze.Down] && row < m.Size-1 {
+			if oneWay, _ := m.IsOneWay(row, col, maze.Down); oneWay && m.InBounds(row+1, col) && row+1 < bottom {
+				r.drawSquareOneWay(screen, m, row, col, maze.Down, ox, oy)
+			} else if cell.Walls[maze.Down] && m.InBounds(row+1, col) && row+1 < bottom {
 				op := &ebiten.DrawImageOptions{}
 				op.GeoM.Translate(float64(x)-halfWall, float64(y+cellSize)-halfWall)
+				tintDoor(op, cell.Door(maze.Down))
 				screen.DrawImage(r.WallH, op)
 			}
 		}
 	}
 }
 
-func (r *RevealScreen) drawBorderWalls(screen *ebiten.Image, m *maze.Maze, ox, oy int) {
-	halfWall := float64(wallOffset) / 2
+// drawSquareOneWay draws the one-way wall on side d of the square cell at
+// (row, col), with the maze's top-left corner at (ox, oy).
+func (r *RevealScreen) drawSquareOneWay(screen *ebiten.Image, m *maze.Maze, row, col int, d maze.Direction, ox, oy int) {
+	a, b, _ := ui.Geometry{Topology: maze.Square{}, CellSize: cellSize}.Side(row, col, d)
+	at := func(p ui.Point) ui.Point { return ui.Point{X: p.X + float64(ox), Y: p.Y + float64(oy)} }
+	dr, dc := m.Topology().Delta(d)
+	angle := math.Atan2(float64(dr), float64(dc))
+	if _, out := m.IsOneWay(row, col, d); !out {
+		angle += math.Pi
+	}
+	r.drawOneWay(screen, at(a), at(b), angle)
+}
 
-	for row := 0; row < m.Size; row++ {
-		y := oy + row*cellSize
-		opL := &ebiten.DrawImageOptions{}
-		opL.GeoM.Translate(float64(ox)-halfWall, float64(y)-halfWall)
-		screen.DrawImage(r.WallV, opL)
+// doorColor marks locked doors and the keys that open them.
+var doorColor = color.RGBA{200, 140, 20, 255}
 
-		opR := &ebiten.DrawImageOptions{}
-		opR.GeoM.Translate(float64(ox+m.Size*cellSize)-halfWall, float64(y)-halfWall)
-		screen.DrawImage(r.WallV, opR)
+// tintDoor colours a wall drawn with op if it is a door.
+func tintDoor(op *ebiten.DrawImageOptions, door int) {
+	if door > 0 {
+		op.ColorScale.ScaleWithColor(doorColor)
 	}
+}
 
-	for col := 0; col < m.Size; col++ {
-		x := ox + col*cellSize
-		opT := &ebiten.DrawImageOptions{}
-		opT.GeoM.Translate(float64(x)-halfWall, float64(oy)-halfWall)
-		screen.DrawImage(r.WallH, opT)
+// drawBorderWalls outlines every playable cell side that faces the outside of
+// the grid or a disabled cell, or the floor above or below. On an edge that
+// wraps round only the closed walls are drawn.
+func (r *RevealScreen) drawBorderWalls(screen *ebiten.Image, m *maze.Maze, top, bottom, ox, oy int) {
+	halfWall := float64(wallOffset) / 2
+	// border also draws the one-way walls on a wrapped edge, which are
+	// not a border
+	border := func(row, col int, d maze.Direction) bool {
+		nr, nc := m.Neighbor(row, col, d)
+		if !m.InBounds(nr, nc) {
+			return true
+		}
+		if !m.Wraps(row, col, d) {
+			return false
+		}
+		if oneWay, _ := m.IsOneWay(row, col, d); oneWay {
+			r.drawSquareOneWay(screen, m, row, col, d, ox, oy)
+			return false
+		}
+		return m.Grid[row][col].Walls[d]
+	}
 
-		opB := &ebiten.DrawImageOptions{}
-		opB.GeoM.Translate(float64(x)-halfWall, float64(oy+m.Size*cellSize)-halfWall)
-		screen.DrawImage(r.WallH, opB)
+	for row := top; row < bottom; row++ {
+		for col := 0; col < m.Cols; col++ {
+			if m.IsDisabled(row, col) {
+				continue
+			}
+			x := ox + col*cellSize
+			y := oy + row*cellSize
+
+			cell := m.Grid[row][col]
+
+			if border(row, col, maze.Left) {
+				opL := &ebiten.DrawImageOptions{}
+				opL.GeoM.Translate(float64(x)-halfWall, float64(y)-halfWall)
+				tintDoor(opL, cell.Door(maze.Left))
+				screen.DrawImage(r.WallV, opL)
+			}
+			if border(row, col, maze.Right) {
+				opR := &ebiten.DrawImageOptions{}
+				opR.GeoM.Translate(float64(x+cellSize)-halfWall, float64(y)-halfWall)
+				tintDoor(opR, cell.Door(maze.Right))
+				screen.DrawImage(r.WallV, opR)
+			}
+			if border(row, col, maze.Up) || row == top && !m.Wraps(row, col, maze.Up) {
+				opT := &ebiten.DrawImageOptions{}
+				opT.GeoM.Translate(float64(x)-halfWall, float64(y)-halfWall)
+				tintDoor(opT, cell.Door(maze.Up))
+				screen.DrawImage(r.WallH, opT)
+			}
+			if border(row, col, maze.Down) || row == bottom-1 && !m.Wraps(row, col, maze.Down) {
+				opB := &ebiten.DrawImageOptions{}
+				opB.GeoM.Translate(float64(x)-halfWall, float64(y+cellSize)-halfWall)
+				tintDoor(opB, cell.Door(maze.Down))
+				screen.DrawImage(r.WallH, opB)
+			}
+		}
 	}
 }
 
-func (r *RevealScreen) drawPlayers(screen *ebiten.Image, ox, oy int, players []*game.Player) {
+// drawPlayers draws the players on the shown floor and a legend of all of
+// them.
+func (r *RevealScreen) drawPlayers(screen *ebiten.Image, top, bottom, ox, oy int, players []*game.Player) {
 	legendX := 20
 	legendY := 20
 	lineHeight := 50 // spacing between entries (including background)
@@ -349,12 +532,14 @@ func (r *RevealScreen) drawPlayers(screen *ebiten.Image, ox, oy int, players []*
 		}
 
 		// --- Draw player on maze ---
-		img := r.PlayerImages[i]
-		x := ox + player.Col*cellSize
-		y := oy + player.Row*cellSize
-		op := &ebiten.DrawImageOptions{}
-		op.GeoM.Translate(float64(x), float64(y))
-		screen.DrawImage(img, op)
+		if player.Row >= top && player.Row < bottom {
+			img := r.PlayerImages[i]
+			x := ox + player.Col*cellSize
+			y := oy + player.Row*cellSize
+			op := &ebiten.DrawImageOptions{}
+			op.GeoM.Translate(float64(x), float64(y))
+			screen.DrawImage(img, op)
+		}
 
 		// --- Extract color from image filename ---
 		myColor := "unknown"
@@ -387,16 +572,13 @@ func (r *RevealScreen) drawPlayers(screen *ebiten.Image, ox, oy int, players []*
 }
 
 func loadCellImages() map[maze.CellType]*ebiten.Image {
-	return map[maze.CellType]*ebiten.Image{
-		maze.Empty:    loadImageFromEmbed("cells/cell_empty_2.png"),
-		maze.Hospital: loadImageFromEmbed("cells/cell_hospital_2.png"),
-		maze.Exit:     loadImageFromEmbed("cells/cell_exit.png"),
-		maze.Hole:     loadImageFromEmbed("cells/cell_hole_2.png"),
-		maze.Dragon:   loadImageFromEmbed("cells/cell_dragon_2.png"),
-		maze.Armory:   loadImageFromEmbed("cells/cell_armory_2.png"),
-		maze.River:    loadImageFromEmbed("cells/cell_river.png"),
-		maze.Estuary:  loadImageFromEmbed("cells/cell_estuary.png"),
+	images := map[maze.CellType]*ebiten.Image{}
+	for _, t := range game.CellTypes() {
+		if sprite := game.CellBehaviorOf(t).Sprite(); sprite != "" {
+			images[t] = loadImageFromEmbed(sprite)
+		}
 	}
+	return images
 }
 
 func colorRGBA(name string) color.Color {
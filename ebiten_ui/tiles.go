@@ -0,0 +1,182 @@
+package ebiten_ui
+
+import (
+	"fmt"
+	"math"
+	"maze-game/game"
+	"maze-game/maze"
+	"maze-game/ui"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text"
+)
+
+// drawTiles draws the shown floor of a maze whose cells are not squares.
+// Each cell is its sprite cut to the cell's shape, walls are the wall sprite
+// laid along each side, and players, monsters and the treasure are shrunk
+// to fit inside the cell.
+func (r *RevealScreen) drawTiles(screen *ebiten.Image, g *game.Game, top, bottom int) {
+	m := g.GetMaze()
+	geo := ui.Geometry{Topology: m.Topology(), CellSize: cellSize}
+	lo, hi := geo.Bounds(top, bottom, m.Cols)
+	ox := (screenWidth-(hi.X-lo.X))/2 - lo.X
+	oy := (screenHeight-(hi.Y-lo.Y))/2 - lo.Y
+	at := func(p ui.Point) ui.Point { return ui.Point{X: p.X + ox, Y: p.Y + oy} }
+
+	// scale shrinks a sprite the size of a square cell into the circle
+	// inside the cell
+	a, b, _ := geo.Side(0, 0, m.Sides(0, 0)[0])
+	c0 := geo.Centre(0, 0)
+	scale := 2 * math.Hypot((a.X+b.X)/2-c0.X, (a.Y+b.Y)/2-c0.Y) / cellSize
+	sprite := func(img *ebiten.Image, row, col int, size float64, op *ebiten.DrawImageOptions) {
+		w, h := img.Bounds().Dx(), img.Bounds().Dy()
+		centre := at(geo.Centre(row, col))
+		op.GeoM.Translate(-float64(w)/2, -float64(h)/2)
+		op.GeoM.Scale(scale*size, scale*size)
+		op.GeoM.Translate(centre.X, centre.Y)
+		screen.DrawImage(img, op)
+	}
+
+	for row := top; row < bottom; row++ {
+		for col := 0; col < m.Cols; col++ {
+			if m.IsDisabled(row, col) {
+				continue
+			}
+			cell := m.Grid[row][col]
+			img, ok := r.Images[cell.Type]
+			if !ok {
+				img = r.Images[maze.Empty]
+			}
+			r.drawShape(screen, img, geo.Outline(row, col), at)
+
+			centre := at(geo.Centre(row, col))
+			if cell.Type.Flows() {
+				// Mark the side the water leaves by
+				if a, b, ok := geo.Side(row, col, cell.RiverDir); ok {
+					out := at(ui.Point{X: (a.X + b.X) / 2, Y: (a.Y + b.Y) / 2})
+					op := &ebiten.DrawImageOptions{}
+					op.GeoM.Scale(math.Hypot(out.X-centre.X, out.Y-centre.Y)/float64(r.WallH.Bounds().Dx()), 0.5)
+					op.GeoM.Translate(0, -float64(r.WallH.Bounds().Dy())/4)
+					op.GeoM.Rotate(math.Atan2(out.Y-centre.Y, out.X-centre.X))
+					op.GeoM.Translate(centre.X, centre.Y)
+					op.ColorScale.ScaleAlpha(0.5)
+					screen.DrawImage(r.WallH, op)
+				}
+			}
+			if cell.Type == maze.Key {
+				text.Draw(screen, fmt.Sprintf("K%d", cell.Key), MainFont, int(centre.X)-cellSize/6, int(centre.Y)+cellSize/8, doorColor)
+			} else if cell.Type == maze.Stairs {
+				text.Draw(screen, stairsLabel(m, row, col), MainFont, int(centre.X)-cellSize/6, int(centre.Y)+cellSize/8, doorColor)
+			} else if cell.Key > 0 {
+				text.Draw(screen, fmt.Sprint(cell.Key), MainFont, int(centre.X)-cellSize/12, int(centre.Y)+cellSize/8, doorColor)
+			}
+			if m.TreasureOnMap && m.TreasureRow == row && m.TreasureCol == col {
+				sprite(r.Treasure_big, row, col, 1, &ebiten.DrawImageOptions{})
+			}
+		}
+	}
+
+	// Every closed side, and every side facing the outside of the grid, a
+	// disabled cell or another floor
+	for row := top; row < bottom; row++ {
+		for col := 0; col < m.Cols; col++ {
+			if m.IsDisabled(row, col) {
+				continue
+			}
+			cell := m.Grid[row][col]
+			for _, d := range m.Sides(row, col) {
+				nr, nc := m.Neighbor(row, col, d)
+				border := !m.InBounds(nr, nc) || m.IsDisabled(nr, nc) || nr < top || nr >= bottom
+				if !cell.Walls[d] && !border {
+					continue
+				}
+				a, b, _ := geo.Side(row, col, d)
+				if oneWay, _ := m.IsOneWay(row, col, d); oneWay {
+					// Closed from this cell, so it lets you in
+					mid, centre := ui.Point{X: (a.X + b.X) / 2, Y: (a.Y + b.Y) / 2}, geo.Centre(row, col)
+					r.drawOneWay(screen, at(a), at(b), math.Atan2(centre.Y-mid.Y, centre.X-mid.X))
+					continue
+				}
+				r.drawSide(screen, at(a), at(b), cell.Door(d))
+			}
+		}
+	}
+
+	for _, mon := range g.Monsters {
+		if mon.Dead || mon.Row < top || mon.Row >= bottom {
+			continue
+		}
+		op := &ebiten.DrawImageOptions{}
+		if mon.Stunned > 0 {
+			op.ColorScale.ScaleAlpha(0.5)
+		}
+		sprite(r.Images[maze.Dragon], mon.Row, mon.Col, 0.6, op)
+	}
+	for i, p := range g.Players {
+		if i < len(r.PlayerImages) && p.Row >= top && p.Row < bottom {
+			sprite(r.PlayerImages[i], p.Row, p.Col, 1, &ebiten.DrawImageOptions{})
+		}
+	}
+}
+
+// drawShape fills the polygon with the given corners with img, stretched
+// over the box around them.
+func (r *RevealScreen) drawShape(screen, img *ebiten.Image, corners []ui.Point, at func(ui.Point) ui.Point) {
+	lo := ui.Point{X: math.Inf(1), Y: math.Inf(1)}
+	hi := ui.Point{X: math.Inf(-1), Y: math.Inf(-1)}
+	for _, p := range corners {
+		lo.X, lo.Y = min(lo.X, p.X), min(lo.Y, p.Y)
+		hi.X, hi.Y = max(hi.X, p.X), max(hi.Y, p.Y)
+	}
+	w, h := float64(img.Bounds().Dx()), float64(img.Bounds().Dy())
+
+	vs := make([]ebiten.Vertex, len(corners))
+	for i, p := range corners {
+		dst := at(p)
+		vs[i] = ebiten.Vertex{
+			DstX: float32(dst.X), DstY: float32(dst.Y),
+			SrcX: float32((p.X - lo.X) / (hi.X - lo.X) * w), SrcY: float32((p.Y - lo.Y) / (hi.Y - lo.Y) * h),
+			ColorR: 1, ColorG: 1, ColorB: 1, ColorA: 1,
+		}
+	}
+	var is []uint16
+	for i := 1; i+1 < len(corners); i++ {
+		is = append(is, 0, uint16(i), uint16(i+1))
+	}
+	screen.DrawTriangles(vs, is, img, nil)
+}
+
+// drawSide lays the horizontal wall sprite from a to b, tinted if the wall
+// is a door.
+func (r *RevealScreen) drawSide(screen *ebiten.Image, a, b ui.Point, door int) {
+	op := r.sideOp(a, b)
+	tintDoor(op, door)
+	screen.DrawImage(r.WallH, op)
+}
+
+// drawOneWay draws a one-way wall from a to b as a faded wall with the arrow
+// sprite on its middle, turned by angle to point the way through.
+func (r *RevealScreen) drawOneWay(screen *ebiten.Image, a, b ui.Point, angle float64) {
+	op := r.sideOp(a, b)
+	op.ColorScale.ScaleAlpha(0.4)
+	screen.DrawImage(r.WallH, op)
+
+	w, h := r.OneWay.Bounds().Dx(), r.OneWay.Bounds().Dy()
+	op = &ebiten.DrawImageOptions{}
+	op.GeoM.Translate(-float64(w)/2, -float64(h)/2)
+	op.GeoM.Rotate(angle)
+	op.GeoM.Translate((a.X+b.X)/2, (a.Y+b.Y)/2)
+	screen.DrawImage(r.OneWay, op)
+}
+
+// sideOp places the horizontal wall sprite along the line from a to b.
+func (r *RevealScreen) sideOp(a, b ui.Point) *ebiten.DrawImageOptions {
+	halfWall := float64(wallOffset) / 2
+	length := math.Hypot(b.X-a.X, b.Y-a.Y)
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Scale((length+float64(wallOffset))/float64(r.WallH.Bounds().Dx()), 1)
+	op.GeoM.Translate(-halfWall, -halfWall)
+	op.GeoM.Rotate(math.Atan2(b.Y-a.Y, b.X-a.X))
+	op.GeoM.Translate(a.X, a.Y)
+	return op
+}
@@ -59,9 +59,14 @@ func (u *UIManager) Update() error {
 		u.start.Update(u)
 	case ScreenConfig:
 		if u.config.Done {
-			size, holes, riverLength, riverPush, names := u.config.GetConfig()
-			u.dialog = NewDialogScreen(size, holes, riverLength, riverPush, names)
-			u.screen = ScreenDialog
+			size, holes, riverLength, riverPush, topology, names := u.config.GetConfig()
+			dialog, err := NewDialogScreen(size, holes, riverLength, riverPush, topology, names)
+			if err != nil {
+				u.config.ShowError(err)
+			} else {
+				u.dialog = dialog
+				u.screen = ScreenDialog
+			}
 		} else {
 			u.config.Update()
 		}
@@ -0,0 +1,299 @@
+package game
+
+import (
+	"sort"
+
+	"maze-game/maze"
+)
+
+// CellBehavior is what a cell type does in play and how front-ends show it.
+// Every cell type is registered with one, so new tiles only need a
+// maze.RegisterCellType and a RegisterCell.
+type CellBehavior interface {
+	// Enter is called when a move takes a player onto the cell, with the
+	// player already on it, and returns what happened.
+	Enter(g *Game, p *Player, cell *maze.Cell) []Event
+	// BumpWall is called after a player walked into a closed wall of the
+	// cell. It reports false to end the move there, or true to carry on as
+	// if the player had entered a cell, e.g. after moving them.
+	BumpWall(g *Game, p *Player, cell *maze.Cell, dir maze.Direction) ([]Event, bool)
+	// EnterState is Enter for the Solver: it returns the state of a player
+	// who just stepped onto the cell. It must follow the same rules as Enter.
+	EnterState(s *Solver, st PlayerState, cell *maze.Cell) PlayerState
+	// BumpWallState is BumpWall for the Solver.
+	BumpWallState(s *Solver, st PlayerState, cell *maze.Cell, dir maze.Direction) (PlayerState, bool)
+	// BlocksSight reports whether the dragon, monsters and the treasure are
+	// hidden on and behind the cell.
+	BlocksSight() bool
+	// Symbol is the one-character ASCII symbol of the cell.
+	Symbol(cell maze.Cell) string
+	// Sprite names the cell's image under assets, e.g. "cells/cell_hole_2.png".
+	Sprite() string
+}
+
+// CellKind is a CellBehavior made of plain values and functions. Nil hooks
+// do nothing.
+type CellKind struct {
+	OnEnter         func(g *Game, p *Player, cell *maze.Cell) []Event
+	OnBumpWall      func(g *Game, p *Player, cell *maze.Cell, dir maze.Direction) ([]Event, bool)
+	OnEnterState    func(s *Solver, st PlayerState, cell *maze.Cell) PlayerState
+	OnBumpWallState func(s *Solver, st PlayerState, cell *maze.Cell, dir maze.Direction) (PlayerState, bool)
+	Opaque          bool
+	ASCII           string
+	SpriteName      string
+}
+
+func (k CellKind) Enter(g *Game, p *Player, cell *maze.Cell) []Event {
+	if k.OnEnter == nil {
+		return nil
+	}
+	return k.OnEnter(g, p, cell)
+}
+
+func (k CellKind) BumpWall(g *Game, p *Player, cell *maze.Cell, dir maze.Direction) ([]Event, bool) {
+	if k.OnBumpWall == nil {
+		return nil, false
+	}
+	return k.OnBumpWall(g, p, cell, dir)
+}
+
+func (k CellKind) EnterState(s *Solver, st PlayerState, cell *maze.Cell) PlayerState {
+	if k.OnEnterState == nil {
+		return st
+	}
+	return k.OnEnterState(s, st, cell)
+}
+
+func (k CellKind) BumpWallState(s *Solver, st PlayerState, cell *maze.Cell, dir maze.Direction) (PlayerState, bool) {
+	if k.OnBumpWallState == nil {
+		return st, false
+	}
+	return k.OnBumpWallState(s, st, cell, dir)
+}
+
+func (k CellKind) BlocksSight() bool            { return k.Opaque }
+func (k CellKind) Symbol(cell maze.Cell) string { return k.ASCII }
+func (k CellKind) Sprite() string               { return k.SpriteName }
+
+// riverCell draws rivers as arrows along their flow.
+type riverCell struct {
+	CellKind
+	arrows map[maze.Direction]string
+}
+
+var flowArrows = map[maze.Direction]string{
+	maze.Up:    "↑",
+	maze.Down:  "↓",
+	maze.Left:  "←",
+	maze.Right: "→",
+}
+
+var fallArrows = map[maze.Direction]string{
+	maze.Up:    "⇑",
+	maze.Down:  "⇓",
+	maze.Left:  "⇐",
+	maze.Right: "⇒",
+}
+
+func (r riverCell) Symbol(cell maze.Cell) string {
+	if arrow, ok := r.arrows[cell.RiverDir]; ok {
+		return arrow
+	}
+	return r.ASCII
+}
+
+var cells map[maze.CellType]CellBehavior
+
+// The built-in cells are registered in init, since holes look up the cell a
+// player drops onto.
+func init() {
+	cells = map[maze.CellType]CellBehavior{
+		maze.Empty: CellKind{ASCII: ".", SpriteName: "cells/cell_empty_2.png"},
+		maze.Wall:  CellKind{ASCII: "#"},
+		maze.Hole: CellKind{
+			OnEnter:         enterHole,
+			OnBumpWall:      bumpHole,
+			OnEnterState:    planHole,
+			OnBumpWallState: planBumpHole,
+			ASCII:           "O",
+			SpriteName:      "cells/cell_hole_2.png",
+		},
+		maze.River: riverCell{CellKind{
+			OnEnter:         enterRiver,
+			OnBumpWall:      bumpRiver,
+			OnEnterState:    planRiver,
+			OnBumpWallState: planBumpRiver,
+			ASCII:           "~",
+			SpriteName:      "cells/cell_river.png",
+		}, flowArrows},
+		maze.Waterfall: riverCell{CellKind{
+			OnEnter:         enterRiver,
+			OnBumpWall:      bumpRiver,
+			OnEnterState:    planRiver,
+			OnBumpWallState: planBumpRiver,
+			ASCII:           "W",
+			SpriteName:      "cells/cell_waterfall.png",
+		}, fallArrows},
+		maze.Estuary:  CellKind{ASCII: "~", SpriteName: "cells/cell_estuary.png"},
+		maze.Lake:     CellKind{ASCII: "≈", SpriteName: "cells/cell_lake.png"},
+		maze.Exit:     CellKind{OnEnter: enterExit, ASCII: "E", SpriteName: "cells/cell_exit.png"},
+		maze.Hospital: CellKind{OnEnter: enterHospital, OnEnterState: planHospital, ASCII: "H", SpriteName: "cells/cell_hospital_2.png"},
+		maze.Armory:   CellKind{OnEnter: enterArmory, OnEnterState: planArmory, ASCII: "A", SpriteName: "cells/cell_armory_2.png"},
+		maze.Dragon:   CellKind{OnEnter: enterDragon, OnEnterState: planDragon, ASCII: "D", SpriteName: "cells/cell_dragon_2.png"},
+		maze.Key:      CellKind{OnEnter: enterKey, OnEnterState: planKey, ASCII: "K", SpriteName: "cells/cell_key.png"},
+		maze.Stairs:   CellKind{OnEnter: enterStairs, OnEnterState: planStairs, ASCII: "S", SpriteName: "cells/cell_stairs.png"},
+	}
+}
+
+// RegisterCell sets the behaviour of a cell type, replacing any before it.
+func RegisterCell(t maze.CellType, b CellBehavior) {
+	cells[t] = b
+}
+
+// CellBehaviorOf returns the behaviour of a cell type. Types nobody
+// registered behave like empty cells.
+func CellBehaviorOf(t maze.CellType) CellBehavior {
+	if b, ok := cells[t]; ok {
+		return b
+	}
+	return cells[maze.Empty]
+}
+
+// CellTypes lists the cell types with a registered behaviour.
+func CellTypes() []maze.CellType {
+	types := make([]maze.CellType, 0, len(cells))
+	for t := range cells {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+// enterHole drops the player to the floor below when holes drop, where the
+// cell they land on takes effect, and otherwise sends them to the next hole.
+func enterHole(g *Game, p *Player, cell *maze.Cell) []Event {
+	if r, c, ok := g.Maze.DropTo(p.Row, p.Col); ok {
+		p.Row, p.Col = r, c
+		below := g.Maze.Grid[r][c]
+		events := []Event{{Kind: EventDropped, Row: r, Col: c, Cell: below.Type, Floor: g.Maze.FloorOf(r)}}
+		return append(events, CellBehaviorOf(below.Type).Enter(g, p, below)...)
+	}
+	if g.teleportPlayerFromHole(p) {
+		return []Event{{Kind: EventTeleported, Row: p.Row, Col: p.Col}}
+	}
+	return nil
+}
+
+// bumpHole lets a player climb out of a hole into the next one by walking
+// into a wall.
+func bumpHole(g *Game, p *Player, cell *maze.Cell, dir maze.Direction) ([]Event, bool) {
+	return enterHole(g, p, cell), true
+}
+
+func enterRiver(g *Game, p *Player, cell *maze.Cell) []Event {
+	return []Event{g.moveAlongRiver(p)}
+}
+
+// bumpRiver lets the river carry a player who walked into a wall.
+func bumpRiver(g *Game, p *Player, cell *maze.Cell, dir maze.Direction) ([]Event, bool) {
+	return enterRiver(g, p, cell), true
+}
+
+func enterStairs(g *Game, p *Player, cell *maze.Cell) []Event {
+	r, c, ok := g.Maze.StairsTo(p.Row, p.Col)
+	if !ok {
+		return nil
+	}
+	dir := maze.Down
+	if r < p.Row {
+		dir = maze.Up
+	}
+	p.Row, p.Col = r, c
+	return []Event{{Kind: EventClimbed, Row: r, Col: c, Dir: dir, Floor: g.Maze.FloorOf(r)}}
+}
+
+func enterExit(g *Game, p *Player, cell *maze.Cell) []Event {
+	switch {
+	case cell.Key > 0 && !p.Keys.Has(cell.Key):
+		return []Event{{Kind: EventExitLocked, Row: p.Row, Col: p.Col, Key: cell.Key}}
+	case p.HasTreasure && !p.Hurt:
+		return []Event{{Kind: EventWin, Row: p.Row, Col: p.Col}}
+	}
+	return []Event{{Kind: EventExitDenied, Row: p.Row, Col: p.Col}}
+}
+
+func enterDragon(g *Game, p *Player, cell *maze.Cell) []Event {
+	events := []Event{{Kind: EventDamage, Row: p.Row, Col: p.Col, Changed: !p.Hurt}}
+	p.Hurt = true
+	if p.HasTreasure {
+		g.Maze.TreasureRow = g.Maze.TreasureStartRow
+		g.Maze.TreasureCol = g.Maze.TreasureStartCol
+		g.Maze.TreasureOnMap = true
+		p.HasTreasure = false
+		events = append(events, Event{Kind: EventTreasureLost, Row: p.Row, Col: p.Col, Item: ItemTreasure, Changed: true})
+	}
+	return events
+}
+
+func enterHospital(g *Game, p *Player, cell *maze.Cell) []Event {
+	e := Event{Kind: EventHealed, Row: p.Row, Col: p.Col, Changed: p.Hurt}
+	p.Hurt = false
+	return []Event{e}
+}
+
+func enterArmory(g *Game, p *Player, cell *maze.Cell) []Event {
+	e := Event{Kind: EventPickup, Row: p.Row, Col: p.Col, Item: ItemBullet, Changed: !p.Bullet}
+	p.Bullet = true
+	return []Event{e}
+}
+
+func enterKey(g *Game, p *Player, cell *maze.Cell) []Event {
+	e := Event{Kind: EventPickup, Row: p.Row, Col: p.Col, Item: ItemKey, Key: cell.Key, Changed: !p.Keys.Has(cell.Key)}
+	p.Keys = p.Keys.With(cell.Key)
+	return []Event{e}
+}
+
+// The plan functions are the solver's side of the enter and bump functions
+// above.
+
+func planHole(s *Solver, st PlayerState, cell *maze.Cell) PlayerState {
+	return s.hole(st)
+}
+
+func planBumpHole(s *Solver, st PlayerState, cell *maze.Cell, dir maze.Direction) (PlayerState, bool) {
+	return s.hole(st), true
+}
+
+func planRiver(s *Solver, st PlayerState, cell *maze.Cell) PlayerState {
+	return s.riverPush(st)
+}
+
+func planBumpRiver(s *Solver, st PlayerState, cell *maze.Cell, dir maze.Direction) (PlayerState, bool) {
+	return s.riverPush(st), true
+}
+
+func planStairs(s *Solver, st PlayerState, cell *maze.Cell) PlayerState {
+	if r, c, ok := s.Maze.StairsTo(st.Row, st.Col); ok {
+		st.Row, st.Col = r, c
+	}
+	return st
+}
+
+func planDragon(s *Solver, st PlayerState, cell *maze.Cell) PlayerState {
+	return s.burn(st)
+}
+
+func planHospital(s *Solver, st PlayerState, cell *maze.Cell) PlayerState {
+	st.Hurt = false
+	return st
+}
+
+func planArmory(s *Solver, st PlayerState, cell *maze.Cell) PlayerState {
+	st.Bullet = true
+	return st
+}
+
+func planKey(s *Solver, st PlayerState, cell *maze.Cell) PlayerState {
+	st.Keys = st.Keys.With(cell.Key)
+	return st
+}
@@ -1,11 +1,12 @@
 package game
 
 import (
-	"encoding/gob"
+	"context"
+	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	"maze-game/maze"
 	"maze-game/mazegen"
@@ -18,11 +19,26 @@ type Game struct {
 	ShowVisibilityMessages bool
 	RiverMoveLength        int
 	MoveHistory            []string
+	Phase                  Phase
+	Turn                   int
+	Winner                 string
+	EndReason              string
+	Seed                   int64
+	Starts                 []maze.PlayerStart // where each player began, for replays
+	Monsters               []*Monster
+	Log                    []LogEntry
+	RNG                    RNG       // random numbers drawn during play
+	EndRules               []EndRule // the saved part of endConditions
+	endConditions          []EndCondition
+	knowledge              map[string]*Knowledge // each player's notes, see Knowledge
+	timeline               *Timeline
+	subscribers            map[int]func(LogEntry)
+	nextSubscriber         int
 }
 
-func NewGame() *Game {
-	var size int = 7
-	cfg := mazegen.MazeConfig{
+// DefaultConfig is the maze configuration games use unless told otherwise.
+func DefaultConfig(size int) mazegen.MazeConfig {
+	return mazegen.MazeConfig{
 		Size:                    size,
 		NumHoles:                2,
 		NumArmories:             1,
@@ -32,345 +48,375 @@ func NewGame() *Game {
 		ExtraOpenings:           0,
 		MinTreasureExitDistance: size - 2,
 	}
+}
 
-	var m *maze.Maze
-	var players []*Player
+func NewGame() (*Game, error) {
+	return NewGameFromConfig(context.Background(), DefaultConfig(7), 2, []string{"P1", "P2"})
+}
 
-	for {
-		m = mazegen.GenerateMaze(cfg)
-		players = PlacePlayers(m, 2)
+func NewGameWithConfig(ctx context.Context, size, holes, riverLength, riverPush int, topology string, names []string) (*Game, error) {
+	cfg := DefaultConfig(size)
+	cfg.Topology = topology
+	cfg.NumHoles = holes
+	cfg.RiverLength = riverLength
+	cfg.ExtraOpenings = extraOpenings(size)
+	return NewGameFromConfig(ctx, cfg, riverPush, names)
+}
 
-		if AllPlayersCanReachTreasureAndExit(m, players) && CanReachTreasureFromEstuary(m, m.TreasureRow, m.TreasureCol) && HospitalReachableFromExit(m) {
-			break
-		}
+// extraOpenings is how many walls NewGameWithConfig knocks down: 5 in 12 of
+// the (size-1)^2 inner walls a perfect square maze keeps, so 15 on a 7x7
+// board, and few enough for the smallest boards.
+func extraOpenings(size int) int {
+	return (size - 1) * (size - 1) * 5 / 12
+}
+
+// NewGameFromConfig generates mazes from cfg until one passes validation and
+// places the named players on it. The maze and starting positions depend only
+// on cfg (including cfg.Seed) and names.
+//
+// It gives up after cfg.MaxAttempts candidates or when ctx is done, and fails
+// at once if the config can never produce a valid maze. The error names the
+// constraint that failed.
+func NewGameFromConfig(ctx context.Context, cfg mazegen.MazeConfig, riverPush int, names []string) (*Game, error) {
+	if err := CheckNames(names); err != nil {
+		return nil, err
+	}
+	rng, seed := mazegen.NewRand(cfg.Seed)
+	attempts := cfg.MaxAttempts
+	if attempts <= 0 {
+		attempts = mazegen.DefaultMaxAttempts
 	}
 
-	return &Game{
-		Maze:                   m,
-		Players:                players,
-		current:                0,
-		ShowVisibilityMessages: true,
-		RiverMoveLength:        2,
+	var lastErr error
+	for i := 0; i < attempts; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("maze generation stopped after %d attempts: %w", i, err)
+		}
+
+		m, err := mazegen.GenerateMazeWithRand(cfg, rng)
+		if errors.Is(err, mazegen.ErrConfig) {
+			return nil, err
+		} else if err != nil {
+			lastErr = err
+			continue
+		}
+
+		players, err := PlacePlayersByName(m, names, rng)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := checkSetup(m, players, riverPush); err != nil {
+			lastErr = err
+			continue
+		}
+
+		g := &Game{
+			Maze:                   m,
+			Players:                players,
+			current:                0,
+			ShowVisibilityMessages: true,
+			RiverMoveLength:        riverPush,
+			Seed:                   seed,
+			Starts:                 startsOf(players),
+			RNG:                    NewRNG(seed),
+		}
+		if err := g.Start(); err != nil {
+			return nil, err
+		}
+		return g, nil
 	}
+
+	return nil, fmt.Errorf("no valid maze after %d attempts, last failure: %w", attempts, lastErr)
 }
 
-func NewGameWithConfig(size, holes, riverLength, riverPush int, names []string) *Game {
-	cfg := mazegen.MazeConfig{
-		Size:                    size,
-		NumHoles:                holes,
-		NumArmories:             1,
-		NumHospitals:            1,
-		NumDragons:              1,
-		RiverLength:             riverLength,
-		ExtraOpenings:           15,
-		MinTreasureExitDistance: size - 2,
+// CheckNames reports why names cannot be the players of a game: there must be
+// at least one, none blank and no two the same, as players are known by name.
+func CheckNames(names []string) error {
+	if len(names) == 0 {
+		return fmt.Errorf("a game needs at least one player")
 	}
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("player names cannot be blank")
+		}
+		if seen[name] {
+			return fmt.Errorf("duplicate player %q", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
 
-	var m *maze.Maze
-	var players []*Player
-
-	for {
-		m = mazegen.GenerateMaze(cfg)
-		players = PlacePlayersByName(m, names)
+// NewGameFromMaze starts a game on a ready-made maze, such as one read with
+// maze.ParseText. Players are created in the order of starts, and each must be
+// able to win from its start.
+func NewGameFromMaze(m *maze.Maze, starts []maze.PlayerStart, riverPush int) (*Game, error) {
+	if len(starts) == 0 {
+		return nil, fmt.Errorf("maze has no player starts")
+	}
+	ids := make([]string, len(starts))
+	for i, s := range starts {
+		ids[i] = s.ID
+	}
+	if err := CheckNames(ids); err != nil {
+		return nil, err
+	}
+	if _, _, found := maze.FindExit(m); !found {
+		return nil, fmt.Errorf("maze has no exit")
+	}
 
-		if AllPlayersCanReachTreasureAndExit(m, players) &&
-			CanReachTreasureFromEstuary(m, m.TreasureRow, m.TreasureCol) &&
-			HospitalReachableFromExit(m) {
-			break
+	players := make([]*Player, len(starts))
+	solver := NewSolver(m, riverPush)
+	for i, s := range starts {
+		if !m.InBounds(s.Row, s.Col) {
+			return nil, fmt.Errorf("start of %s at (%d,%d) is not a playable cell", s.ID, s.Row, s.Col)
+		}
+		players[i] = &Player{ID: s.ID, Row: s.Row, Col: s.Col, Bullet: true}
+		if _, ok := solver.PathToWin(stateOf(players[i])); !ok {
+			return nil, fmt.Errorf("player %s cannot reach the treasure and the exit", s.ID)
 		}
 	}
 
-	return &Game{
+	g := &Game{
 		Maze:                   m,
 		Players:                players,
 		current:                0,
 		ShowVisibilityMessages: true,
 		RiverMoveLength:        riverPush,
+		Starts:                 append([]maze.PlayerStart(nil), starts...),
+		RNG:                    NewRNG(0),
+	}
+	if err := g.Start(); err != nil {
+		return nil, err
 	}
+	return g, nil
 }
 
-func (g *Game) PerformAction(cmd string) (string, string) {
+func (g *Game) PerformAction(cmd string) (Outcome, error) {
+	if g.Phase != PhaseInProgress {
+		return Outcome{Command: cmd}, &PhaseError{Phase: g.Phase}
+	}
 	cmd = strings.ToUpper(cmd)
 
-	var res string
-	var usedTurn bool
 	p := g.CurrentPlayer()
+	notes := g.knowledgeOf(p)
+	// Only actions that use a turn are recorded, so only they need the state
+	// they were played on
+	var before *Game
+	if g.usesTurn(p, cmd) {
+		before = g.snapshot()
+	}
+	entry := LogEntry{Turn: g.Turn, PlayerID: p.ID, Action: cmd, Time: time.Now(), RNGState: g.RNG.State}
+	out := Outcome{
+		PlayerID: p.ID,
+		Command:  cmd,
+		Before:   stateOf(p),
+	}
 
 	switch {
-	case cmd == "UP" || cmd == "DOWN" || cmd == "LEFT" || cmd == "RIGHT":
-		res = g.moveCurrentPlayerInDirection(cmd)
-		usedTurn = true
+	case g.parseDirection(cmd) != -1:
+		out.Events = g.moveCurrentPlayerInDirection(cmd)
+		out.UsedTurn = true
+
+	case cmd == "SKIP":
+		out.Events = []Event{{Kind: EventSkipped, Row: p.Row, Col: p.Col}}
+		out.UsedTurn = true
 
 	case strings.HasPrefix(cmd, "SHOOT "):
 		dir := strings.TrimPrefix(cmd, "SHOOT ")
-		res = g.Shoot(dir)
-		if res == "You have no bullets to shoot." || res == "Invalid shooting direction." {
-			usedTurn = false
-		} else {
-			usedTurn = true
+		shot := g.Shoot(dir)
+		out.Events = []Event{shot}
+		if shot.Kind == EventShotHit || shot.Kind == EventShotMiss || shot.Kind == EventShotMonster {
+			out.UsedTurn = true
 
 			// After a valid shot, check if standing on a hole
-			if g.Maze.Grid[p.Row][p.Col].Type == maze.Hole {
-				g.teleportPlayerFromHole(p)
-				res += " You shot and got teleported through the hole!"
+			if cell := g.Maze.Grid[p.Row][p.Col]; cell.Type == maze.Hole {
+				out.Events = append(out.Events, enterHole(g, p, cell)...)
 			}
 		}
 
 	default:
-		return "Unknown command.", g.CurrentPlayer().ID
+		out.Events = []Event{{Kind: EventInvalid, Row: p.Row, Col: p.Col}}
 	}
 
-	if usedTurn {
-		g.NextPlayer()
+	out.After = stateOf(p)
+	notes.learn(out, g.Turn)
+
+	if out.UsedTurn {
+		g.Turn++
 		g.MoveHistory = append(g.MoveHistory, cmd)
+		if out.Won() {
+			g.Finish(p.ID, "escaped with the treasure")
+		} else {
+			g.checkEndConditions()
+		}
+		if !g.IsOver() {
+			prev := g.current
+			g.NextPlayer()
+			if g.current <= prev {
+				out.Events = append(out.Events, g.endRound()...)
+				out.After = stateOf(p)
+			}
+		}
 	}
 
-	return res, g.CurrentPlayer().ID
+	out.NextPlayer = g.CurrentPlayer().ID
+	entry.Outcome = out
+	g.Log = append(g.Log, entry)
+	if out.UsedTurn {
+		if g.timeline == nil {
+			g.timeline = newTimeline(before)
+		}
+		g.record(cmd, before)
+	}
+	g.publish(entry)
+	return out, nil
 }
 
-func (g *Game) moveCurrentPlayerInDirection(dirStr string) string {
-	dir := parseDirection(dirStr)
+func (g *Game) moveCurrentPlayerInDirection(dirStr string) []Event {
+	dir := g.parseDirection(dirStr)
+	p := g.CurrentPlayer()
 	if dir == -1 {
-		return "Invalid direction."
+		return []Event{{Kind: EventInvalid, Row: p.Row, Col: p.Col}}
 	}
 
-	p := g.CurrentPlayer()
 	cell := g.Maze.Grid[p.Row][p.Col]
 
-	if cell.Type == maze.River {
+	if cell.Type == maze.River || cell.Type == maze.Waterfall {
 		p.LastRiverDir = cell.RiverDir
 	} else {
 		p.LastRiverDir = maze.None
 	}
 
-	status := p.ID + ": "
-	var treasure string
-
-	// Handle wall
-	if cell.Walls[dir] {
-		// Hole teleportation
-		switch cell.Type {
-		case maze.Hole:
-			g.teleportPlayerFromHole(p)
-			status += "You hit a wall and got teleported through the hole! "
-		case maze.River:
-			status += "You hit a wall but the river pushes you! "
-			status += g.moveAlongRiver(p)
-		default:
+	var events []Event
+
+	// Handle wall; a door is a wall unless the player holds its key
+	door := cell.Door(dir)
+	if cell.Walls[dir] && !p.Keys.Has(door) {
+		if door > 0 {
+			events = append(events, Event{Kind: EventLocked, Row: p.Row, Col: p.Col, Dir: dir, Key: door})
+		} else {
+			events = append(events, Event{Kind: EventBlocked, Row: p.Row, Col: p.Col, Dir: dir})
+		}
+		bumped, carryOn := CellBehaviorOf(cell.Type).BumpWall(g, p, cell, dir)
+		events = append(events, bumped...)
+		if !carryOn {
 			// Plain wall hit — return early with visibility from current position
-			vis := g.computeVisibilityMessages(p)
-			return status + "You hit a wall. " + strings.Join(vis, " ")
+			return append(events, g.computeVisibility(p)...)
 		}
 	} else {
 		// Valid move
-		nr, nc := maze.Neighbor(p.Row, p.Col, dir)
+		if door > 0 {
+			events = append(events, Event{Kind: EventUnlocked, Row: p.Row, Col: p.Col, Dir: dir, Key: door})
+		}
+		nr, nc := g.Maze.Neighbor(p.Row, p.Col, dir)
 		if !g.Maze.InBounds(nr, nc) {
-			vis := g.computeVisibilityMessages(p)
-			return status + "Out of bounds. " + strings.Join(vis, " ")
+			events = append(events, Event{Kind: EventBlocked, Row: p.Row, Col: p.Col, Dir: dir})
+			return append(events, g.computeVisibility(p)...)
 		}
 
 		target := g.Maze.Grid[nr][nc]
 		p.Row, p.Col = nr, nc
+		events = append(events, Event{Kind: EventMoved, Row: nr, Col: nc, Cell: target.Type, Dir: dir, Flow: p.LastRiverDir})
 
-		switch target.Type {
-		case maze.Exit:
-			if p.HasTreasure && !p.Hurt {
-				status += "You reached the exit with the treasure. You win!"
-			} else if p.Hurt {
-				status += "You reached the exit but you're hurt and can't escape. Go to a hospital first."
-			} else {
-				status += "You reached the exit but don't have the treasure."
-			}
-		case maze.Hole:
-			g.teleportPlayerFromHole(p)
-			status += "You fell into a hole and got teleported!"
-		case maze.Dragon:
-			if p.Hurt {
-				status += "The dragon burned you. You're still hurt."
-			} else {
-				p.Hurt = true
-				status += "The dragon burned you. You're hurt now."
-			}
-			if p.HasTreasure {
-				g.Maze.TreasureRow = g.Maze.TreasureStartRow
-				g.Maze.TreasureCol = g.Maze.TreasureStartCol
-				g.Maze.TreasureOnMap = true
-				p.HasTreasure = false
-				status += " You lost the treasure and it was returned to its starting position."
-			}
-		case maze.Hospital:
-			if p.Hurt {
-				p.Hurt = false
-				status += "You reached the hospital and are healed!"
-			} else {
-				status += "You visited the hospital, but you're already fine."
-			}
-		case maze.Armory:
-			if p.Bullet {
-				status += "You found an armory but already had a bullet!"
-			} else {
-				p.Bullet = true
-				status += "You found an armory and received a bullet!"
-			}
-		case maze.River:
-
-			if p.LastRiverDir != maze.None {
-				if dir == p.LastRiverDir {
-					status += " You moved down the river. "
-				} else if dir == maze.Opposite(p.LastRiverDir) {
-					status += " You moved up the river. "
-				}
-			} else {
-				status += "You stepped into a river. "
-			}
+		events = append(events, CellBehaviorOf(target.Type).Enter(g, p, target)...)
+	}
 
-			status += g.moveAlongRiver(p)
-		case maze.Estuary:
-			status += "You stepped directly on the estuary."
-		default:
-			status += "You moved successfully."
+	// A monster on the cell catches the player
+	if m := g.monsterAt(p.Row, p.Col); m != nil && m.Active() {
+		events = append(events, g.monsterAttack(m, p)...)
+		if p.Eliminated {
+			return events
 		}
 	}
 
 	// Check treasure
+	var treasure []Event
 	if g.Maze.TreasureOnMap && p.Row == g.Maze.TreasureRow && p.Col == g.Maze.TreasureCol {
-		if p.Hurt {
-			treasure += " You found the treasure but can't pick it up because you are hurt!"
-		} else {
-			treasure += " You found the treasure!"
+		treasure = append(treasure, Event{Kind: EventPickup, Row: p.Row, Col: p.Col, Item: ItemTreasure, Changed: !p.Hurt})
+		if !p.Hurt {
 			p.HasTreasure = true
 			g.Maze.TreasureOnMap = false
 		}
 	}
 
 	// Recompute visibility from final position
-	visibilityMsgs := g.computeVisibilityMessages(p)
+	events = append(events, g.computeVisibility(p)...)
 
-	if len(visibilityMsgs) > 0 {
-		status += " " + strings.Join(visibilityMsgs, " ")
-	}
-	return status + treasure
+	return append(events, treasure...)
 }
 
-func (g *Game) computeVisibilityMessages(p *Player) []string {
-	var msgs []string
+func (g *Game) computeVisibility(p *Player) []Event {
+	var events []Event
+	lines := g.Maze.Topology().Directions()
 
 	// Dragon visibility
-	for r := p.Row - 1; r >= 0; r-- {
-		if g.Maze.Grid[r+1][p.Col].Walls[maze.Up] {
-			break
-		}
-		if g.Maze.Grid[r][p.Col].Type == maze.Dragon {
-			msgs = append(msgs, "The dragon sees you!")
-			break
+	for _, d := range lines {
+		if r, c, ok := g.look(p.Row, p.Col, d, func(r, c int) bool { return g.Maze.Grid[r][c].Type == maze.Dragon }); ok {
+			events = append(events, Event{Kind: EventDragonSeen, Row: r, Col: c})
 		}
 	}
-	for r := p.Row + 1; r < g.Maze.Size; r++ {
-		if g.Maze.Grid[r-1][p.Col].Walls[maze.Down] {
-			break
-		}
-		if g.Maze.Grid[r][p.Col].Type == maze.Dragon {
-			msgs = append(msgs, "The dragon sees you!")
-			break
-		}
-	}
-	for c := p.Col - 1; c >= 0; c-- {
-		if g.Maze.Grid[p.Row][c+1].Walls[maze.Left] {
-			break
-		}
-		if g.Maze.Grid[p.Row][c].Type == maze.Dragon {
-			msgs = append(msgs, "The dragon sees you!")
-			break
-		}
-	}
-	for c := p.Col + 1; c < g.Maze.Size; c++ {
-		if g.Maze.Grid[p.Row][c-1].Walls[maze.Right] {
-			break
-		}
-		if g.Maze.Grid[p.Row][c].Type == maze.Dragon {
-			msgs = append(msgs, "The dragon sees you!")
-			break
+	for _, d := range lines {
+		if r, c, ok := g.look(p.Row, p.Col, d, func(r, c int) bool { return g.monsterAt(r, c) != nil }); ok {
+			events = append(events, Event{Kind: EventMonsterSeen, Row: r, Col: c, Monster: g.monsterAt(r, c).ID})
 		}
 	}
 
 	if g.ShowVisibilityMessages && g.Maze.TreasureOnMap {
-		if p.Col == g.Maze.TreasureCol {
-			for r := p.Row - 1; r >= 0; r-- {
-				if g.Maze.Grid[r+1][p.Col].Walls[maze.Up] {
-					break
-				}
-				if r == g.Maze.TreasureRow {
-					msgs = append(msgs, "You see the treasure!")
-					break
-				}
-			}
-			for r := p.Row + 1; r < g.Maze.Size; r++ {
-				if g.Maze.Grid[r-1][p.Col].Walls[maze.Down] {
-					break
-				}
-				if r == g.Maze.TreasureRow {
-					msgs = append(msgs, "You see the treasure!")
-					break
-				}
-			}
-		}
-		if p.Row == g.Maze.TreasureRow {
-			for c := p.Col - 1; c >= 0; c-- {
-				if g.Maze.Grid[p.Row][c+1].Walls[maze.Left] {
-					break
-				}
-				if c == g.Maze.TreasureCol {
-					msgs = append(msgs, "You see the treasure!")
-					break
-				}
-			}
-			for c := p.Col + 1; c < g.Maze.Size; c++ {
-				if g.Maze.Grid[p.Row][c-1].Walls[maze.Right] {
-					break
-				}
-				if c == g.Maze.TreasureCol {
-					msgs = append(msgs, "You see the treasure!")
-					break
-				}
+		tr, tc := g.Maze.TreasureRow, g.Maze.TreasureCol
+		for _, d := range lines {
+			if _, _, ok := g.look(p.Row, p.Col, d, func(r, c int) bool { return r == tr && c == tc }); ok {
+				events = append(events, Event{Kind: EventTreasureSeen, Row: tr, Col: tc})
 			}
 		}
 	}
 
-	return msgs
+	return events
 }
 
-func (g *Game) moveAlongRiver(p *Player) string {
-	for i := 0; i < g.RiverMoveLength; i++ {
-		cell := g.Maze.Grid[p.Row][p.Col]
-		if cell.Type == maze.Estuary {
-			return "You arrived at the estuary."
-		}
-		dir := cell.RiverDir
-		if cell.Walls[dir] {
+// moveAlongRiver pushes the player downstream and reports where they ended
+// up. The event's Cell is Estuary or Lake when the push ended on one.
+func (g *Game) moveAlongRiver(p *Player) Event {
+	p.Row, p.Col = downstream(g.Maze, p.Row, p.Col, g.RiverMoveLength)
+	return Event{Kind: EventRiverPush, Row: p.Row, Col: p.Col, Cell: g.Maze.Grid[p.Row][p.Col].Type}
+}
+
+// downstream returns where a river push from (r, c) ends: length cells along
+// the flow, not counting those a waterfall carries you out of, or sooner on
+// an estuary, a lake or a wall.
+func downstream(m *maze.Maze, r, c, length int) (int, int) {
+	// A river drawn by hand may flow in a circle, so no push goes on for
+	// longer than it takes to cross every cell
+	for i, steps := 0, 0; i < length && steps < m.Rows*m.Cols; steps++ {
+		cell := m.Grid[r][c]
+		if !cell.Type.Flows() || cell.Type == maze.Estuary || cell.Walls[cell.RiverDir] {
 			break
 		}
-		nr, nc := maze.Neighbor(p.Row, p.Col, dir)
-		if !g.Maze.InBounds(nr, nc) {
+		nr, nc := m.Neighbor(r, c, cell.RiverDir)
+		if !m.InBounds(nr, nc) {
 			break
 		}
-		p.Row, p.Col = nr, nc
-		cell = g.Maze.Grid[p.Row][p.Col]
-		if cell.Type == maze.Estuary {
-			return "You arrived at the estuary."
+		if cell.Type != maze.Waterfall {
+			i++
 		}
+		r, c = nr, nc
 	}
-	return ""
+	return r, c
 }
-func (g *Game) teleportPlayerFromHole(p *Player) {
-	size := g.Maze.Size
+
+// teleportPlayerFromHole moves the player to the next hole and reports
+// whether they moved. Holes that drop to the floor below are left out.
+func (g *Game) teleportPlayerFromHole(p *Player) bool {
 	r0, c0 := p.Row, p.Col
 
 	// Collect all hole positions
 	type pos struct{ r, c int }
 	var holes []pos
-	for r := 0; r < size; r++ {
-		for c := 0; c < size; c++ {
-			if g.Maze.Grid[r][c].Type == maze.Hole {
+	for r := 0; r < g.Maze.Rows; r++ {
+		for c := 0; c < g.Maze.Cols; c++ {
+			if _, _, drops := g.Maze.DropTo(r, c); g.Maze.Grid[r][c].Type == maze.Hole && !drops {
 				holes = append(holes, pos{r, c})
 			}
 		}
@@ -378,7 +424,7 @@ func (g *Game) teleportPlayerFromHole(p *Player) {
 
 	// If less than 2 holes, do nothing
 	if len(holes) < 2 {
-		return
+		return false
 	}
 
 	// Find index of current hole
@@ -391,23 +437,34 @@ func (g *Game) teleportPlayerFromHole(p *Player) {
 	}
 
 	if current == -1 {
-		return // not currently on a hole (shouldn't happen)
+		return false // not currently on a hole (shouldn't happen)
 	}
 
 	// Teleport to next hole (clockwise in list)
 	next := (current + 1) % len(holes)
 	p.Row, p.Col = holes[next].r, holes[next].c
+	return true
 }
 
-func (g *Game) Shoot(dirStr string) string {
-	dir := parseDirection(dirStr)
-	if dir == -1 {
-		return "Invalid shooting direction."
+// usesTurn reports whether p playing cmd will use their turn: moves and
+// skips always do, shots when there is a direction and a bullet.
+func (g *Game) usesTurn(p *Player, cmd string) bool {
+	if g.parseDirection(cmd) != -1 || cmd == "SKIP" {
+		return true
 	}
+	dir, ok := strings.CutPrefix(cmd, "SHOOT ")
+	return ok && g.parseDirection(dir) != -1 && p.Bullet
+}
 
+func (g *Game) Shoot(dirStr string) Event {
 	shooter := g.CurrentPlayer()
+	dir := g.parseDirection(dirStr)
+	if dir == -1 {
+		return Event{Kind: EventInvalid, Row: shooter.Row, Col: shooter.Col}
+	}
+
 	if !shooter.Bullet {
-		return "You have no bullets to shoot."
+		return Event{Kind: EventNoBullet, Row: shooter.Row, Col: shooter.Col, Dir: dir}
 	}
 
 	r, c := shooter.Row, shooter.Col
@@ -417,34 +474,45 @@ func (g *Game) Shoot(dirStr string) string {
 		// Check if wall blocks shooting out of current cell
 		if m.Grid[r][c].Walls[dir] {
 			shooter.Bullet = false
-			return "Your bullet hit a wall and stopped."
+			return Event{Kind: EventShotMiss, Row: r, Col: c, Dir: dir}
 		}
 
-		// Move to next cell in direction
-		nr, nc := maze.Neighbor(r, c, dir)
-		if !m.InBounds(nr, nc) {
+		// Move to next cell in direction; in a wrapped maze a bullet that
+		// comes all the way round falls short of the shooter
+		nr, nc := m.Neighbor(r, c, dir)
+		if !m.InBounds(nr, nc) || nr == shooter.Row && nc == shooter.Col {
 			shooter.Bullet = false
-			return "Your bullet flew out of bounds."
+			return Event{Kind: EventShotMiss, Row: r, Col: c, Dir: dir}
 		}
 
 		// Check if a player is in the next cell
 		for _, p := range g.Players {
-			if p.Row == nr && p.Col == nc {
+			if !p.Eliminated && p.Row == nr && p.Col == nc {
 				// Hit player
 				p.Hurt = true
+				shooter.Bullet = false
+				hit := Event{Kind: EventShotHit, Row: nr, Col: nc, Dir: dir, Target: p.ID}
 
 				if p.HasTreasure {
 					p.HasTreasure = false
 					m.TreasureRow = p.Row
 					m.TreasureCol = p.Col
 					m.TreasureOnMap = true
-					shooter.Bullet = false
-					return fmt.Sprintf("You shot player %s! They are now hurt and dropped the treasure.", p.ID)
-				} else {
-					shooter.Bullet = false
-					return fmt.Sprintf("You shot player %s! They are now hurt.", p.ID)
+					hit.Item = ItemTreasure
+					hit.Changed = true
 				}
+				return hit
+			}
+		}
+
+		if mon := g.monsterAt(nr, nc); mon != nil {
+			shooter.Bullet = false
+			if mon.Stun == 0 {
+				mon.Dead = true
+			} else {
+				mon.Stunned = mon.Stun
 			}
+			return Event{Kind: EventShotMonster, Row: nr, Col: nc, Dir: dir, Monster: mon.ID, Changed: mon.Dead}
 		}
 
 		// No player hit, continue to next cell
@@ -452,27 +520,48 @@ func (g *Game) Shoot(dirStr string) string {
 	}
 }
 
-func parseDirection(input string) maze.Direction {
-	switch strings.ToUpper(input) {
-	case "UP":
-		return maze.Up
-	case "DOWN":
-		return maze.Down
-	case "LEFT":
-		return maze.Left
-	case "RIGHT":
-		return maze.Right
-	default:
+// parseDirection returns the direction a command names, e.g. "UP" or
+// "DOWN-LEFT", or -1 if the maze has no such direction.
+func (g *Game) parseDirection(input string) maze.Direction {
+	d, err := maze.ParseDirection(strings.ToLower(input))
+	if err != nil || !slices.Contains(g.Maze.Topology().Directions(), d) {
 		return -1
 	}
+	return d
+}
+
+// IsDirection reports whether s names a direction players can move and
+// shoot in on this maze.
+func (g *Game) IsDirection(s string) bool {
+	return g.parseDirection(s) != -1
+}
+
+// MoveCommands lists the commands that move a player on this maze.
+func (g *Game) MoveCommands() []string {
+	var cmds []string
+	for _, d := range g.Maze.Topology().Directions() {
+		cmds = append(cmds, Command(d))
+	}
+	return cmds
+}
+
+// Command returns the command that moves in direction d.
+func Command(d maze.Direction) string {
+	return strings.ToUpper(d.String())
 }
 
 func (g *Game) CurrentPlayer() *Player {
 	return g.Players[g.current]
 }
 
+// NextPlayer advances the turn to the next player who is still in the game.
 func (g *Game) NextPlayer() {
-	g.current = (g.current + 1) % len(g.Players)
+	for i := 0; i < len(g.Players); i++ {
+		g.current = (g.current + 1) % len(g.Players)
+		if !g.Players[g.current].Eliminated {
+			return
+		}
+	}
 }
 
 func (g *Game) GetMaze() *maze.Maze {
@@ -487,44 +576,19 @@ func (g *Game) GetCurrent() int {
 	return g.current
 }
 
-func (g *Game) SaveToFile(filename string) error {
-	// Ensure the save directory exists
-	if err := os.MkdirAll("saved", 0755); err != nil {
-		return err
-	}
-	file, err := os.Create(filepath.Join("saved", filename))
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	encoder := gob.NewEncoder(file)
-	return encoder.Encode(g)
-}
-
-func LoadFromFile(filename string) (*Game, error) {
-	file, err := os.Open(filepath.Join("saved", filename))
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-
-	var g Game
-	decoder := gob.NewDecoder(file)
-	err = decoder.Decode(&g)
-	return &g, err
-}
-
 func (g *Game) Copy() *Game {
 	// Deep copy players
 	playersCopy := make([]*Player, len(g.Players))
 	for i, p := range g.Players {
 		playersCopy[i] = &Player{
-			ID:     p.ID,
-			Row:    p.Row,
-			Col:    p.Col,
-			Hurt:   p.Hurt,
-			Bullet: p.Bullet,
+			ID:          p.ID,
+			Row:         p.Row,
+			Col:         p.Col,
+			Hurt:        p.Hurt,
+			HasTreasure: p.HasTreasure,
+			Bullet:      p.Bullet,
+			Eliminated:  p.Eliminated,
+			Keys:        p.Keys,
 		}
 	}
 
@@ -537,5 +601,54 @@ func (g *Game) Copy() *Game {
 		current:                g.current,
 		ShowVisibilityMessages: false, // suppress output during sim
 		RiverMoveLength:        g.RiverMoveLength,
+		Phase:                  g.Phase,
+		Turn:                   g.Turn,
+		Winner:                 g.Winner,
+		EndReason:              g.EndReason,
+		Seed:                   g.Seed,
+		Starts:                 g.Starts,
+		Monsters:               copyMonsters(g.Monsters),
+		RNG:                    g.RNG,
+		EndRules:               g.EndRules,
+		endConditions:          g.endConditions,
+	}
+}
+
+func startsOf(players []*Player) []maze.PlayerStart {
+	starts := make([]maze.PlayerStart, len(players))
+	for i, p := range players {
+		starts[i] = maze.PlayerStart{ID: p.ID, Row: p.Row, Col: p.Col}
+	}
+	return starts
+}
+
+// InitialState rebuilds the game as it was before the first move, so that
+// MoveHistory can be replayed on it.
+func (g *Game) InitialState() (*Game, error) {
+	if len(g.Starts) != len(g.Players) {
+		return nil, fmt.Errorf("game has no recorded starting positions")
+	}
+
+	m := maze.CopyMaze(g.Maze)
+	m.TreasureRow, m.TreasureCol = m.TreasureStartRow, m.TreasureStartCol
+	m.TreasureOnMap = true
+
+	players := make([]*Player, len(g.Starts))
+	for i, s := range g.Starts {
+		players[i] = &Player{ID: s.ID, Row: s.Row, Col: s.Col, Bullet: true}
 	}
+
+	return &Game{
+		Maze:                   m,
+		Players:                players,
+		ShowVisibilityMessages: g.ShowVisibilityMessages,
+		RiverMoveLength:        g.RiverMoveLength,
+		Phase:                  PhaseInProgress,
+		Seed:                   g.Seed,
+		Starts:                 g.Starts,
+		Monsters:               startMonsters(g.Monsters),
+		RNG:                    NewRNG(g.Seed),
+		EndRules:               g.EndRules,
+		endConditions:          g.endConditions,
+	}, nil
 }
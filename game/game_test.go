@@ -0,0 +1,100 @@
+package game
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"maze-game/maze"
+	"maze-game/mazegen"
+)
+
+func newTestGame(t *testing.T, seed int64) *Game {
+	t.Helper()
+	cfg := mazegen.MazeConfig{
+		Size:                    7,
+		NumHoles:                2,
+		NumArmories:             1,
+		NumHospitals:            1,
+		NumDragons:              1,
+		RiverLength:             9,
+		MinTreasureExitDistance: 5,
+		Seed:                    seed,
+	}
+	g, err := NewGameFromConfig(context.Background(), cfg, 2, []string{"P1", "P2"})
+	if err != nil {
+		t.Fatalf("seed %d: %v", seed, err)
+	}
+	return g
+}
+
+func TestSameSeedSameGame(t *testing.T) {
+	for seed := int64(1); seed <= 5; seed++ {
+		a, b := newTestGame(t, seed), newTestGame(t, seed)
+		if a.Seed != seed {
+			t.Errorf("seed %d: game reports seed %d", seed, a.Seed)
+		}
+		mazeA, _ := json.Marshal(a.Maze)
+		mazeB, _ := json.Marshal(b.Maze)
+		if string(mazeA) != string(mazeB) {
+			t.Errorf("seed %d: different mazes", seed)
+		}
+		for i := range a.Players {
+			pa, pb := a.Players[i], b.Players[i]
+			if pa.ID != pb.ID || pa.Row != pb.Row || pa.Col != pb.Col {
+				t.Errorf("seed %d: players start at %+v and %+v", seed, *pa, *pb)
+			}
+		}
+		if a.RNG != b.RNG {
+			t.Errorf("seed %d: play RNG %v and %v", seed, a.RNG, b.RNG)
+		}
+	}
+}
+
+func TestDifferentSeedsDifferentGames(t *testing.T) {
+	seen := map[string]bool{}
+	for seed := int64(1); seed <= 5; seed++ {
+		data, _ := json.Marshal(newTestGame(t, seed).Maze)
+		seen[string(data)] = true
+	}
+	if len(seen) != 5 {
+		t.Errorf("5 seeds gave %d different mazes", len(seen))
+	}
+}
+
+func TestNewGameRejectsBadNames(t *testing.T) {
+	m, _, err := maze.ParseText(strings.NewReader("size 1 2\n+---+---+\n| .  TE |\n+---+---+\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewGameFromMaze(m, []maze.PlayerStart{{ID: "P1"}}, 2); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		names []string
+		want  string
+	}{
+		{"none", nil, "at least one player"},
+		{"empty", []string{""}, "cannot be blank"},
+		{"blank", []string{"P1", "  "}, "cannot be blank"},
+		{"duplicate", []string{"P1", "P2", "P1"}, "duplicate player"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := NewGameFromConfig(context.Background(), DefaultConfig(5), 2, tt.names)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("game %v, error %v, want one containing %q", g, err, tt.want)
+			}
+
+			starts := make([]maze.PlayerStart, len(tt.names))
+			for i, name := range tt.names {
+				starts[i] = maze.PlayerStart{ID: name}
+			}
+			if _, err := NewGameFromMaze(m, starts, 2); err == nil {
+				t.Errorf("game from a maze started with %q", tt.names)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package game
+
+import (
+	"strconv"
+	"strings"
+
+	"maze-game/maze"
+)
+
+// KeySet is the set of keys a player holds, by number from 1 to maze.MaxKey.
+type KeySet uint16
+
+// Has reports whether the set holds the key; it never holds key 0.
+func (s KeySet) Has(key int) bool {
+	return key > 0 && s&(1<<(key-1)) != 0
+}
+
+// With returns the set with the key added.
+func (s KeySet) With(key int) KeySet {
+	if key <= 0 {
+		return s
+	}
+	return s | 1<<(key-1)
+}
+
+// Keys lists the key numbers in the set in order.
+func (s KeySet) Keys() []int {
+	var keys []int
+	for k := 1; k <= maze.MaxKey; k++ {
+		if s.Has(k) {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+func (s KeySet) String() string {
+	var names []string
+	for _, k := range s.Keys() {
+		names = append(names, strconv.Itoa(k))
+	}
+	return strings.Join(names, ",")
+}
+
+// MazeKeys returns every key lying on the maze.
+func MazeKeys(m *maze.Maze) KeySet {
+	var s KeySet
+	for r := 0; r < m.Rows; r++ {
+		for c := 0; c < m.Cols; c++ {
+			if cell := m.Grid[r][c]; cell.Type == maze.Key {
+				s = s.With(cell.Key)
+			}
+		}
+	}
+	return s
+}
@@ -0,0 +1,207 @@
+package game
+
+import (
+	"fmt"
+	"slices"
+
+	"maze-game/maze"
+)
+
+// WallKnowledge is what a player knows about one side of a cell.
+type WallKnowledge int8
+
+const (
+	WallUnknown WallKnowledge = iota
+	WallOpen
+	WallClosed
+)
+
+// KnownCell is what a player has learned about one cell. Walls are recorded
+// per side, as found from this cell, since walls can be one-way.
+type KnownCell struct {
+	Visited    bool
+	Identified bool // Type is known
+	Type       maze.CellType
+	Walls      [maze.NumDirections]WallKnowledge
+}
+
+// Sighting records where a player stood when told that the dragon, a monster
+// or the treasure was in sight.
+type Sighting struct {
+	Turn     int
+	Row, Col int
+	Kind     EventKind // EventDragonSeen, EventMonsterSeen or EventTreasureSeen
+}
+
+// Knowledge is a player's own map of the maze, the notes they would draw on
+// paper: only what their own actions have told them. It is kept in maze
+// coordinates, so front-ends can draw it like the maze itself.
+type Knowledge struct {
+	Rows, Cols int
+	Floors     int           // as maze.Maze.FloorCount
+	Topology   maze.Topology // of the maze, which players can see
+	Wrap       maze.Wrap     // likewise
+	Cells      [][]KnownCell
+	Sightings  []Sighting
+}
+
+func newKnowledge(m *maze.Maze, startRow, startCol int) *Knowledge {
+	k := blankKnowledge(m)
+	k.identify(startRow, startCol, maze.Empty)
+	return k
+}
+
+// blankKnowledge returns notes on m with nothing in them yet.
+func blankKnowledge(m *maze.Maze) *Knowledge {
+	k := &Knowledge{Rows: m.Rows, Cols: m.Cols, Floors: m.FloorCount(), Topology: m.Topology(), Wrap: m.Wrap, Cells: make([][]KnownCell, m.Rows)}
+	for r := range k.Cells {
+		k.Cells[r] = make([]KnownCell, m.Cols)
+	}
+	return k
+}
+
+func (k *Knowledge) identify(r, c int, t maze.CellType) {
+	cell := &k.Cells[r][c]
+	cell.Visited = true
+	cell.Identified = true
+	cell.Type = t
+}
+
+// Wall reports what is known about the wall on side d of a cell, from either
+// side of it. Where a cell has no side the wall is known to be closed.
+func (k *Knowledge) Wall(r, c int, d maze.Direction) WallKnowledge {
+	if !slices.Contains(k.Topology.Sides(r, c), d) {
+		return WallClosed
+	}
+	w := k.Cells[r][c].Walls[d]
+	nr, nc := k.neighbor(r, c, d)
+	if w == WallClosed || nr < 0 || nr >= k.Rows || nc < 0 || nc >= k.Cols {
+		return w
+	}
+	if k.Cells[nr][nc].Walls[k.Topology.Opposite(d)] == WallClosed {
+		return WallClosed
+	}
+	return w
+}
+
+func (k *Knowledge) neighbor(r, c int, d maze.Direction) (int, int) {
+	dr, dc := k.Topology.Delta(d)
+	return k.Wrap.Fold(r+dr, c+dc, r, k.Rows, k.Cols, k.Floors)
+}
+
+// MaybeDragon reports whether the dragon could be on a cell as far as the
+// player knows: the cell lies in a straight line from a dragon sighting, no
+// wall the player knows of stands between them, and the player has not been
+// on the cell without meeting the dragon.
+func (k *Knowledge) MaybeDragon(r, c int) bool {
+	cell := k.Cells[r][c]
+	if cell.Identified {
+		return cell.Type == maze.Dragon
+	}
+	for _, s := range k.Sightings {
+		if s.Kind != EventDragonSeen || (s.Row == r && s.Col == c) {
+			continue
+		}
+		if k.lineOpen(s.Row, s.Col, r, c) {
+			return true
+		}
+	}
+	return false
+}
+
+// lineOpen reports whether (r1,c1) lies in a straight line from (r0,c0)
+// with no known wall on it.
+func (k *Knowledge) lineOpen(r0, c0, r1, c1 int) bool {
+	for _, d := range k.Topology.Directions() {
+		r, c := r0, c0
+		for {
+			if r == r1 && c == c1 {
+				return true
+			}
+			if k.Wall(r, c, d) == WallClosed {
+				break
+			}
+			r, c = k.neighbor(r, c, d)
+			// Off the edge, or all the way round a wrapped maze
+			if r < 0 || r >= k.Rows || c < 0 || c >= k.Cols || r == r0 && c == c0 {
+				break
+			}
+		}
+	}
+	return false
+}
+
+// learn records what the acting player finds out from an outcome.
+func (k *Knowledge) learn(out Outcome, turn int) {
+	r, c := out.Before.Row, out.Before.Col
+	for _, e := range out.Events {
+		switch e.Kind {
+		case EventMoved:
+			k.Cells[r][c].Walls[e.Dir] = WallOpen
+			r, c = e.Row, e.Col
+			k.identify(r, c, e.Cell)
+		case EventBlocked, EventLocked:
+			k.Cells[e.Row][e.Col].Walls[e.Dir] = WallClosed
+		case EventTeleported:
+			k.identify(r, c, maze.Hole)
+			r, c = e.Row, e.Col
+			k.identify(r, c, maze.Hole)
+		case EventClimbed:
+			k.identify(r, c, maze.Stairs)
+			r, c = e.Row, e.Col
+			k.identify(r, c, maze.Stairs)
+		case EventDropped:
+			k.identify(r, c, maze.Hole)
+			r, c = e.Row, e.Col
+			k.identify(r, c, e.Cell)
+		case EventRiverPush:
+			r, c = e.Row, e.Col
+			k.identify(r, c, e.Cell)
+		case EventDragonSeen, EventMonsterSeen, EventTreasureSeen:
+			k.Sightings = append(k.Sightings, Sighting{Turn: turn, Row: r, Col: c, Kind: e.Kind})
+		}
+	}
+}
+
+// Copy returns a deep copy of the knowledge.
+func (k *Knowledge) Copy() *Knowledge {
+	cp := &Knowledge{
+		Rows:      k.Rows,
+		Cols:      k.Cols,
+		Floors:    k.Floors,
+		Topology:  k.Topology,
+		Wrap:      k.Wrap,
+		Cells:     make([][]KnownCell, len(k.Cells)),
+		Sightings: append([]Sighting(nil), k.Sightings...),
+	}
+	for r := range k.Cells {
+		cp.Cells[r] = append([]KnownCell(nil), k.Cells[r]...)
+	}
+	return cp
+}
+
+// knowledgeOf returns the notes of a player, starting them on the player's
+// current cell the first time.
+func (g *Game) knowledgeOf(p *Player) *Knowledge {
+	if g.knowledge == nil {
+		g.knowledge = map[string]*Knowledge{}
+	}
+	k, ok := g.knowledge[p.ID]
+	if !ok {
+		k = newKnowledge(g.Maze, p.Row, p.Col)
+		g.knowledge[p.ID] = k
+	}
+	return k
+}
+
+// Knowledge returns a copy of what the player with the given ID has learned
+// about the maze. JSON saves keep the notes; a game loaded from a gob save
+// starts them afresh.
+func (g *Game) Knowledge(id string) (*Knowledge, error) {
+	for _, p := range g.Players {
+		if p.ID == id {
+			return g.knowledgeOf(p).Copy(), nil
+		}
+	}
+	return nil, fmt.Errorf("unknown player %q", id)
+}
@@ -0,0 +1,72 @@
+package game
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// LogEntry records one action: who took it, what happened and when. The
+// acting player's position before and after is in Outcome.Before and After.
+type LogEntry struct {
+	Turn     int // the turn the action was taken on
+	PlayerID string
+	Action   string
+	Outcome  Outcome
+	Time     time.Time
+	RNGState uint64 // Game.RNG before the action, to reproduce it
+}
+
+// MarshalJSON writes the entry as in the "log" of the JSON save format.
+func (e LogEntry) MarshalJSON() ([]byte, error) {
+	return json.Marshal(saveLogEntryOf(e))
+}
+
+func (e *LogEntry) UnmarshalJSON(data []byte) error {
+	var in saveLogEntry
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	entry, err := in.entry()
+	if err != nil {
+		return err
+	}
+	*e = entry
+	return nil
+}
+
+// Subscribe calls fn with every log entry from now on, right after the action
+// is resolved, and with an EventTimeline entry after each Undo, Redo and GoTo.
+// The returned function cancels the subscription.
+func (g *Game) Subscribe(fn func(LogEntry)) (cancel func()) {
+	if g.subscribers == nil {
+		g.subscribers = map[int]func(LogEntry){}
+	}
+	id := g.nextSubscriber
+	g.nextSubscriber++
+	g.subscribers[id] = fn
+	return func() { delete(g.subscribers, id) }
+}
+
+// Replace turns g into other, e.g. a game just loaded, keeping g's
+// subscribers so that they carry on with it.
+func (g *Game) Replace(other *Game) {
+	subscribers, next := g.subscribers, g.nextSubscriber
+	*g = *other
+	g.subscribers, g.nextSubscriber = subscribers, next
+}
+
+func (g *Game) publish(e LogEntry) {
+	for _, id := range sortedKeys(g.subscribers) {
+		g.subscribers[id](e)
+	}
+}
+
+func sortedKeys(m map[int]func(LogEntry)) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
@@ -0,0 +1,381 @@
+package game
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"maze-game/maze"
+)
+
+// Damage is what a monster does to a player it catches.
+type Damage int
+
+const (
+	// DamageHurt hurts the player like a dragon cell, and the treasure goes
+	// back to its start
+	DamageHurt Damage = iota
+	// DamageSteal only sends the treasure back to its start
+	DamageSteal
+	// DamageKill eliminates the player
+	DamageKill
+)
+
+var damageNames = map[Damage]string{
+	DamageHurt:  "hurt",
+	DamageSteal: "steal",
+	DamageKill:  "kill",
+}
+
+func (d Damage) String() string {
+	if name, ok := damageNames[d]; ok {
+		return name
+	}
+	return fmt.Sprintf("damage(%d)", int(d))
+}
+
+// Monster is a creature that moves through the maze on its own, once every
+// round after the last player in turn order has acted. Unlike a dragon cell
+// it can be shot.
+type Monster struct {
+	ID       string
+	Row, Col int
+	Behavior string // name of a registered Behavior
+	Damage   Damage
+	// Stun is how many rounds a shot stuns the monster for; 0 means a shot
+	// kills it
+	Stun     int
+	Stunned  int // rounds left stunned
+	Dead     bool
+	Facing   maze.Direction // last direction moved, used by behaviours
+	StartRow int
+	StartCol int
+}
+
+// Active reports whether the monster can move and attack.
+func (m *Monster) Active() bool {
+	return !m.Dead && m.Stunned == 0
+}
+
+// Behavior decides where a monster goes each round. Step returns the
+// direction to move in, or maze.None to stay. It may only draw random numbers
+// from g.RNG, so games stay reproducible.
+type Behavior interface {
+	Step(g *Game, m *Monster) maze.Direction
+}
+
+// BehaviorFunc adapts a function to Behavior.
+type BehaviorFunc func(g *Game, m *Monster) maze.Direction
+
+func (f BehaviorFunc) Step(g *Game, m *Monster) maze.Direction { return f(g, m) }
+
+var behaviors = map[string]Behavior{
+	"static": BehaviorFunc(func(*Game, *Monster) maze.Direction { return maze.None }),
+	"patrol": BehaviorFunc(patrol),
+	"wander": BehaviorFunc(wander),
+	"chase":  BehaviorFunc(chase),
+}
+
+// RegisterBehavior adds a behaviour monsters can be given by name.
+func RegisterBehavior(name string, b Behavior) {
+	behaviors[name] = b
+}
+
+// BehaviorNames lists the registered behaviours.
+func BehaviorNames() []string {
+	names := make([]string, 0, len(behaviors))
+	for name := range behaviors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// patrol follows the wall on its right hand, so it walks the same loop over
+// and over: it tries the sharpest right turn first, round to the sharpest
+// left, and turns back last.
+func patrol(g *Game, m *Monster) maze.Direction {
+	dirs := g.Maze.Topology().Directions()
+	facing := max(slices.Index(dirs, m.Facing), 0)
+	n := len(dirs)
+	for i := n/2 - 1; i >= -n/2; i-- {
+		if d := dirs[(facing+i+n)%n]; g.monsterCanStep(m, d) {
+			return d
+		}
+	}
+	return maze.None
+}
+
+// wander picks a random open direction, preferring not to turn back.
+func wander(g *Game, m *Monster) maze.Direction {
+	var open []maze.Direction
+	for _, d := range g.Maze.Topology().Directions() {
+		if g.monsterCanStep(m, d) && (m.Facing == maze.None || d != g.Maze.Opposite(m.Facing)) {
+			open = append(open, d)
+		}
+	}
+	if len(open) == 0 {
+		if back := g.Maze.Opposite(m.Facing); m.Facing != maze.None && g.monsterCanStep(m, back) {
+			return back
+		}
+		return maze.None
+	}
+	return open[g.RNG.Intn(len(open))]
+}
+
+// chase heads for the nearest player in a straight unwalled line, the same
+// lines along which players are told the dragon sees them, and wanders when
+// it sees nobody.
+func chase(g *Game, m *Monster) maze.Direction {
+	best, bestDist := maze.None, 0
+	for _, d := range g.Maze.Topology().Directions() {
+		r, c, ok := g.look(m.Row, m.Col, d, func(r, c int) bool { return g.playerAt(r, c) != nil })
+		if !ok {
+			continue
+		}
+		dist := g.Maze.Distance(m.Row, m.Col, r, c)
+		if best == maze.None || dist < bestDist {
+			best, bestDist = d, dist
+		}
+	}
+	if best != maze.None && g.monsterCanStep(m, best) {
+		return best
+	}
+	return wander(g, m)
+}
+
+// look walks from (r, c) in direction d through open walls and returns the
+// first cell found reports true for. Cells that block sight stop it, and so
+// does coming back round a wrapped maze to where it started.
+func (g *Game) look(r, c int, d maze.Direction, found func(r, c int) bool) (int, int, bool) {
+	r0, c0 := r, c
+	for {
+		if g.Maze.Grid[r][c].Walls[d] {
+			return 0, 0, false
+		}
+		r, c = g.Maze.Neighbor(r, c, d)
+		if !g.Maze.InBounds(r, c) || r == r0 && c == c0 || CellBehaviorOf(g.Maze.Grid[r][c].Type).BlocksSight() {
+			return 0, 0, false
+		}
+		if found(r, c) {
+			return r, c, true
+		}
+	}
+}
+
+// monsterCanStep reports whether a monster may move in direction d. Monsters
+// keep to plain cells, but will step anywhere to catch a player.
+func (g *Game) monsterCanStep(m *Monster, d maze.Direction) bool {
+	if g.Maze.Grid[m.Row][m.Col].Walls[d] {
+		return false
+	}
+	r, c := g.Maze.Neighbor(m.Row, m.Col, d)
+	if !g.Maze.InBounds(r, c) || g.monsterAt(r, c) != nil {
+		return false
+	}
+	return g.Maze.Grid[r][c].Type == maze.Empty || g.playerAt(r, c) != nil
+}
+
+func (g *Game) playerAt(r, c int) *Player {
+	for _, p := range g.Players {
+		if !p.Eliminated && p.Row == r && p.Col == c {
+			return p
+		}
+	}
+	return nil
+}
+
+// monsterAt returns the live monster on a cell, if any.
+func (g *Game) monsterAt(r, c int) *Monster {
+	for _, m := range g.Monsters {
+		if !m.Dead && m.Row == r && m.Col == c {
+			return m
+		}
+	}
+	return nil
+}
+
+// moveMonsters lets every monster take its step for the round and catch the
+// players on its cell.
+func (g *Game) moveMonsters() []Event {
+	var events []Event
+	for _, m := range g.Monsters {
+		if m.Dead {
+			continue
+		}
+		if m.Stunned > 0 {
+			m.Stunned--
+			continue
+		}
+		b, ok := behaviors[m.Behavior]
+		if !ok {
+			continue
+		}
+		if d := b.Step(g, m); d != maze.None && g.monsterCanStep(m, d) {
+			m.Row, m.Col = g.Maze.Neighbor(m.Row, m.Col, d)
+			m.Facing = d
+			events = append(events, Event{Kind: EventMonsterMoved, Row: m.Row, Col: m.Col, Dir: d, Monster: m.ID})
+		}
+		for _, p := range g.Players {
+			if !p.Eliminated && p.Row == m.Row && p.Col == m.Col {
+				events = append(events, g.monsterAttack(m, p)...)
+			}
+		}
+	}
+	return events
+}
+
+// endRound runs what happens once every player has had their turn.
+func (g *Game) endRound() []Event {
+	events := g.moveMonsters()
+	g.checkEndConditions()
+	if !g.IsOver() && g.CurrentPlayer().Eliminated {
+		g.NextPlayer()
+	}
+	return events
+}
+
+// monsterAttack applies a monster's damage to a player on its cell.
+func (g *Game) monsterAttack(m *Monster, p *Player) []Event {
+	events := []Event{{Kind: EventMonsterAttack, Row: m.Row, Col: m.Col, Target: p.ID, Monster: m.ID}}
+	switch m.Damage {
+	case DamageHurt:
+		events = append(events, Event{Kind: EventDamage, Row: m.Row, Col: m.Col, Target: p.ID, Monster: m.ID, Changed: !p.Hurt})
+		p.Hurt = true
+	case DamageKill:
+		p.Eliminated = true
+		events = append(events, Event{Kind: EventEliminated, Row: m.Row, Col: m.Col, Target: p.ID, Monster: m.ID, Changed: true})
+	}
+	if p.HasTreasure {
+		p.HasTreasure = false
+		g.Maze.TreasureRow = g.Maze.TreasureStartRow
+		g.Maze.TreasureCol = g.Maze.TreasureStartCol
+		g.Maze.TreasureOnMap = true
+		events = append(events, Event{Kind: EventTreasureLost, Row: m.Row, Col: m.Col, Item: ItemTreasure, Target: p.ID, Monster: m.ID, Changed: true})
+	}
+	return events
+}
+
+// MonsterSpec describes a monster to place.
+type MonsterSpec struct {
+	Behavior string
+	Damage   Damage
+	Stun     int
+}
+
+// ParseMonsterSpec reads BEHAVIOR[:DAMAGE[:STUN]], e.g. "chase:kill" or
+// "wander:hurt:2". Damage defaults to hurt and stun to 0, so a shot kills.
+func ParseMonsterSpec(s string) (MonsterSpec, error) {
+	parts := strings.Split(s, ":")
+	spec := MonsterSpec{Behavior: parts[0]}
+	if _, ok := behaviors[spec.Behavior]; !ok {
+		return spec, fmt.Errorf("unknown monster behaviour %q", spec.Behavior)
+	}
+	if len(parts) > 3 {
+		return spec, fmt.Errorf("bad monster %q, want BEHAVIOR[:DAMAGE[:STUN]]", s)
+	}
+	if len(parts) > 1 {
+		d, err := parseName(damageNames, parts[1])
+		if err != nil {
+			return spec, fmt.Errorf("bad monster damage: %w", err)
+		}
+		spec.Damage = d
+	}
+	if len(parts) > 2 {
+		n, err := strconv.Atoi(parts[2])
+		if err != nil || n < 0 {
+			return spec, fmt.Errorf("bad monster stun %q", parts[2])
+		}
+		spec.Stun = n
+	}
+	return spec, nil
+}
+
+// ParseMonsterSpecs reads a comma-separated list of monster specs.
+func ParseMonsterSpecs(s string) ([]MonsterSpec, error) {
+	var specs []MonsterSpec
+	for _, field := range strings.Split(s, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		spec, err := ParseMonsterSpec(field)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// PlaceMonsters puts monsters on empty cells at least three cells away (see
+// Maze.Distance) from every player. Placement depends only on the game's
+// seed. It must be called before the first action.
+func (g *Game) PlaceMonsters(specs []MonsterSpec) error {
+	if len(g.Log) > 0 {
+		return fmt.Errorf("monsters must be placed before the first action")
+	}
+	var free [][2]int
+	for r := 0; r < g.Maze.Rows; r++ {
+		for c := 0; c < g.Maze.Cols; c++ {
+			if g.Maze.Grid[r][c].Type != maze.Empty || g.monsterAt(r, c) != nil ||
+				(g.Maze.TreasureRow == r && g.Maze.TreasureCol == c) {
+				continue
+			}
+			near := false
+			for _, p := range g.Players {
+				near = near || g.Maze.Distance(p.Row, p.Col, r, c) < 3
+			}
+			if !near {
+				free = append(free, [2]int{r, c})
+			}
+		}
+	}
+	if len(free) < len(specs) {
+		return fmt.Errorf("room for %d monsters, want %d", len(free), len(specs))
+	}
+
+	rng := rand.New(rand.NewSource(g.Seed + int64(len(g.Monsters))))
+	rng.Shuffle(len(free), func(i, j int) { free[i], free[j] = free[j], free[i] })
+	for i, spec := range specs {
+		if _, ok := behaviors[spec.Behavior]; !ok {
+			return fmt.Errorf("unknown monster behaviour %q", spec.Behavior)
+		}
+		g.Monsters = append(g.Monsters, &Monster{
+			ID:       fmt.Sprintf("M%d", len(g.Monsters)+1),
+			Row:      free[i][0],
+			Col:      free[i][1],
+			Behavior: spec.Behavior,
+			Damage:   spec.Damage,
+			Stun:     spec.Stun,
+			Facing:   maze.None,
+			StartRow: free[i][0],
+			StartCol: free[i][1],
+		})
+	}
+	return nil
+}
+
+func copyMonsters(monsters []*Monster) []*Monster {
+	if monsters == nil {
+		return nil
+	}
+	cp := make([]*Monster, len(monsters))
+	for i, m := range monsters {
+		c := *m
+		cp[i] = &c
+	}
+	return cp
+}
+
+// startMonsters returns the monsters as they were placed.
+func startMonsters(monsters []*Monster) []*Monster {
+	cp := copyMonsters(monsters)
+	for _, m := range cp {
+		m.Row, m.Col = m.StartRow, m.StartCol
+		m.Stunned, m.Dead, m.Facing = 0, false, maze.None
+	}
+	return cp
+}
@@ -0,0 +1,407 @@
+package game
+
+import (
+	"fmt"
+	"strings"
+
+	"maze-game/maze"
+)
+
+// EventKind identifies a single thing that happened while resolving an action.
+type EventKind int
+
+const (
+	EventInvalid EventKind = iota
+	EventMoved
+	EventBlocked
+	EventTeleported
+	EventRiverPush
+	EventDamage
+	EventTreasureLost
+	EventHealed
+	EventPickup
+	EventExitDenied
+	EventWin
+	EventShotHit
+	EventShotMiss
+	EventNoBullet
+	EventDragonSeen
+	EventTreasureSeen
+	EventSkipped
+	EventMonsterSeen
+	EventMonsterMoved
+	EventMonsterAttack
+	EventShotMonster
+	EventEliminated
+	EventLocked
+	EventUnlocked
+	EventExitLocked
+	EventClimbed
+	EventDropped
+	EventTimeline
+)
+
+var eventKindNames = map[EventKind]string{
+	EventInvalid:      "invalid",
+	EventMoved:        "moved",
+	EventBlocked:      "blocked",
+	EventTeleported:   "teleported",
+	EventRiverPush:    "river_push",
+	EventDamage:       "damage",
+	EventTreasureLost: "treasure_lost",
+	EventHealed:       "healed",
+	EventPickup:       "pickup",
+	EventExitDenied:   "exit_denied",
+	EventWin:          "win",
+	EventShotHit:      "shot_hit",
+	EventShotMiss:     "shot_miss",
+	EventNoBullet:     "no_bullet",
+	EventDragonSeen:   "dragon_seen",
+	EventTreasureSeen: "treasure_seen",
+	EventSkipped:      "skipped",
+
+	EventMonsterSeen:   "monster_seen",
+	EventMonsterMoved:  "monster_moved",
+	EventMonsterAttack: "monster_attack",
+	EventShotMonster:   "shot_monster",
+	EventEliminated:    "eliminated",
+
+	EventLocked:     "locked",
+	EventUnlocked:   "unlocked",
+	EventExitLocked: "exit_locked",
+	EventClimbed:    "climbed",
+	EventDropped:    "dropped",
+	EventTimeline:   "timeline",
+}
+
+func (k EventKind) String() string {
+	if name, ok := eventKindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("event(%d)", int(k))
+}
+
+// Item is something a player can pick up.
+type Item int
+
+const (
+	ItemNone Item = iota
+	ItemTreasure
+	ItemBullet
+	ItemKey
+)
+
+var itemNames = map[Item]string{
+	ItemNone:     "none",
+	ItemTreasure: "treasure",
+	ItemBullet:   "bullet",
+	ItemKey:      "key",
+}
+
+func (i Item) String() string {
+	if name, ok := itemNames[i]; ok {
+		return name
+	}
+	return fmt.Sprintf("item(%d)", int(i))
+}
+
+// Event is one step of an action's resolution. Row and Col give the cell the
+// event happened on. Changed reports whether the event altered player state,
+// e.g. a pickup that actually added the item or damage that actually hurt.
+type Event struct {
+	Kind     EventKind
+	Row, Col int
+	Cell     maze.CellType  // cell type entered, for EventMoved
+	Dir      maze.Direction // direction of the move or shot
+	Flow     maze.Direction // river flow the player left from, for EventMoved
+	Item     Item
+	Target   string // player hit or attacked, when not the acting player
+	Monster  string // ID of the monster involved, if any
+	Key      int    // key picked up or needed, for keys and doors
+	Floor    int    // floor arrived on, for stairs and holes that drop
+	Changed  bool
+}
+
+// PlayerState is the part of a Player an action can change.
+type PlayerState struct {
+	Row, Col    int
+	Hurt        bool
+	HasTreasure bool
+	Bullet      bool
+	Keys        KeySet
+}
+
+func stateOf(p *Player) PlayerState {
+	return PlayerState{
+		Row:         p.Row,
+		Col:         p.Col,
+		Hurt:        p.Hurt,
+		HasTreasure: p.HasTreasure,
+		Bullet:      p.Bullet,
+		Keys:        p.Keys,
+	}
+}
+
+// Outcome is the typed result of Game.PerformAction. Before and After hold the
+// acting player's state, so their Row and Col are the start and end cells.
+type Outcome struct {
+	PlayerID   string
+	Command    string
+	Events     []Event
+	Before     PlayerState
+	After      PlayerState
+	UsedTurn   bool
+	NextPlayer string
+}
+
+// kindPriority orders event kinds by how much they matter when summarising an
+// outcome with a single kind.
+var kindPriority = []EventKind{
+	EventWin,
+	EventShotHit,
+	EventShotMonster,
+	EventShotMiss,
+	EventDamage,
+	EventHealed,
+	EventPickup,
+	EventUnlocked,
+	EventRiverPush,
+	EventClimbed,
+	EventDropped,
+	EventTeleported,
+	EventLocked,
+	EventBlocked,
+	EventMoved,
+	EventNoBullet,
+	EventSkipped,
+	EventTimeline,
+}
+
+// Kind summarises the outcome by its most significant event.
+func (o Outcome) Kind() EventKind {
+	for _, k := range kindPriority {
+		if o.Has(k) {
+			return k
+		}
+	}
+	return EventInvalid
+}
+
+func (o Outcome) Has(kind EventKind) bool {
+	for _, e := range o.Events {
+		if e.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func (o Outcome) Won() bool {
+	return o.Has(EventWin)
+}
+
+// Message renders the outcome as the sentence shown to the acting player.
+// Monsters catching other players at the end of the round are left out.
+func (o Outcome) Message() string {
+	var parts []string
+	for _, e := range o.Events {
+		if e.Target != "" && e.Target != o.PlayerID && e.Kind != EventShotHit {
+			continue
+		}
+		if s := o.eventText(e); s != "" {
+			parts = append(parts, s)
+		}
+	}
+	msg := strings.Join(parts, " ")
+	if o.UsedTurn {
+		return o.PlayerID + ": " + msg
+	}
+	return msg
+}
+
+func (o Outcome) eventText(e Event) string {
+	switch e.Kind {
+	case EventInvalid:
+		return "Invalid command."
+	case EventMoved:
+		switch e.Cell {
+		case maze.Hole:
+			return "You fell into a hole."
+		case maze.River:
+			if e.Flow == maze.None {
+				return "You stepped into a river."
+			} else if e.Dir == e.Flow {
+				return "You moved down the river."
+			} else if e.Dir == maze.Opposite(e.Flow) {
+				return "You moved up the river."
+			}
+			return "You stepped into a river."
+		case maze.Estuary:
+			return "You stepped directly on the estuary."
+		case maze.Waterfall:
+			return "You stepped into a waterfall."
+		case maze.Lake:
+			return "You stepped into a lake."
+		case maze.Exit:
+			return "You reached the exit."
+		case maze.Empty:
+			return "You moved successfully."
+		}
+		return ""
+	case EventBlocked:
+		return "You hit a wall."
+	case EventTeleported:
+		return "You got teleported through the hole!"
+	case EventRiverPush:
+		switch e.Cell {
+		case maze.Estuary:
+			return "The river pushes you. You arrived at the estuary."
+		case maze.Lake:
+			return "The river pushes you. You arrived at a lake."
+		}
+		return "The river pushes you."
+	case EventDamage:
+		if e.Monster != "" {
+			if e.Changed {
+				return "You're hurt now."
+			}
+			return "You're still hurt."
+		}
+		if e.Changed {
+			return "The dragon burned you. You're hurt now."
+		}
+		return "The dragon burned you. You're still hurt."
+	case EventTreasureLost:
+		return "You lost the treasure and it was returned to its starting position."
+	case EventHealed:
+		if e.Changed {
+			return "You reached the hospital and are healed!"
+		}
+		return "You visited the hospital, but you're already fine."
+	case EventPickup:
+		switch e.Item {
+		case ItemBullet:
+			if e.Changed {
+				return "You found an armory and received a bullet!"
+			}
+			return "You found an armory but already had a bullet!"
+		case ItemTreasure:
+			if e.Changed {
+				return "You found the treasure!"
+			}
+			return "You found the treasure but can't pick it up because you are hurt!"
+		case ItemKey:
+			if e.Changed {
+				return fmt.Sprintf("You found key %d!", e.Key)
+			}
+			return fmt.Sprintf("You found key %d again.", e.Key)
+		}
+	case EventExitDenied:
+		if o.After.Hurt {
+			return "You're hurt and can't escape. Go to a hospital first."
+		}
+		return "You don't have the treasure."
+	case EventWin:
+		return "You escaped with the treasure. You win!"
+	case EventShotHit:
+		if e.Changed {
+			return fmt.Sprintf("You shot player %s! They are now hurt and dropped the treasure.", e.Target)
+		}
+		return fmt.Sprintf("You shot player %s! They are now hurt.", e.Target)
+	case EventShotMiss:
+		return "Your bullet hit a wall and stopped."
+	case EventNoBullet:
+		return "You have no bullets to shoot."
+	case EventDragonSeen:
+		return "The dragon sees you!"
+	case EventTreasureSeen:
+		return "You see the treasure!"
+	case EventSkipped:
+		return "You skipped your turn."
+	case EventTimeline:
+		return "The game moved along its timeline."
+	case EventMonsterSeen:
+		return "A monster sees you!"
+	case EventMonsterAttack:
+		return fmt.Sprintf("Monster %s caught you.", e.Monster)
+	case EventShotMonster:
+		if e.Changed {
+			return fmt.Sprintf("You shot monster %s and killed it!", e.Monster)
+		}
+		return fmt.Sprintf("You shot monster %s and stunned it!", e.Monster)
+	case EventEliminated:
+		return "You are out of the game."
+	case EventLocked:
+		return fmt.Sprintf("The door is locked. You need key %d.", e.Key)
+	case EventUnlocked:
+		return fmt.Sprintf("You unlocked the door with key %d.", e.Key)
+	case EventExitLocked:
+		return fmt.Sprintf("The exit is locked. You need key %d.", e.Key)
+	case EventClimbed:
+		return fmt.Sprintf("You took the stairs %s to floor %d.", e.Dir, e.Floor+1)
+	case EventDropped:
+		return fmt.Sprintf("You fell through the hole to floor %d.", e.Floor+1)
+	}
+	return ""
+}
+
+// PublicMessage renders what every player learns from the outcome: shots,
+// eliminations and a win. It is empty when the action gives nothing away.
+func (o Outcome) PublicMessage() string {
+	var parts []string
+	for _, e := range o.Events {
+		switch e.Kind {
+		case EventShotHit:
+			s := fmt.Sprintf("%s shot %s and hit %s.", o.PlayerID, e.Dir, e.Target)
+			if e.Changed {
+				s += fmt.Sprintf(" %s dropped the treasure.", e.Target)
+			}
+			parts = append(parts, s)
+		case EventShotMiss:
+			parts = append(parts, fmt.Sprintf("%s shot %s and missed.", o.PlayerID, e.Dir))
+		case EventWin:
+			parts = append(parts, fmt.Sprintf("%s escaped with the treasure!", o.PlayerID))
+		case EventSkipped:
+			parts = append(parts, fmt.Sprintf("%s skipped their turn.", o.PlayerID))
+		case EventTimeline:
+			parts = append(parts, "The game moved along its timeline.")
+		case EventShotMonster:
+			if e.Changed {
+				parts = append(parts, fmt.Sprintf("%s shot %s and killed monster %s.", o.PlayerID, e.Dir, e.Monster))
+			} else {
+				parts = append(parts, fmt.Sprintf("%s shot %s and stunned monster %s.", o.PlayerID, e.Dir, e.Monster))
+			}
+		case EventEliminated:
+			parts = append(parts, fmt.Sprintf("Monster %s caught %s, who is out of the game.", e.Monster, e.Target))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// MessageFor renders the outcome as seen by the given player: the acting
+// player gets the full message, a player who was shot or caught by a monster
+// learns it, and everyone else gets PublicMessage.
+func (o Outcome) MessageFor(id string) string {
+	if id == o.PlayerID {
+		return o.Message()
+	}
+	var parts []string
+	for _, e := range o.Events {
+		if e.Target != id {
+			continue
+		}
+		if e.Kind == EventShotHit {
+			s := fmt.Sprintf("%s shot you. You're hurt now.", o.PlayerID)
+			if e.Changed {
+				s += " You dropped the treasure."
+			}
+			parts = append(parts, s)
+		} else if s := o.eventText(e); s != "" {
+			parts = append(parts, s)
+		}
+	}
+	if len(parts) > 0 {
+		return strings.Join(parts, " ")
+	}
+	return o.PublicMessage()
+}
@@ -0,0 +1,202 @@
+package game
+
+import (
+	"fmt"
+)
+
+// Phase is the lifecycle stage of a Game.
+type Phase int
+
+const (
+	PhaseSetup Phase = iota
+	PhaseInProgress
+	PhaseFinished
+)
+
+func (p Phase) String() string {
+	switch p {
+	case PhaseSetup:
+		return "setup"
+	case PhaseInProgress:
+		return "in progress"
+	case PhaseFinished:
+		return "finished"
+	}
+	return fmt.Sprintf("phase(%d)", int(p))
+}
+
+// PhaseError is returned when an action is not allowed in the game's phase.
+type PhaseError struct {
+	Phase Phase
+}
+
+func (e *PhaseError) Error() string {
+	if e.Phase == PhaseFinished {
+		return "game is over"
+	}
+	return fmt.Sprintf("game is %s", e.Phase)
+}
+
+// EndCondition is checked after every turn. It returns true when the game
+// should end, with the winner's ID (empty for no winner) and a reason.
+type EndCondition func(g *Game) (winner string, reason string, over bool)
+
+// LastPlayerStanding ends the game when only one player is not eliminated.
+func LastPlayerStanding() EndCondition {
+	return func(g *Game) (string, string, bool) {
+		var last *Player
+		for _, p := range g.Players {
+			if p.Eliminated {
+				continue
+			}
+			if last != nil {
+				return "", "", false
+			}
+			last = p
+		}
+		if last == nil {
+			return "", "all players were eliminated", true
+		}
+		return last.ID, "last player standing", true
+	}
+}
+
+// TurnLimit ends the game after the given number of turns. A player carrying
+// the treasure at that point wins, otherwise nobody does.
+func TurnLimit(turns int) EndCondition {
+	return func(g *Game) (string, string, bool) {
+		if g.Turn < turns {
+			return "", "", false
+		}
+		for _, p := range g.Players {
+			if p.HasTreasure {
+				return p.ID, "held the treasure at the turn limit", true
+			}
+		}
+		return "", "turn limit reached", true
+	}
+}
+
+// Kinds of EndRule.
+const (
+	EndTurnLimit          = "turn_limit"
+	EndLastPlayerStanding = "last_player_standing"
+)
+
+// EndRule names one of the end conditions above, so that it can be saved
+// with the game: TurnLimit(N) for EndTurnLimit, LastPlayerStanding() for
+// EndLastPlayerStanding.
+type EndRule struct {
+	Kind string `json:"kind"`
+	N    int    `json:"n,omitempty"`
+}
+
+// Condition returns the end condition the rule names.
+func (r EndRule) Condition() (EndCondition, error) {
+	switch r.Kind {
+	case EndTurnLimit:
+		if r.N < 1 {
+			return nil, fmt.Errorf("turn limit %d is not positive", r.N)
+		}
+		return TurnLimit(r.N), nil
+	case EndLastPlayerStanding:
+		return LastPlayerStanding(), nil
+	}
+	return nil, fmt.Errorf("unknown end rule %q", r.Kind)
+}
+
+// Start moves the game from setup into play.
+func (g *Game) Start() error {
+	if g.Phase != PhaseSetup {
+		return &PhaseError{Phase: g.Phase}
+	}
+	if len(g.Players) == 0 {
+		return fmt.Errorf("cannot start a game without players")
+	}
+	g.Phase = PhaseInProgress
+	return nil
+}
+
+// Finish ends the game and records the winner, if any.
+func (g *Game) Finish(winner, reason string) {
+	g.Phase = PhaseFinished
+	g.Winner = winner
+	g.EndReason = reason
+}
+
+func (g *Game) IsOver() bool {
+	return g.Phase == PhaseFinished
+}
+
+// AddEndCondition registers an extra way for the game to end besides a player
+// escaping with the treasure. It is not saved: a loaded game only has the
+// conditions of its EndRules, so add it again after loading.
+func (g *Game) AddEndCondition(cond EndCondition) {
+	g.endConditions = append(g.endConditions, cond)
+}
+
+// AddEndRule registers the end condition a rule names, and keeps the rule in
+// EndRules so that saved games end the same way once loaded.
+func (g *Game) AddEndRule(r EndRule) error {
+	cond, err := r.Condition()
+	if err != nil {
+		return err
+	}
+	g.EndRules = append(g.EndRules, r)
+	g.AddEndCondition(cond)
+	return nil
+}
+
+// useEndRules sets the end conditions of a loaded game from its EndRules.
+func (g *Game) useEndRules() error {
+	g.endConditions = nil
+	for _, r := range g.EndRules {
+		cond, err := r.Condition()
+		if err != nil {
+			return err
+		}
+		g.AddEndCondition(cond)
+	}
+	return nil
+}
+
+// Eliminate takes a player out of the turn order.
+func (g *Game) Eliminate(id string) error {
+	if g.Phase == PhaseFinished {
+		return &PhaseError{Phase: g.Phase}
+	}
+	for i, p := range g.Players {
+		if p.ID != id {
+			continue
+		}
+		p.Eliminated = true
+		g.checkEndConditions()
+		if i == g.current && !g.IsOver() {
+			g.NextPlayer()
+		}
+		return nil
+	}
+	return fmt.Errorf("no player with ID %q", id)
+}
+
+func (g *Game) checkEndConditions() {
+	if g.Phase != PhaseInProgress {
+		return
+	}
+	left := 0
+	for _, p := range g.Players {
+		if !p.Eliminated {
+			left++
+		}
+	}
+	if left == 0 {
+		g.Finish("", "all players were eliminated")
+		return
+	}
+	for _, cond := range g.endConditions {
+		if winner, reason, over := cond(g); over {
+			g.Finish(winner, reason)
+			return
+		}
+	}
+}
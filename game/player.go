@@ -3,7 +3,6 @@ package game
 import (
 	"fmt"
 	"math/rand"
-	"strings"
 
 	"maze-game/maze"
 )
@@ -15,295 +14,174 @@ type Player struct {
 	HasTreasure  bool
 	Bullet       bool
 	LastRiverDir maze.Direction
+	Eliminated   bool
+	Keys         KeySet
 }
 
-func PlacePlayers(m *maze.Maze, count int) []*Player {
-	placed := make(map[[2]int]bool)
-	players := make([]*Player, 0, count)
-
-	for len(players) < count {
-		r := rand.Intn(m.Size)
-		c := rand.Intn(m.Size)
-		pos := [2]int{r, c}
-
-		if m.Grid[r][c].Type == maze.Empty &&
-			!(r == m.TreasureRow && c == m.TreasureCol) &&
-			!placed[pos] {
-
-			player := &Player{
-				ID:     fmt.Sprintf("P%d", len(players)+1),
-				Row:    r,
-				Col:    c,
-				Hurt:   false,
-				Bullet: true,
-			}
-			players = append(players, player)
-			placed[pos] = true
-		}
+func PlacePlayers(m *maze.Maze, count int, rng *rand.Rand) ([]*Player, error) {
+	names := make([]string, count)
+	for i := range names {
+		names[i] = fmt.Sprintf("P%d", i+1)
 	}
-
-	return players
+	return PlacePlayersByName(m, names, rng)
 }
 
-func PlacePlayersByName(m *maze.Maze, names []string) []*Player {
-	placed := make(map[[2]int]bool)
-	players := make([]*Player, 0, len(names))
-
-	for _, name := range names {
-		for {
-			r := rand.Intn(m.Size)
-			c := rand.Intn(m.Size)
-			pos := [2]int{r, c}
-
+// PlacePlayersByName puts each named player on a different empty cell that
+// does not hold the treasure.
+func PlacePlayersByName(m *maze.Maze, names []string, rng *rand.Rand) ([]*Player, error) {
+	var free [][2]int
+	for r := 0; r < m.Rows; r++ {
+		for c := 0; c < m.Cols; c++ {
 			if m.Grid[r][c].Type == maze.Empty &&
-				!(r == m.TreasureRow && c == m.TreasureCol) &&
-				!placed[pos] {
-
-				player := &Player{
-					ID:     name,
-					Row:    r,
-					Col:    c,
-					Hurt:   false,
-					Bullet: true,
-				}
-				players = append(players, player)
-				placed[pos] = true
-				break
+				!(r == m.TreasureRow && c == m.TreasureCol) {
+				free = append(free, [2]int{r, c})
 			}
 		}
 	}
-
-	return players
-}
-
-func CanReachUsingActions(g *Game, startRow, startCol, targetRow, targetCol int) bool {
-	startState := &Game{
-		Maze: g.Maze,
-		Players: []*Player{{
-			ID:     g.CurrentPlayer().ID,
-			Row:    startRow,
-			Col:    startCol,
-			Hurt:   g.CurrentPlayer().Hurt,
-			Bullet: g.CurrentPlayer().Bullet,
-		}},
-		current:                0,
-		ShowVisibilityMessages: false,
-		RiverMoveLength:        g.RiverMoveLength,
+	if len(free) < len(names) {
+		return nil, fmt.Errorf("not enough empty cells for %d players (only %d free)", len(names), len(free))
 	}
 
-	stack := []*Game{startState}
-	visited := make(map[[2]int]bool)
-
-	for len(stack) > 0 {
-		current := stack[len(stack)-1]
-		stack = stack[:len(stack)-1]
-		p := current.CurrentPlayer()
-		pos := [2]int{p.Row, p.Col}
-
-		if pos == [2]int{targetRow, targetCol} {
-			return true
-		}
-
-		if visited[pos] {
-			continue
-		}
-		visited[pos] = true
-
-		for _, dir := range []string{"UP", "DOWN", "LEFT", "RIGHT"} {
-			nextGame := current.Copy()
-			res, _ := nextGame.PerformAction(dir)
-
-			// Skip if move was invalid
-			if strings.Contains(res, "can't") || strings.Contains(strings.ToLower(res), "invalid") {
-				continue
-			}
-
-			np := nextGame.CurrentPlayer()
-			cell := nextGame.Maze.Grid[np.Row][np.Col]
-
-			// Skip if new cell is a dragon
-			if cell.Type == maze.Dragon {
-				continue
-			}
+	players := make([]*Player, 0, len(names))
+	for _, name := range names {
+		i := rng.Intn(len(free))
+		pos := free[i]
+		free[i] = free[len(free)-1]
+		free = free[:len(free)-1]
 
-			stack = append(stack, nextGame)
-		}
+		players = append(players, &Player{
+			ID:     name,
+			Row:    pos[0],
+			Col:    pos[1],
+			Hurt:   false,
+			Bullet: true,
+		})
 	}
 
-	return false
+	return players, nil
 }
 
-func AllPlayersCanReachTreasureAndExit(m *maze.Maze, players []*Player) bool {
-	// Save original treasure position
-	treasureRow, treasureCol := m.TreasureRow, m.TreasureCol
-
-	// Find exit position
-	exitRow, exitCol, found := maze.FindExit(m)
-	if !found {
+// AllPlayersCanReachTreasureAndExit reports whether every player has a way
+// to pick up the treasure and leave through the exit.
+func AllPlayersCanReachTreasureAndExit(m *maze.Maze, players []*Player, riverMoveLength int) bool {
+	if _, _, found := maze.FindExit(m); !found {
 		return false
 	}
 
+	solver := NewSolver(m, riverMoveLength)
 	for _, p := range players {
-		// Create a temporary game instance with a deep copy of the player
-		tempPlayer := &Player{
-			ID:     p.ID,
-			Row:    p.Row,
-			Col:    p.Col,
-			Hurt:   p.Hurt,
-			Bullet: p.Bullet,
-		}
-		tempGame := &Game{
-			Maze:                   m,
-			Players:                []*Player{tempPlayer},
-			current:                0,
-			ShowVisibilityMessages: false,
-			RiverMoveLength:        2,
-		}
-
-		// Step 1: Check if the player can reach the treasure
-		if !CanReachUsingActions(tempGame, tempPlayer.Row, tempPlayer.Col, treasureRow, treasureCol) {
+		if _, ok := solver.PathToWin(stateOf(p)); !ok {
 			return false
 		}
+	}
+	return true
+}
 
-		// Move player to treasure and restore treasure position
-		tempPlayer.Row = treasureRow
-		tempPlayer.Col = treasureCol
-		m.TreasureRow = treasureRow
-		m.TreasureCol = treasureCol
-		m.TreasureOnMap = true
-
-		// Step 2: Check if the player can reach the exit from the treasure
-		if !CanReachUsingActions(tempGame, treasureRow, treasureCol, exitRow, exitCol) {
+// CanReachTreasureFromEstuary reports whether a player washed up on any
+// estuary or lake holding keys can still get to the treasure. It is false for
+// a maze without an estuary.
+func CanReachTreasureFromEstuary(m *maze.Maze, riverMoveLength int, keys KeySet) bool {
+	ends := riverEnds(m)
+	if len(ends) == 0 {
+		return false
+	}
+	solver := NewSolver(m, riverMoveLength)
+	for _, e := range ends {
+		if !canReachTreasureFrom(solver, e[0], e[1], keys) {
 			return false
 		}
-
-		m.TreasureRow = treasureRow
-		m.TreasureCol = treasureCol
-		m.TreasureOnMap = true
 	}
-
 	return true
 }
 
-func CanReachTreasureFromEstuary(m *maze.Maze, treasureRow, treasureCol int) bool {
-	// Find the estuary tile
-	var estuaryRow, estuaryCol int
-	found := false
-	for r := 0; r < m.Size; r++ {
-		for c := 0; c < m.Size; c++ {
-			if m.Grid[r][c].Type == maze.Estuary {
-				estuaryRow = r
-				estuaryCol = c
-				found = true
-				break
+func canReachTreasureFrom(solver *Solver, r, c int, keys KeySet) bool {
+	start := PlayerState{Row: r, Col: c, Bullet: true, Keys: keys}
+	_, ok := solver.PathTo(start, solver.Maze.TreasureRow, solver.Maze.TreasureCol)
+	return ok
+}
+
+// riverEnds lists the estuaries and lakes, where river pushes end, in row
+// order.
+func riverEnds(m *maze.Maze) [][2]int {
+	var ends [][2]int
+	for r := 0; r < m.Rows; r++ {
+		for c := 0; c < m.Cols; c++ {
+			if t := m.Grid[r][c].Type; t == maze.Estuary || t == maze.Lake {
+				ends = append(ends, [2]int{r, c})
 			}
 		}
-		if found {
-			break
-		}
-	}
-	if !found {
-		return false
-	}
-
-	// Create a game with a single player starting on the estuary
-	player := &Player{
-		ID:     "RiverTester",
-		Row:    estuaryRow,
-		Col:    estuaryCol,
-		Hurt:   false,
-		Bullet: true,
-	}
-	g := &Game{
-		Maze:                   m,
-		Players:                []*Player{player},
-		current:                0,
-		ShowVisibilityMessages: false,
-		RiverMoveLength:        2,
-	}
-
-	if CanReachUsingActions(g, estuaryRow, estuaryCol, treasureRow, treasureCol) {
-		m.TreasureRow = treasureRow
-		m.TreasureCol = treasureCol
-		m.TreasureOnMap = true
-		return true
-	} else {
-		return false
 	}
+	return ends
 }
 
-func HospitalReachableFromExit(m *maze.Maze) bool {
-	treasureRow := m.TreasureRow
-	treasureCol := m.TreasureCol
+// HospitalReachableFromExit reports whether a player turned away from the exit
+// for being hurt, holding keys, can get healed and come back.
+func HospitalReachableFromExit(m *maze.Maze, riverMoveLength int, keys KeySet) bool {
 	exitRow, exitCol, exitFound := maze.FindExit(m)
 	if !exitFound {
 		return false
 	}
-
-	// Find the hospital
-	var hospitalRow, hospitalCol int
-	hospitalFound := false
-	for r := 0; r < m.Size; r++ {
-		for c := 0; c < m.Size; c++ {
-			if m.Grid[r][c].Type == maze.Hospital {
-				hospitalRow = r
-				hospitalCol = c
-				hospitalFound = true
-				break
-			}
-		}
-		if hospitalFound {
-			break
-		}
-	}
+	hospitalRow, hospitalCol, hospitalFound := findCell(m, maze.Hospital)
 	if !hospitalFound {
 		return false
 	}
 
-	// Create a test player starting at exit
-	exitPlayer := &Player{
-		ID:     "HospitalTester",
-		Row:    exitRow,
-		Col:    exitCol,
-		Hurt:   false,
-		Bullet: true,
-	}
-	gameFromExit := &Game{
-		Maze:                   m,
-		Players:                []*Player{exitPlayer},
-		current:                0,
-		ShowVisibilityMessages: false,
-		RiverMoveLength:        2,
-	}
+	solver := NewSolver(m, riverMoveLength)
 
 	// Check exit -> hospital
-	if !CanReachUsingActions(gameFromExit, exitRow, exitCol, hospitalRow, hospitalCol) {
+	fromExit := PlayerState{Row: exitRow, Col: exitCol, Hurt: true, Bullet: true, Keys: keys}
+	if _, ok := solver.PathTo(fromExit, hospitalRow, hospitalCol); !ok {
 		return false
 	}
 
 	// Now check hospital -> exit
-	hospitalPlayer := &Player{
-		ID:     "HospitalTester2",
-		Row:    hospitalRow,
-		Col:    hospitalCol,
-		Hurt:   false,
-		Bullet: true,
-	}
-	gameFromHospital := &Game{
-		Maze:                   m,
-		Players:                []*Player{hospitalPlayer},
-		current:                0,
-		ShowVisibilityMessages: false,
-		RiverMoveLength:        2,
-	}
+	fromHospital := PlayerState{Row: hospitalRow, Col: hospitalCol, Bullet: true, Keys: keys}
+	_, ok := solver.PathTo(fromHospital, exitRow, exitCol)
+	return ok
+}
 
-	if !CanReachUsingActions(gameFromHospital, hospitalRow, hospitalCol, exitRow, exitCol) {
-		return false
-	} else {
-		m.TreasureRow = treasureRow
-		m.TreasureCol = treasureCol
-		m.TreasureOnMap = true
-		return true
+// findCell returns the first cell of the given type in row order.
+func findCell(m *maze.Maze, t maze.CellType) (int, int, bool) {
+	for r := 0; r < m.Rows; r++ {
+		for c := 0; c < m.Cols; c++ {
+			if m.Grid[r][c].Type == t {
+				return r, c, true
+			}
+		}
 	}
+	return 0, 0, false
+}
 
+// checkSetup runs every generation check on a candidate maze and player
+// layout and reports the first one that fails. The estuary and hospital
+// checks assume the keys each player holds when their shortest path first
+// reaches each estuary or lake, or wins at the exit.
+func checkSetup(m *maze.Maze, players []*Player, riverMoveLength int) error {
+	if _, _, found := maze.FindExit(m); !found {
+		return fmt.Errorf("maze has no exit")
+	}
+	ends := riverEnds(m)
+	if len(ends) == 0 {
+		return fmt.Errorf("treasure cannot be reached from the estuary")
+	}
+	solver := NewSolver(m, riverMoveLength)
+	for _, p := range players {
+		_, won, ok := solver.search(stateOf(p), solver.Won)
+		if !ok {
+			return fmt.Errorf("player %s cannot reach the treasure and the exit", p.ID)
+		}
+		for _, e := range ends {
+			keys := p.Keys
+			if st, ok := solver.StateAt(stateOf(p), e[0], e[1]); ok {
+				keys = st.Keys
+			}
+			if !canReachTreasureFrom(solver, e[0], e[1], keys) {
+				return fmt.Errorf("treasure cannot be reached from the %s at (%d,%d)", m.Grid[e[0]][e[1]].Type, e[0], e[1])
+			}
+		}
+		if !HospitalReachableFromExit(m, riverMoveLength, won.Keys) {
+			return fmt.Errorf("hospital and exit cannot be reached from each other")
+		}
+	}
+	return nil
 }
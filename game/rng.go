@@ -0,0 +1,27 @@
+package game
+
+// RNG is the random number generator used during play (SplitMix64). Its whole
+// state is a single number, so it can be saved, logged and restored exactly.
+type RNG struct {
+	State uint64
+}
+
+func NewRNG(seed int64) RNG {
+	return RNG{State: uint64(seed)}
+}
+
+func (r *RNG) Uint64() uint64 {
+	r.State += 0x9e3779b97f4a7c15
+	z := r.State
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// Intn returns a number in [0, n). It panics if n <= 0.
+func (r *RNG) Intn(n int) int {
+	if n <= 0 {
+		panic("game: RNG.Intn called with n <= 0")
+	}
+	return int(r.Uint64() % uint64(n))
+}
@@ -0,0 +1,953 @@
+package game
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"maze-game/maze"
+)
+
+// Games can be saved as gob (the original format) or as JSON. LoadFromFile
+// and ReadGame detect which one they are given.
+//
+// The JSON save format, version 12, is
+//
+//	{
+//	  "format": "maze-game",
+//	  "version": 12,
+//	  "maze": { see maze.Maze.MarshalJSON },
+//	  "players": [
+//	    {"id": "P1", "row": 0, "col": 2, "hurt": false, "has_treasure": false,
+//	     "bullet": true, "eliminated": false, "keys": [1]}
+//	  ],
+//	  "current": 0,
+//	  "phase": "in_progress",
+//	  "turn": 4,
+//	  "winner": "",
+//	  "end_reason": "",
+//	  "seed": 1234,
+//	  "river_move_length": 2,
+//	  "show_visibility_messages": true,
+//	  "move_history": ["UP", "SHOOT LEFT"],
+//	  "starts": [{"id": "P1", "row": 1, "col": 2}],
+//	  "monsters": [
+//	    {"id": "M1", "row": 4, "col": 5, "start_row": 4, "start_col": 3,
+//	     "behavior": "chase", "damage": "hurt", "stun": 2, "stunned": 0,
+//	     "dead": false, "facing": "right"}
+//	  ],
+//	  "rng_state": 1234,
+//	  "log": [
+//	    {"turn": 0, "player": "P1", "action": "UP", "time": "2024-05-01T10:00:00Z",
+//	     "rng_state": 1234, "used_turn": true, "next_player": "P2",
+//	     "before": {"row": 1, "col": 2, "hurt": false, "has_treasure": false, "bullet": true},
+//	     "after": { ... },
+//	     "events": [{"kind": "moved", "row": 0, "col": 2, "cell": "empty", "dir": "up",
+//	                 "flow": "none", "item": "none", "target": "", "monster": "",
+//	                 "changed": false}]}
+//	  ],
+//	  "end_rules": [{"kind": "turn_limit", "n": 50}],
+//	  "notes": {
+//	    "P1": {"cells": [{"row": 0, "col": 2, "visited": true, "type": "empty",
+//	                      "walls": {"up": "closed", "down": "open"}}],
+//	           "sightings": [{"turn": 3, "row": 0, "col": 2, "kind": "dragon_seen"}]}
+//	  },
+//	  "timeline": {
+//	    "current": 1,
+//	    "nodes": [
+//	      {"parent": -1, "command": "", "state": { a save without timeline }},
+//	      {"parent": 0, "command": "UP", "before": { ... }, "state": { ... }}
+//	    ]
+//	  }
+//	}
+//
+// phase is one of "setup", "in_progress" or "finished". current is the index
+// of the player whose turn it is. A player's LastRiverDir is not saved: it is
+// recomputed at the start of every move. starts holds where each player
+// began, so the game can be replayed; it is empty for games saved before
+// version 2.
+//
+// monsters holds Game.Monsters; damage is one of "hurt", "steal" or "kill".
+// An event's monster, key and floor are only saved when set.
+//
+// keys lists the numbers of the keys a player or player state holds; it is
+// left out when empty. Doors and key cells are part of the maze.
+//
+// rng_state is Game.RNG. log holds Game.Log; entries of saves from before
+// version 4 are lost, but move_history still has their commands.
+//
+// end_rules holds Game.EndRules, left out when there are none. End
+// conditions added with AddEndCondition are not saved.
+//
+// notes holds each player's Knowledge by player ID. Only cells the player
+// has learned something about are listed; type is left out until the cell
+// is identified, and walls only lists the sides known to be "open" or
+// "closed". Saves from before version 11, and gob saves, have no notes, so
+// players start them afresh.
+//
+// timeline is the tree of Game.Timeline, if one was recorded. Nodes are
+// listed so that every parent comes before its children. Each holds the
+// state after its command, and before holds the state the command was played
+// on when that differs from the parent's, e.g. after an invalid command. The
+// log of a node's states only holds the entries added since its parent, and
+// their maze is left out when it is the same as the parent's. Their
+// move_history is left out too: it is the parent's plus the node's command.
+//
+// Older JSON saves are upgraded by saveMigrations on load. When the schema
+// changes, bump SaveVersion and add a migration from the previous version.
+
+const (
+	SaveFormat  = "maze-game"
+	SaveVersion = 12
+)
+
+// SaveDir is where SaveToFile and LoadFromFile resolve relative file names.
+var SaveDir = "saved"
+
+// saveMigrations[v] upgrades a decoded save document from version v to v+1.
+var saveMigrations = map[int]func(doc map[string]json.RawMessage) error{
+	// Version 2 added the players' starting cells
+	1: func(doc map[string]json.RawMessage) error {
+		doc["starts"] = json.RawMessage("[]")
+		return nil
+	},
+	// Version 3 added the optional timeline
+	2: func(doc map[string]json.RawMessage) error {
+		return nil
+	},
+	// Version 4 added the log and the play RNG, seeded like new games
+	3: func(doc map[string]json.RawMessage) error {
+		var seed int64
+		if err := json.Unmarshal(doc["seed"], &seed); err != nil {
+			return err
+		}
+		doc["log"] = json.RawMessage("[]")
+		doc["rng_state"] = json.RawMessage(strconv.FormatUint(NewRNG(seed).State, 10))
+		return nil
+	},
+	// Version 5 added monsters
+	4: func(doc map[string]json.RawMessage) error {
+		doc["monsters"] = json.RawMessage("[]")
+		return nil
+	},
+	// Version 6 added keys and doors
+	5: func(doc map[string]json.RawMessage) error {
+		return nil
+	},
+	// Version 7 added floors, stairs and holes that drop
+	6: func(doc map[string]json.RawMessage) error {
+		return nil
+	},
+	// Version 8 added hex and triangle topologies and the diagonal wall letters
+	7: func(doc map[string]json.RawMessage) error {
+		return nil
+	},
+	// Version 9 added mazes whose edges wrap
+	8: func(doc map[string]json.RawMessage) error {
+		return nil
+	},
+	// Version 10 added lakes and waterfalls
+	9: func(doc map[string]json.RawMessage) error {
+		return nil
+	},
+	// Version 11 added the players' notes
+	10: func(doc map[string]json.RawMessage) error {
+		return nil
+	},
+	// Version 12 added the end rules
+	11: func(doc map[string]json.RawMessage) error {
+		return nil
+	},
+}
+
+type saveGame struct {
+	Format                 string               `json:"format"`
+	Version                int                  `json:"version"`
+	Maze                   *maze.Maze           `json:"maze,omitempty"`
+	Players                []savePlayer         `json:"players"`
+	Current                int                  `json:"current"`
+	Phase                  string               `json:"phase"`
+	Turn                   int                  `json:"turn"`
+	Winner                 string               `json:"winner"`
+	EndReason              string               `json:"end_reason"`
+	Seed                   int64                `json:"seed"`
+	RiverMoveLength        int                  `json:"river_move_length"`
+	ShowVisibilityMessages bool                 `json:"show_visibility_messages"`
+	MoveHistory            []string             `json:"move_history,omitempty"`
+	Starts                 []saveStart          `json:"starts"`
+	Monsters               []saveMonster        `json:"monsters"`
+	RNGState               uint64               `json:"rng_state"`
+	Log                    []saveLogEntry       `json:"log"`
+	EndRules               []EndRule            `json:"end_rules,omitempty"`
+	Notes                  map[string]saveNotes `json:"notes,omitempty"`
+	Timeline               *saveTimeline        `json:"timeline,omitempty"`
+}
+
+type saveNotes struct {
+	Cells     []saveKnownCell `json:"cells"`
+	Sightings []saveSighting  `json:"sightings"`
+}
+
+type saveKnownCell struct {
+	Row     int               `json:"row"`
+	Col     int               `json:"col"`
+	Visited bool              `json:"visited"`
+	Type    string            `json:"type,omitempty"`
+	Walls   map[string]string `json:"walls,omitempty"`
+}
+
+type saveSighting struct {
+	Turn int    `json:"turn"`
+	Row  int    `json:"row"`
+	Col  int    `json:"col"`
+	Kind string `json:"kind"`
+}
+
+type saveLogEntry struct {
+	Turn       int             `json:"turn"`
+	Player     string          `json:"player"`
+	Action     string          `json:"action"`
+	Time       time.Time       `json:"time"`
+	RNGState   uint64          `json:"rng_state"`
+	UsedTurn   bool            `json:"used_turn"`
+	NextPlayer string          `json:"next_player"`
+	Before     savePlayerState `json:"before"`
+	After      savePlayerState `json:"after"`
+	Events     []saveEvent     `json:"events"`
+}
+
+type savePlayerState struct {
+	Row         int   `json:"row"`
+	Col         int   `json:"col"`
+	Hurt        bool  `json:"hurt"`
+	HasTreasure bool  `json:"has_treasure"`
+	Bullet      bool  `json:"bullet"`
+	Keys        []int `json:"keys,omitempty"`
+}
+
+type saveEvent struct {
+	Kind    string `json:"kind"`
+	Row     int    `json:"row"`
+	Col     int    `json:"col"`
+	Cell    string `json:"cell"`
+	Dir     string `json:"dir"`
+	Flow    string `json:"flow"`
+	Item    string `json:"item"`
+	Target  string `json:"target"`
+	Monster string `json:"monster,omitempty"`
+	Key     int    `json:"key,omitempty"`
+	Floor   int    `json:"floor,omitempty"`
+	Changed bool   `json:"changed"`
+}
+
+type saveTimeline struct {
+	Current int            `json:"current"`
+	Nodes   []saveTimeNode `json:"nodes"`
+}
+
+type saveTimeNode struct {
+	Parent  int       `json:"parent"`
+	Command string    `json:"command"`
+	Before  *saveGame `json:"before,omitempty"`
+	State   *saveGame `json:"state"`
+}
+
+type saveMonster struct {
+	ID       string `json:"id"`
+	Row      int    `json:"row"`
+	Col      int    `json:"col"`
+	StartRow int    `json:"start_row"`
+	StartCol int    `json:"start_col"`
+	Behavior string `json:"behavior"`
+	Damage   string `json:"damage"`
+	Stun     int    `json:"stun"`
+	Stunned  int    `json:"stunned"`
+	Dead     bool   `json:"dead"`
+	Facing   string `json:"facing"`
+}
+
+type saveStart struct {
+	ID  string `json:"id"`
+	Row int    `json:"row"`
+	Col int    `json:"col"`
+}
+
+type savePlayer struct {
+	ID          string `json:"id"`
+	Row         int    `json:"row"`
+	Col         int    `json:"col"`
+	Hurt        bool   `json:"hurt"`
+	HasTreasure bool   `json:"has_treasure"`
+	Bullet      bool   `json:"bullet"`
+	Eliminated  bool   `json:"eliminated"`
+	Keys        []int  `json:"keys,omitempty"`
+}
+
+var phaseNames = map[Phase]string{
+	PhaseSetup:      "setup",
+	PhaseInProgress: "in_progress",
+	PhaseFinished:   "finished",
+}
+
+var wallKnowledgeNames = map[WallKnowledge]string{
+	WallUnknown: "unknown",
+	WallOpen:    "open",
+	WallClosed:  "closed",
+}
+
+func savePath(filename string) string {
+	if filepath.IsAbs(filename) {
+		return filename
+	}
+	return filepath.Join(SaveDir, filename)
+}
+
+// SaveToFile writes the game below SaveDir. Names ending in .json are saved
+// as JSON, anything else as gob.
+func (g *Game) SaveToFile(filename string) error {
+	path := savePath(filename)
+	// Ensure the save directory exists
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if strings.EqualFold(filepath.Ext(filename), ".json") {
+		return g.WriteJSON(file)
+	}
+	return writeGob(file, g)
+}
+
+// writeGob encodes the game followed by the index of the current player,
+// which gob leaves out of the game as it is unexported.
+func writeGob(w io.Writer, g *Game) error {
+	encoder := gob.NewEncoder(w)
+	if err := encoder.Encode(g); err != nil {
+		return err
+	}
+	return encoder.Encode(g.current)
+}
+
+// LoadFromFile reads a game saved as either JSON or gob.
+func LoadFromFile(filename string) (*Game, error) {
+	file, err := os.Open(savePath(filename))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadGame(file)
+}
+
+// ReadGame decodes a game, detecting whether r holds JSON or gob.
+func ReadGame(r io.Reader) (*Game, error) {
+	br := bufio.NewReader(r)
+	for {
+		b, err := br.Peek(1)
+		if err != nil {
+			return nil, fmt.Errorf("reading save: %w", err)
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			br.ReadByte()
+			continue
+		case '{':
+			data, err := io.ReadAll(br)
+			if err != nil {
+				return nil, err
+			}
+			return UnmarshalGame(data)
+		}
+		return readGob(br)
+	}
+}
+
+func readGob(r io.Reader) (*Game, error) {
+	var g Game
+	decoder := gob.NewDecoder(r)
+	if err := decoder.Decode(&g); err != nil {
+		return nil, err
+	}
+	// Older gob saves end here, with the first player to move
+	if err := decoder.Decode(&g.current); err != nil && err != io.EOF {
+		return nil, err
+	}
+	if len(g.Players) > 0 && (g.current < 0 || g.current >= len(g.Players)) {
+		return nil, fmt.Errorf("current player %d out of range", g.current)
+	}
+	// Gob saves from before game phases decode as PhaseSetup
+	if g.Phase == PhaseSetup && len(g.Players) > 0 {
+		g.Phase = PhaseInProgress
+	}
+	if err := g.useEndRules(); err != nil {
+		return nil, err
+	}
+	// Gob only fills in exported fields; copying resolves the topology
+	if g.Maze != nil {
+		g.Maze = maze.CopyMaze(g.Maze)
+	}
+	return &g, nil
+}
+
+// WriteJSON writes the game in the current JSON save format.
+func (g *Game) WriteJSON(w io.Writer) error {
+	save := g.saveData()
+	save.EndRules = g.EndRules
+	if g.timeline != nil {
+		save.Timeline = g.timeline.saveData()
+	}
+	data, err := json.MarshalIndent(save, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+func (g *Game) saveData() saveGame {
+	return g.saveDataFrom(0)
+}
+
+// saveDataFrom leaves out the log entries before logStart.
+func (g *Game) saveDataFrom(logStart int) saveGame {
+	players := make([]savePlayer, len(g.Players))
+	for i, p := range g.Players {
+		players[i] = savePlayer{
+			ID:          p.ID,
+			Row:         p.Row,
+			Col:         p.Col,
+			Hurt:        p.Hurt,
+			HasTreasure: p.HasTreasure,
+			Bullet:      p.Bullet,
+			Eliminated:  p.Eliminated,
+			Keys:        p.Keys.Keys(),
+		}
+	}
+	starts := make([]saveStart, len(g.Starts))
+	for i, st := range g.Starts {
+		starts[i] = saveStart{ID: st.ID, Row: st.Row, Col: st.Col}
+	}
+	monsters := make([]saveMonster, len(g.Monsters))
+	for i, m := range g.Monsters {
+		monsters[i] = saveMonster{
+			ID:       m.ID,
+			Row:      m.Row,
+			Col:      m.Col,
+			StartRow: m.StartRow,
+			StartCol: m.StartCol,
+			Behavior: m.Behavior,
+			Damage:   m.Damage.String(),
+			Stun:     m.Stun,
+			Stunned:  m.Stunned,
+			Dead:     m.Dead,
+			Facing:   m.Facing.String(),
+		}
+	}
+	history := g.MoveHistory
+	if history == nil {
+		history = []string{}
+	}
+	log := []saveLogEntry{}
+	for _, e := range g.Log[logStart:] {
+		log = append(log, saveLogEntryOf(e))
+	}
+	var notes map[string]saveNotes
+	for id, k := range g.knowledge {
+		if notes == nil {
+			notes = map[string]saveNotes{}
+		}
+		notes[id] = saveNotesOf(k)
+	}
+	return saveGame{
+		Format:                 SaveFormat,
+		Version:                SaveVersion,
+		Maze:                   g.Maze,
+		Players:                players,
+		Current:                g.current,
+		Phase:                  phaseNames[g.Phase],
+		Turn:                   g.Turn,
+		Winner:                 g.Winner,
+		EndReason:              g.EndReason,
+		Seed:                   g.Seed,
+		RiverMoveLength:        g.RiverMoveLength,
+		ShowVisibilityMessages: g.ShowVisibilityMessages,
+		MoveHistory:            history,
+		Starts:                 starts,
+		Monsters:               monsters,
+		RNGState:               g.RNG.State,
+		Log:                    log,
+		Notes:                  notes,
+	}
+}
+
+// saveNotesOf lists the cells of k the player has learned something about.
+func saveNotesOf(k *Knowledge) saveNotes {
+	out := saveNotes{Cells: []saveKnownCell{}, Sightings: []saveSighting{}}
+	for r, row := range k.Cells {
+		for c, cell := range row {
+			sc := saveKnownCell{Row: r, Col: c, Visited: cell.Visited}
+			if cell.Identified {
+				sc.Type = cell.Type.String()
+			}
+			for d, w := range cell.Walls {
+				if w == WallUnknown {
+					continue
+				}
+				if sc.Walls == nil {
+					sc.Walls = map[string]string{}
+				}
+				sc.Walls[maze.Direction(d).String()] = wallKnowledgeNames[w]
+			}
+			if sc.Visited || sc.Type != "" || sc.Walls != nil {
+				out.Cells = append(out.Cells, sc)
+			}
+		}
+	}
+	for _, s := range k.Sightings {
+		out.Sightings = append(out.Sightings, saveSighting{Turn: s.Turn, Row: s.Row, Col: s.Col, Kind: s.Kind.String()})
+	}
+	return out
+}
+
+func (in saveNotes) knowledge(m *maze.Maze) (*Knowledge, error) {
+	k := blankKnowledge(m)
+	for _, sc := range in.Cells {
+		if !m.InGrid(sc.Row, sc.Col) {
+			return nil, fmt.Errorf("cell (%d,%d) is off the maze", sc.Row, sc.Col)
+		}
+		cell := &k.Cells[sc.Row][sc.Col]
+		cell.Visited = sc.Visited
+		if sc.Type != "" {
+			t, err := maze.ParseCellType(sc.Type)
+			if err != nil {
+				return nil, err
+			}
+			cell.Identified, cell.Type = true, t
+		}
+		for side, wall := range sc.Walls {
+			d, err := maze.ParseDirection(side)
+			if err != nil {
+				return nil, err
+			}
+			if cell.Walls[d], err = parseName(wallKnowledgeNames, wall); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, ss := range in.Sightings {
+		kind, err := parseName(eventKindNames, ss.Kind)
+		if err != nil {
+			return nil, err
+		}
+		k.Sightings = append(k.Sightings, Sighting{Turn: ss.Turn, Row: ss.Row, Col: ss.Col, Kind: kind})
+	}
+	return k, nil
+}
+
+func saveLogEntryOf(e LogEntry) saveLogEntry {
+	state := func(s PlayerState) savePlayerState {
+		return savePlayerState{Row: s.Row, Col: s.Col, Hurt: s.Hurt, HasTreasure: s.HasTreasure, Bullet: s.Bullet, Keys: s.Keys.Keys()}
+	}
+	out := saveLogEntry{
+		Turn:       e.Turn,
+		Player:     e.PlayerID,
+		Action:     e.Action,
+		Time:       e.Time,
+		RNGState:   e.RNGState,
+		UsedTurn:   e.Outcome.UsedTurn,
+		NextPlayer: e.Outcome.NextPlayer,
+		Before:     state(e.Outcome.Before),
+		After:      state(e.Outcome.After),
+		Events:     []saveEvent{},
+	}
+	for _, ev := range e.Outcome.Events {
+		out.Events = append(out.Events, saveEvent{
+			Kind:    ev.Kind.String(),
+			Row:     ev.Row,
+			Col:     ev.Col,
+			Cell:    ev.Cell.String(),
+			Dir:     ev.Dir.String(),
+			Flow:    ev.Flow.String(),
+			Item:    ev.Item.String(),
+			Target:  ev.Target,
+			Monster: ev.Monster,
+			Key:     ev.Key,
+			Floor:   ev.Floor,
+			Changed: ev.Changed,
+		})
+	}
+	return out
+}
+
+func (in saveLogEntry) entry() (LogEntry, error) {
+	state := func(s savePlayerState) (PlayerState, error) {
+		keys, err := keySetOf(s.Keys)
+		return PlayerState{Row: s.Row, Col: s.Col, Hurt: s.Hurt, HasTreasure: s.HasTreasure, Bullet: s.Bullet, Keys: keys}, err
+	}
+	before, err := state(in.Before)
+	if err != nil {
+		return LogEntry{}, err
+	}
+	after, err := state(in.After)
+	if err != nil {
+		return LogEntry{}, err
+	}
+	out := Outcome{
+		PlayerID:   in.Player,
+		Command:    in.Action,
+		Before:     before,
+		After:      after,
+		UsedTurn:   in.UsedTurn,
+		NextPlayer: in.NextPlayer,
+	}
+	for _, se := range in.Events {
+		ev := Event{Row: se.Row, Col: se.Col, Target: se.Target, Monster: se.Monster, Key: se.Key, Floor: se.Floor, Changed: se.Changed}
+		var err error
+		if ev.Kind, err = parseName(eventKindNames, se.Kind); err != nil {
+			return LogEntry{}, err
+		}
+		if ev.Item, err = parseName(itemNames, se.Item); err != nil {
+			return LogEntry{}, err
+		}
+		if ev.Cell, err = maze.ParseCellType(se.Cell); err != nil {
+			return LogEntry{}, err
+		}
+		if ev.Dir, err = maze.ParseDirection(se.Dir); err != nil {
+			return LogEntry{}, err
+		}
+		if ev.Flow, err = maze.ParseDirection(se.Flow); err != nil {
+			return LogEntry{}, err
+		}
+		out.Events = append(out.Events, ev)
+	}
+	return LogEntry{
+		Turn:     in.Turn,
+		PlayerID: in.Player,
+		Action:   in.Action,
+		Outcome:  out,
+		Time:     in.Time,
+		RNGState: in.RNGState,
+	}, nil
+}
+
+func parseName[T comparable](names map[T]string, s string) (T, error) {
+	for v, name := range names {
+		if name == s {
+			return v, nil
+		}
+	}
+	var zero T
+	return zero, fmt.Errorf("unknown name %q", s)
+}
+
+func (t *Timeline) saveData() *saveTimeline {
+	st := &saveTimeline{Current: t.current.ID}
+	for _, n := range t.nodes {
+		sn := saveTimeNode{Parent: -1, Command: n.Command}
+		base := 0
+		if n.Parent != nil {
+			sn.Parent = n.Parent.ID
+			base = len(n.Parent.state.Log)
+			before := n.before.saveDataFrom(base)
+			if !sameSave(before, n.Parent.state.saveDataFrom(base)) {
+				sn.Before = &before
+			}
+		}
+		state := n.state.saveDataFrom(base)
+		if n.Parent != nil {
+			omitSameMaze(&state, n.Parent.state)
+			state.MoveHistory = nil
+			if sn.Before != nil {
+				omitSameMaze(sn.Before, n.Parent.state)
+				sn.Before.MoveHistory = nil
+			}
+		}
+		sn.State = &state
+		st.Nodes = append(st.Nodes, sn)
+	}
+	return st
+}
+
+// omitSameMaze leaves out a node's maze when it is the same as its parent's,
+// which it is unless the treasure moved.
+func omitSameMaze(s *saveGame, parent *Game) {
+	a, errA := json.Marshal(s.Maze)
+	b, errB := json.Marshal(parent.Maze)
+	if errA == nil && errB == nil && bytes.Equal(a, b) {
+		s.Maze = nil
+	}
+}
+
+func sameSave(a, b saveGame) bool {
+	da, errA := json.Marshal(a)
+	db, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(da, db)
+}
+
+func inheritMaze(s *saveGame, parent *Game) {
+	if s != nil && s.Maze == nil {
+		s.Maze = maze.CopyMaze(parent.Maze)
+	}
+}
+
+func (st *saveTimeline) timeline() (*Timeline, error) {
+	t := &Timeline{}
+	for i, sn := range st.Nodes {
+		if sn.State == nil {
+			return nil, fmt.Errorf("timeline node %d has no state", i)
+		}
+		if sn.Parent >= 0 && sn.Parent < i {
+			inheritMaze(sn.State, t.nodes[sn.Parent].state)
+			inheritMaze(sn.Before, t.nodes[sn.Parent].state)
+		}
+		state, err := sn.State.game()
+		if err != nil {
+			return nil, fmt.Errorf("timeline node %d: %w", i, err)
+		}
+		if sn.Parent < 0 {
+			if i != 0 {
+				return nil, fmt.Errorf("timeline node %d has no parent", i)
+			}
+			t.root = t.add(nil, "", nil, state)
+			continue
+		}
+		if sn.Parent >= i {
+			return nil, fmt.Errorf("timeline node %d has an invalid parent", i)
+		}
+		parent := t.nodes[sn.Parent]
+		before := parent.state
+		if sn.Before != nil {
+			if before, err = sn.Before.game(); err != nil {
+				return nil, fmt.Errorf("timeline node %d: %w", i, err)
+			}
+			before.Log = append(parent.state.Log[:len(parent.state.Log):len(parent.state.Log)], before.Log...)
+			before.MoveHistory = parent.state.MoveHistory
+		}
+		state.Log = append(parent.state.Log[:len(parent.state.Log):len(parent.state.Log)], state.Log...)
+		state.MoveHistory = append(before.MoveHistory[:len(before.MoveHistory):len(before.MoveHistory)], sn.Command)
+		t.add(parent, sn.Command, before, state)
+	}
+	t.current = t.Node(st.Current)
+	if t.current == nil {
+		return nil, fmt.Errorf("timeline has no node %d", st.Current)
+	}
+	// Redo follows the path to the current node
+	for n := t.current; n.Parent != nil; n = n.Parent {
+		n.Parent.redo = n
+	}
+	return t, nil
+}
+
+// UnmarshalGame decodes a JSON save, upgrading it from older versions first.
+func UnmarshalGame(data []byte) (*Game, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	var format string
+	var version int
+	if err := json.Unmarshal(doc["format"], &format); err != nil || format != SaveFormat {
+		return nil, fmt.Errorf("not a %s save", SaveFormat)
+	}
+	if err := json.Unmarshal(doc["version"], &version); err != nil {
+		return nil, fmt.Errorf("save has no valid version: %w", err)
+	}
+	if version > SaveVersion {
+		return nil, fmt.Errorf("save version %d is newer than supported version %d", version, SaveVersion)
+	}
+	for ; version < SaveVersion; version++ {
+		migrate, ok := saveMigrations[version]
+		if !ok {
+			return nil, fmt.Errorf("no migration from save version %d", version)
+		}
+		if err := migrate(doc); err != nil {
+			return nil, fmt.Errorf("migrating save from version %d: %w", version, err)
+		}
+	}
+
+	upgraded, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var in saveGame
+	decoder := json.NewDecoder(bytes.NewReader(upgraded))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&in); err != nil {
+		return nil, err
+	}
+	return in.game()
+}
+
+func (in saveGame) game() (*Game, error) {
+	if in.Maze == nil {
+		return nil, fmt.Errorf("save has no maze")
+	}
+	if len(in.Players) == 0 {
+		return nil, fmt.Errorf("save has no players")
+	}
+	if in.Current < 0 || in.Current >= len(in.Players) {
+		return nil, fmt.Errorf("current player %d out of range", in.Current)
+	}
+
+	phase := Phase(-1)
+	for p, name := range phaseNames {
+		if name == in.Phase {
+			phase = p
+		}
+	}
+	if phase < 0 {
+		return nil, fmt.Errorf("unknown phase %q", in.Phase)
+	}
+
+	players := make([]*Player, len(in.Players))
+	for i, sp := range in.Players {
+		if !in.Maze.InBounds(sp.Row, sp.Col) {
+			return nil, fmt.Errorf("player %s is off the maze at (%d,%d)", sp.ID, sp.Row, sp.Col)
+		}
+		keys, err := keySetOf(sp.Keys)
+		if err != nil {
+			return nil, fmt.Errorf("player %s: %w", sp.ID, err)
+		}
+		players[i] = &Player{
+			ID:           sp.ID,
+			Row:          sp.Row,
+			Col:          sp.Col,
+			Hurt:         sp.Hurt,
+			HasTreasure:  sp.HasTreasure,
+			Bullet:       sp.Bullet,
+			LastRiverDir: maze.None,
+			Eliminated:   sp.Eliminated,
+			Keys:         keys,
+		}
+	}
+
+	var starts []maze.PlayerStart
+	if len(in.Starts) > 0 {
+		if len(in.Starts) != len(in.Players) {
+			return nil, fmt.Errorf("save has %d starts for %d players", len(in.Starts), len(in.Players))
+		}
+		starts = make([]maze.PlayerStart, len(in.Starts))
+		for i, st := range in.Starts {
+			starts[i] = maze.PlayerStart{ID: st.ID, Row: st.Row, Col: st.Col}
+		}
+	}
+
+	monsters := make([]*Monster, len(in.Monsters))
+	for i, sm := range in.Monsters {
+		if !in.Maze.InBounds(sm.Row, sm.Col) || !in.Maze.InBounds(sm.StartRow, sm.StartCol) {
+			return nil, fmt.Errorf("monster %s is off the maze", sm.ID)
+		}
+		if _, ok := behaviors[sm.Behavior]; !ok {
+			return nil, fmt.Errorf("monster %s has unknown behaviour %q", sm.ID, sm.Behavior)
+		}
+		damage, err := parseName(damageNames, sm.Damage)
+		if err != nil {
+			return nil, fmt.Errorf("monster %s: %w", sm.ID, err)
+		}
+		facing, err := maze.ParseDirection(sm.Facing)
+		if err != nil {
+			return nil, fmt.Errorf("monster %s: %w", sm.ID, err)
+		}
+		monsters[i] = &Monster{
+			ID:       sm.ID,
+			Row:      sm.Row,
+			Col:      sm.Col,
+			StartRow: sm.StartRow,
+			StartCol: sm.StartCol,
+			Behavior: sm.Behavior,
+			Damage:   damage,
+			Stun:     sm.Stun,
+			Stunned:  sm.Stunned,
+			Dead:     sm.Dead,
+			Facing:   facing,
+		}
+	}
+
+	var log []LogEntry
+	for i, se := range in.Log {
+		e, err := se.entry()
+		if err != nil {
+			return nil, fmt.Errorf("log entry %d: %w", i, err)
+		}
+		log = append(log, e)
+	}
+
+	g := &Game{
+		Maze:                   in.Maze,
+		Players:                players,
+		current:                in.Current,
+		ShowVisibilityMessages: in.ShowVisibilityMessages,
+		RiverMoveLength:        in.RiverMoveLength,
+		MoveHistory:            in.MoveHistory,
+		Phase:                  phase,
+		Turn:                   in.Turn,
+		Winner:                 in.Winner,
+		EndReason:              in.EndReason,
+		Seed:                   in.Seed,
+		Starts:                 starts,
+		Monsters:               monsters,
+		Log:                    log,
+		RNG:                    RNG{State: in.RNGState},
+		EndRules:               in.EndRules,
+	}
+	if err := g.useEndRules(); err != nil {
+		return nil, err
+	}
+	for id, sn := range in.Notes {
+		if !slices.ContainsFunc(players, func(p *Player) bool { return p.ID == id }) {
+			return nil, fmt.Errorf("notes of unknown player %q", id)
+		}
+		k, err := sn.knowledge(in.Maze)
+		if err != nil {
+			return nil, fmt.Errorf("notes of %s: %w", id, err)
+		}
+		if g.knowledge == nil {
+			g.knowledge = map[string]*Knowledge{}
+		}
+		g.knowledge[id] = k
+	}
+	if in.Timeline != nil {
+		t, err := in.Timeline.timeline()
+		if err != nil {
+			return nil, err
+		}
+		g.timeline = t
+	}
+	return g, nil
+}
+
+// WriteMazeText writes the maze in the text format of maze.WriteText, with
+// the players' current cells as their starts.
+func (g *Game) WriteMazeText(w io.Writer) error {
+	starts := make([]maze.PlayerStart, len(g.Players))
+	for i, p := range g.Players {
+		starts[i] = maze.PlayerStart{ID: p.ID, Row: p.Row, Col: p.Col}
+	}
+	return maze.WriteText(w, g.Maze, starts)
+}
+
+// keySetOf reads the key numbers of a save.
+func keySetOf(keys []int) (KeySet, error) {
+	var s KeySet
+	for _, k := range keys {
+		if k < 1 || k > maze.MaxKey {
+			return 0, fmt.Errorf("bad key %d", k)
+		}
+		s = s.With(k)
+	}
+	return s, nil
+}
@@ -0,0 +1,204 @@
+package game
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"math/rand"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// playedGame returns a game some random commands into play, with an undo to
+// give its timeline a second branch.
+func playedGame(t *testing.T, seed int64) *Game {
+	t.Helper()
+	g := newTestGame(t, seed)
+	rng := rand.New(rand.NewSource(seed))
+	cmds := []string{"UP", "DOWN", "LEFT", "RIGHT", "SHOOT UP", "BOGUS"}
+	for i := 0; i < 30 && !g.IsOver(); i++ {
+		g.PerformAction(cmds[rng.Intn(len(cmds))])
+		if i == 10 {
+			g.Undo()
+		}
+	}
+	return g
+}
+
+func jsonSave(t *testing.T, g *Game) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := g.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	for seed := int64(1); seed <= 3; seed++ {
+		g := playedGame(t, seed)
+		data := jsonSave(t, g)
+		loaded, err := ReadGame(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("seed %d: %v", seed, err)
+		}
+		if again := jsonSave(t, loaded); !bytes.Equal(again, data) {
+			t.Errorf("seed %d: saving the loaded game gives a different save", seed)
+		}
+		for _, p := range g.Players {
+			want, _ := g.Knowledge(p.ID)
+			got, _ := loaded.Knowledge(p.ID)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("seed %d: notes of %s changed", seed, p.ID)
+			}
+		}
+		if len(loaded.Timeline().Path()) != len(g.Timeline().Path()) {
+			t.Errorf("seed %d: timeline path has %d nodes, want %d", seed, len(loaded.Timeline().Path()), len(g.Timeline().Path()))
+		}
+	}
+}
+
+func TestGobRoundTrip(t *testing.T) {
+	g := playedGame(t, 1)
+	var buf bytes.Buffer
+	if err := writeGob(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := ReadGame(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Gob keeps neither the timeline nor the notes
+	want, got := g.saveData(), loaded.saveData()
+	want.Notes, got.Notes = nil, nil
+	if !sameSave(want, got) {
+		t.Error("loaded game differs")
+	}
+
+	// Older gob saves hold only the game, and start with the first player
+	buf.Reset()
+	if err := gob.NewEncoder(&buf).Encode(g); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, err = ReadGame(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.GetCurrent() != 0 || loaded.Turn != g.Turn {
+		t.Errorf("older save loaded at player %d on turn %d", loaded.GetCurrent(), loaded.Turn)
+	}
+}
+
+func TestSaveToFile(t *testing.T) {
+	SaveDir = t.TempDir()
+	g := playedGame(t, 2)
+	for _, name := range []string{"game.json", "game.gob"} {
+		if err := g.SaveToFile(name); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		loaded, err := LoadFromFile(filepath.Join(SaveDir, name))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if loaded.Turn != g.Turn || !reflect.DeepEqual(loaded.MoveHistory, g.MoveHistory) {
+			t.Errorf("%s: loaded turn %d after %v, want %d after %v", name, loaded.Turn, loaded.MoveHistory, g.Turn, g.MoveHistory)
+		}
+	}
+}
+
+// TestEndRulesAreSaved checks that a loaded game still ends at its turn limit.
+func TestEndRulesAreSaved(t *testing.T) {
+	g := newTestGame(t, 5)
+	if err := g.AddEndRule(EndRule{Kind: EndTurnLimit, N: 3}); err != nil {
+		t.Fatal(err)
+	}
+	g.AddEndCondition(func(*Game) (string, string, bool) { return "", "not saved", true })
+	var buf bytes.Buffer
+	if err := writeGob(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+	fromJSON, err := ReadGame(bytes.NewReader(jsonSave(t, g)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromGob, err := ReadGame(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, loaded := range map[string]*Game{"json": fromJSON, "gob": fromGob} {
+		if !reflect.DeepEqual(loaded.EndRules, g.EndRules) {
+			t.Errorf("%s: end rules %v, want %v", name, loaded.EndRules, g.EndRules)
+		}
+		for i := 0; i < 10 && !loaded.IsOver(); i++ {
+			loaded.PerformAction("SKIP")
+		}
+		if loaded.Turn != 3 || loaded.EndReason != "turn limit reached" {
+			t.Errorf("%s: game ended on turn %d with %q", name, loaded.Turn, loaded.EndReason)
+		}
+	}
+}
+
+// TestMigrateFirstVersion loads a save as version 1 wrote it, without the
+// fields later versions added.
+func TestMigrateFirstVersion(t *testing.T) {
+	g := newTestGame(t, 3)
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(jsonSave(t, g), &doc); err != nil {
+		t.Fatal(err)
+	}
+	doc["version"] = json.RawMessage("1")
+	for _, field := range []string{"starts", "log", "rng_state", "monsters", "notes", "timeline"} {
+		delete(doc, field)
+	}
+	data, _ := json.Marshal(doc)
+
+	loaded, err := UnmarshalGame(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.RNG != NewRNG(g.Seed) {
+		t.Errorf("play RNG is %v, want one seeded with %d", loaded.RNG, g.Seed)
+	}
+	if len(loaded.Starts) != 0 || len(loaded.Log) != 0 || len(loaded.Monsters) != 0 {
+		t.Errorf("loaded %d starts, %d log entries and %d monsters, want none", len(loaded.Starts), len(loaded.Log), len(loaded.Monsters))
+	}
+}
+
+func TestUnmarshalGameErrors(t *testing.T) {
+	data := string(jsonSave(t, newTestGame(t, 4)))
+	version := `"version": ` + strings.TrimSpace(string(mustJSON(t, SaveVersion)))
+	tests := []struct {
+		name string
+		save string
+		want string
+	}{
+		{"not json", "{", "unexpected end"},
+		{"other format", strings.Replace(data, `"maze-game"`, `"other"`, 1), "not a maze-game save"},
+		{"newer version", strings.Replace(data, version, `"version": 999`, 1), "newer than supported"},
+		{"unknown field", strings.Replace(data, `"format"`, `"colour": 1, "format"`, 1), "unknown field"},
+		{"bad current player", strings.Replace(data, `"current": 0`, `"current": 5`, 1), "out of range"},
+		{"unknown end rule", strings.Replace(data, `"format"`, `"end_rules": [{"kind": "sudden_death"}], "format"`, 1), "unknown end rule"},
+		{"bad phase", strings.Replace(data, `"in_progress"`, `"paused"`, 1), "unknown phase"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.save == data {
+				t.Fatal("the save was not changed")
+			}
+			_, err := UnmarshalGame([]byte(tt.save))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func mustJSON(t *testing.T, v any) []byte {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
@@ -0,0 +1,225 @@
+package game
+
+import (
+	"maze-game/maze"
+)
+
+// Solver answers reachability questions on a maze without running a Game.
+// It searches the graph of PlayerState values (position, hurt, treasure,
+// bullet, keys) where every edge is one command, so the paths it returns are
+// the shortest possible, and a door only opens once its key is held. Walls
+// are those of the cell being left, so one-way walls are only passed the way
+// they let you through.
+//
+// Other players are ignored, and the treasure is assumed to lie on the maze's
+// current treasure cell until picked up.
+type Solver struct {
+	Maze            *maze.Maze
+	RiverMoveLength int
+
+	holes []PlayerState
+}
+
+func NewSolver(m *maze.Maze, riverMoveLength int) *Solver {
+	s := &Solver{Maze: m, RiverMoveLength: riverMoveLength}
+	for r := 0; r < m.Rows; r++ {
+		for c := 0; c < m.Cols; c++ {
+			if _, _, drops := m.DropTo(r, c); m.Grid[r][c].Type == maze.Hole && !drops {
+				s.holes = append(s.holes, PlayerState{Row: r, Col: c})
+			}
+		}
+	}
+	return s
+}
+
+// Solver returns a solver for the game's maze and river settings.
+func (g *Game) Solver() *Solver {
+	return NewSolver(g.Maze, g.RiverMoveLength)
+}
+
+// Move applies a movement command to st, following the same rules as
+// Game.PerformAction: cells act through the state hooks of their
+// CellBehavior. It reports false if the player did not move.
+func (s *Solver) Move(st PlayerState, dir maze.Direction) (PlayerState, bool) {
+	start := st
+	cell := s.Maze.Grid[st.Row][st.Col]
+
+	if cell.Walls[dir] && !st.Keys.Has(cell.Door(dir)) {
+		var moved bool
+		if st, moved = CellBehaviorOf(cell.Type).BumpWallState(s, st, cell, dir); !moved {
+			return start, false
+		}
+	} else {
+		nr, nc := s.Maze.Neighbor(st.Row, st.Col, dir)
+		if !s.Maze.InBounds(nr, nc) {
+			return start, false
+		}
+		st.Row, st.Col = nr, nc
+		st = s.enter(st)
+	}
+
+	st = s.pickUpTreasure(st)
+	return st, st != start
+}
+
+// Shoot spends the bullet. It only changes position when fired from a hole,
+// so the solver only considers shots taken there.
+func (s *Solver) Shoot(st PlayerState) (PlayerState, bool) {
+	if !st.Bullet || s.Maze.Grid[st.Row][st.Col].Type != maze.Hole {
+		return st, false
+	}
+	st.Bullet = false
+	st = s.pickUpTreasure(s.hole(st))
+	return st, true
+}
+
+// enter applies the effect of the cell the player just stepped onto, as its
+// CellBehavior tells.
+func (s *Solver) enter(st PlayerState) PlayerState {
+	cell := s.Maze.Grid[st.Row][st.Col]
+	return CellBehaviorOf(cell.Type).EnterState(s, st, cell)
+}
+
+// hole drops the player to the floor below, or teleports them when holes do
+// not drop.
+func (s *Solver) hole(st PlayerState) PlayerState {
+	if r, c, ok := s.Maze.DropTo(st.Row, st.Col); ok {
+		st.Row, st.Col = r, c
+		return s.enter(st)
+	}
+	return s.teleport(st)
+}
+
+func (s *Solver) teleport(st PlayerState) PlayerState {
+	if len(s.holes) < 2 {
+		return st
+	}
+	for i, h := range s.holes {
+		if h.Row == st.Row && h.Col == st.Col {
+			next := s.holes[(i+1)%len(s.holes)]
+			st.Row, st.Col = next.Row, next.Col
+			return st
+		}
+	}
+	return st
+}
+
+func (s *Solver) riverPush(st PlayerState) PlayerState {
+	st.Row, st.Col = downstream(s.Maze, st.Row, st.Col, s.RiverMoveLength)
+	return st
+}
+
+// burn hurts the player; a carried treasure goes back to its start, which the
+// solver treats as the treasure cell.
+func (s *Solver) burn(st PlayerState) PlayerState {
+	st.Hurt = true
+	st.HasTreasure = false
+	return st
+}
+
+func (s *Solver) pickUpTreasure(st PlayerState) PlayerState {
+	m := s.Maze
+	if !st.HasTreasure && !st.Hurt && m.TreasureOnMap && st.Row == m.TreasureRow && st.Col == m.TreasureCol {
+		st.HasTreasure = true
+	}
+	return st
+}
+
+// Won reports whether st is a winning state: on the exit, carrying the
+// treasure, not hurt and holding the exit's key if it is locked.
+func (s *Solver) Won(st PlayerState) bool {
+	cell := s.Maze.Grid[st.Row][st.Col]
+	return st.HasTreasure && !st.Hurt && cell.Type == maze.Exit && (cell.Key == 0 || st.Keys.Has(cell.Key))
+}
+
+// ShortestPath returns the shortest list of commands leading from start to a
+// state for which goal returns true.
+func (s *Solver) ShortestPath(start PlayerState, goal func(PlayerState) bool) ([]string, bool) {
+	path, _, ok := s.search(start, goal)
+	return path, ok
+}
+
+// StateAt returns the state in which the shortest path from start first
+// reaches the given cell, e.g. to learn which keys a player holds there.
+func (s *Solver) StateAt(start PlayerState, row, col int) (PlayerState, bool) {
+	_, st, ok := s.search(start, func(st PlayerState) bool {
+		return st.Row == row && st.Col == col
+	})
+	return st, ok
+}
+
+func (s *Solver) search(start PlayerState, goal func(PlayerState) bool) ([]string, PlayerState, bool) {
+	start = s.pickUpTreasure(start)
+	if goal(start) {
+		return []string{}, start, true
+	}
+
+	prev := map[PlayerState]solverEdge{start: {}}
+	queue := []PlayerState{start}
+	dirs := s.Maze.Topology().Directions()
+
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+
+		// Nothing can happen after the game is won
+		if s.Won(cur) {
+			continue
+		}
+
+		var next []solverEdge
+		for _, d := range dirs {
+			if st, ok := s.Move(cur, d); ok {
+				next = append(next, solverEdge{st, Command(d)})
+			}
+		}
+		if st, ok := s.Shoot(cur); ok {
+			next = append(next, solverEdge{st, "SHOOT " + Command(dirs[0])})
+		}
+
+		// Here each edge's state is the one reached, not the one left
+		for _, e := range next {
+			if _, seen := prev[e.state]; seen {
+				continue
+			}
+			prev[e.state] = solverEdge{cur, e.cmd}
+			if goal(e.state) {
+				return unwindPath(prev, start, e.state), e.state, true
+			}
+			queue = append(queue, e.state)
+		}
+	}
+	return nil, PlayerState{}, false
+}
+
+// solverEdge links a state to a neighbour by the command between them.
+type solverEdge struct {
+	state PlayerState
+	cmd   string
+}
+
+// unwindPath follows prev, which maps each state to the state it was reached
+// from, back from end to start.
+func unwindPath(prev map[PlayerState]solverEdge, start, end PlayerState) []string {
+	var path []string
+	for st := end; st != start; st = prev[st].state {
+		path = append(path, prev[st].cmd)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// PathTo returns the shortest list of commands that brings the player onto
+// the given cell.
+func (s *Solver) PathTo(start PlayerState, row, col int) ([]string, bool) {
+	return s.ShortestPath(start, func(st PlayerState) bool {
+		return st.Row == row && st.Col == col
+	})
+}
+
+// PathToWin returns the shortest list of commands that wins the game from start.
+func (s *Solver) PathToWin(start PlayerState) ([]string, bool) {
+	return s.ShortestPath(start, s.Won)
+}
@@ -0,0 +1,132 @@
+package game
+
+import (
+	"context"
+	"testing"
+
+	"maze-game/maze"
+	"maze-game/mazegen"
+)
+
+// TestSolverPathsWin replays the solver's winning path in a game of one
+// player and checks that the game agrees it wins.
+func TestSolverPathsWin(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(cfg *mazegen.MazeConfig)
+	}{
+		{"default", func(*mazegen.MazeConfig) {}},
+		{"open", func(cfg *mazegen.MazeConfig) { cfg.ExtraOpenings = 10 }},
+		{"rectangle", func(cfg *mazegen.MazeConfig) { cfg.Rows, cfg.Cols = 5, 8 }},
+		{"shape", func(cfg *mazegen.MazeConfig) { cfg.Shape = "ring" }},
+		{"keys", func(cfg *mazegen.MazeConfig) { cfg.NumKeys, cfg.LockedExit = 2, true }},
+		{"floors", func(cfg *mazegen.MazeConfig) { cfg.Floors, cfg.HolesDrop = 2, true }},
+		{"hex", func(cfg *mazegen.MazeConfig) { cfg.Topology = "hex" }},
+		{"triangle", func(cfg *mazegen.MazeConfig) { cfg.Topology = "triangle" }},
+		{"cylinder", func(cfg *mazegen.MazeConfig) { cfg.Wrap = "cylinder" }},
+		{"torus", func(cfg *mazegen.MazeConfig) { cfg.Wrap = "torus" }},
+		{"one-way", func(cfg *mazegen.MazeConfig) { cfg.NumOneWay = 3 }},
+		{"rivers", func(cfg *mazegen.MazeConfig) { cfg.Tributaries, cfg.Waterfalls = 1, 1 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for seed := int64(1); seed <= 3; seed++ {
+				cfg := mazegen.MazeConfig{
+					Size:                    6,
+					NumHoles:                2,
+					NumArmories:             1,
+					NumHospitals:            1,
+					NumDragons:              1,
+					RiverLength:             8,
+					MinTreasureExitDistance: 4,
+					Seed:                    seed,
+				}
+				tt.change(&cfg)
+				g, err := NewGameFromConfig(context.Background(), cfg, 2, []string{"P1"})
+				if err != nil {
+					t.Fatalf("seed %d: %v", seed, err)
+				}
+				path, ok := g.Solver().PathToWin(stateOf(g.CurrentPlayer()))
+				if !ok {
+					t.Fatalf("seed %d: no way to win from the start", seed)
+				}
+				for i, cmd := range path {
+					if _, err := g.PerformAction(cmd); err != nil {
+						t.Fatalf("seed %d: move %d of %v: %v", seed, i+1, path, err)
+					}
+				}
+				if g.Winner != "P1" {
+					t.Errorf("seed %d: %v does not win", seed, path)
+				}
+			}
+		})
+	}
+}
+
+// smallMaze returns a 2 by 3 maze with no inner walls, the treasure in the
+// middle of the top row and the exit to its right.
+func smallMaze() *maze.Maze {
+	m := maze.CreateMaze(2, 3, 0, 0)
+	for c := 0; c < 3; c++ {
+		m.RemoveWallBetween(0, c, maze.Down)
+		if c < 2 {
+			m.RemoveWallBetween(0, c, maze.Right)
+			m.RemoveWallBetween(1, c, maze.Right)
+		}
+	}
+	m.TreasureRow, m.TreasureCol, m.TreasureOnMap = 0, 1, true
+	m.Grid[0][2].Type = maze.Exit
+	return m
+}
+
+func TestSolverReachability(t *testing.T) {
+	lockExit := func(m *maze.Maze) {
+		m.AddDoor(0, 2, maze.Left, 1)
+		m.AddWall(0, 2, maze.Down)
+	}
+	tests := []struct {
+		name   string
+		change func(m *maze.Maze)
+		hurt   bool
+		keys   KeySet
+		win    bool
+	}{
+		{"open", func(*maze.Maze) {}, false, 0, true},
+		{"exit walled off", func(m *maze.Maze) {
+			m.AddWall(0, 2, maze.Left)
+			m.AddWall(0, 2, maze.Down)
+		}, false, 0, false},
+		{"dragon before the exit", func(m *maze.Maze) {
+			m.AddWall(0, 2, maze.Left)
+			m.Grid[1][2].Type = maze.Dragon
+		}, false, 0, false},
+		{"hurt", func(*maze.Maze) {}, true, 0, false},
+		{"hospital", func(m *maze.Maze) { m.Grid[1][1].Type = maze.Hospital }, true, 0, true},
+		{"locked door", lockExit, false, 0, false},
+		{"key held", lockExit, false, KeySet(0).With(1), true},
+		{"key on the maze", func(m *maze.Maze) {
+			lockExit(m)
+			m.Grid[1][1].Type, m.Grid[1][1].Key = maze.Key, 1
+		}, false, 0, true},
+		{"one-way the wrong way", func(m *maze.Maze) {
+			m.AddWall(0, 2, maze.Down)
+			m.AddOneWay(0, 2, maze.Left)
+		}, false, 0, false},
+		{"one-way the right way", func(m *maze.Maze) {
+			m.AddWall(0, 2, maze.Down)
+			m.AddOneWay(0, 1, maze.Right)
+		}, false, 0, true},
+		{"locked exit", func(m *maze.Maze) { m.Grid[0][2].Key = 2 }, false, KeySet(0).With(1), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := smallMaze()
+			tt.change(m)
+			start := PlayerState{Row: 1, Col: 0, Hurt: tt.hurt, Bullet: true, Keys: tt.keys}
+			path, ok := NewSolver(m, 2).PathToWin(start)
+			if ok != tt.win {
+				t.Errorf("can win %v by %v, want %v", ok, path, tt.win)
+			}
+		})
+	}
+}
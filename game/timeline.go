@@ -0,0 +1,204 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrNothingToUndo = errors.New("nothing to undo")
+	ErrNothingToRedo = errors.New("nothing to redo")
+)
+
+// Timeline is the tree of states a game has been through. Every action that
+// uses a turn adds a child to the current node, so undoing and playing on
+// starts a new branch and keeps the old one to come back to.
+type Timeline struct {
+	root    *TimelineNode
+	current *TimelineNode
+	nodes   []*TimelineNode // by ID
+}
+
+// TimelineNode is one state of the game: the root is where recording began,
+// every other node the state right after Command.
+type TimelineNode struct {
+	ID       int
+	Command  string
+	Parent   *TimelineNode
+	Children []*TimelineNode
+
+	before *Game // the state Command was played on; nil for the root
+	state  *Game
+	redo   *TimelineNode // the child Redo moves to
+}
+
+func newTimeline(g *Game) *Timeline {
+	t := &Timeline{}
+	t.root = t.add(nil, "", nil, g.snapshot())
+	t.current = t.root
+	return t
+}
+
+func (t *Timeline) add(parent *TimelineNode, cmd string, before, state *Game) *TimelineNode {
+	n := &TimelineNode{ID: len(t.nodes), Command: cmd, Parent: parent, before: before, state: state}
+	t.nodes = append(t.nodes, n)
+	if parent != nil {
+		parent.Children = append(parent.Children, n)
+		parent.redo = n
+	}
+	return n
+}
+
+func (t *Timeline) Root() *TimelineNode    { return t.root }
+func (t *Timeline) Current() *TimelineNode { return t.current }
+
+// Node returns the node with the given ID, or nil.
+func (t *Timeline) Node(id int) *TimelineNode {
+	if id < 0 || id >= len(t.nodes) {
+		return nil
+	}
+	return t.nodes[id]
+}
+
+// Path lists the nodes from the root to the current one.
+func (t *Timeline) Path() []*TimelineNode {
+	var path []*TimelineNode
+	for n := t.current; n != nil; n = n.Parent {
+		path = append([]*TimelineNode{n}, path...)
+	}
+	return path
+}
+
+// State returns a copy of the game in this node's state.
+func (n *TimelineNode) State() *Game {
+	return n.state.snapshot()
+}
+
+// Timeline returns the game's tree of states, starting it at the current
+// state if nothing has been recorded yet.
+func (g *Game) Timeline() *Timeline {
+	if g.timeline == nil {
+		g.timeline = newTimeline(g)
+	}
+	return g.timeline
+}
+
+// record adds the action just performed to the timeline; before is the state
+// it was played on.
+func (g *Game) record(cmd string, before *Game) {
+	t := g.Timeline()
+	t.current = t.add(t.current, cmd, before, g.snapshot())
+}
+
+// Undo takes back the last action, restoring the game exactly as it was
+// before it, including any turn skipped in between.
+func (g *Game) Undo() error {
+	t := g.Timeline()
+	n := t.current
+	if n.Parent == nil {
+		return ErrNothingToUndo
+	}
+	g.restore(n.before)
+	t.current = n.Parent
+	t.current.redo = n
+	g.publishJump("UNDO")
+	return nil
+}
+
+// Redo plays again the action last undone, or the branch last visited.
+func (g *Game) Redo() error {
+	t := g.Timeline()
+	if t.current.redo == nil {
+		return ErrNothingToRedo
+	}
+	t.current = t.current.redo
+	g.restore(t.current.state)
+	g.publishJump("REDO")
+	return nil
+}
+
+// GoTo moves the game to any node of its timeline. Playing on from there
+// starts a new branch.
+func (g *Game) GoTo(id int) error {
+	t := g.Timeline()
+	n := t.Node(id)
+	if n == nil {
+		return fmt.Errorf("no timeline node %d", id)
+	}
+	for c := n; c.Parent != nil; c = c.Parent {
+		c.Parent.redo = c
+	}
+	t.current = n
+	g.restore(n.state)
+	g.publishJump(fmt.Sprintf("GOTO %d", id))
+	return nil
+}
+
+// publishJump tells subscribers the game moved along its timeline. The entry
+// is not added to the log, which is the one of the state moved to.
+func (g *Game) publishJump(cmd string) {
+	p := g.CurrentPlayer()
+	g.publish(LogEntry{
+		Turn:     g.Turn,
+		PlayerID: p.ID,
+		Action:   cmd,
+		Time:     time.Now(),
+		RNGState: g.RNG.State,
+		Outcome: Outcome{
+			PlayerID:   p.ID,
+			Command:    cmd,
+			Events:     []Event{{Kind: EventTimeline, Row: p.Row, Col: p.Col}},
+			Before:     stateOf(p),
+			After:      stateOf(p),
+			NextPlayer: p.ID,
+		},
+	})
+}
+
+// snapshot copies everything an action can change. Playing never changes
+// the maze's cells, only where the treasure lies, so snapshots share them.
+func (g *Game) snapshot() *Game {
+	players := make([]*Player, len(g.Players))
+	for i, p := range g.Players {
+		cp := *p
+		players[i] = &cp
+	}
+	var knowledge map[string]*Knowledge
+	if g.knowledge != nil {
+		knowledge = map[string]*Knowledge{}
+		for id, k := range g.knowledge {
+			knowledge[id] = k.Copy()
+		}
+	}
+	m := *g.Maze
+	return &Game{
+		Maze:                   &m,
+		Players:                players,
+		current:                g.current,
+		ShowVisibilityMessages: g.ShowVisibilityMessages,
+		RiverMoveLength:        g.RiverMoveLength,
+		MoveHistory:            g.MoveHistory[:len(g.MoveHistory):len(g.MoveHistory)],
+		Phase:                  g.Phase,
+		Turn:                   g.Turn,
+		Winner:                 g.Winner,
+		EndReason:              g.EndReason,
+		Seed:                   g.Seed,
+		Starts:                 g.Starts,
+		Monsters:               copyMonsters(g.Monsters),
+		Log:                    g.Log[:len(g.Log):len(g.Log)], // entries never change, appending copies
+		RNG:                    g.RNG,
+		EndRules:               g.EndRules,
+		endConditions:          g.endConditions,
+		knowledge:              knowledge,
+	}
+}
+
+// restore puts the game into a recorded state, keeping its timeline, end
+// conditions and subscribers.
+func (g *Game) restore(s *Game) {
+	keep := *g
+	*g = *s.snapshot()
+	g.timeline, g.EndRules, g.endConditions = keep.timeline, keep.EndRules, keep.endConditions
+	g.subscribers, g.nextSubscriber = keep.subscribers, keep.nextSubscriber
+}
@@ -1,5 +1,10 @@
 package maze
 
+import (
+	"fmt"
+	"strings"
+)
+
 type CellType int
 
 const (
@@ -12,8 +17,18 @@ const (
 	Hospital
 	Armory
 	Dragon
+	Key
+	Stairs
+	// Lake is water that does not flow: a river push ends on it
+	Lake
+	// Waterfall is river that carries a push on without using up its length
+	Waterfall
 )
 
+// MaxKey is the highest key number. Keys are numbered from 1, and 0 means no
+// key.
+const MaxKey = 9
+
 type Direction int
 
 const (
@@ -22,8 +37,79 @@ const (
 	Down
 	Left
 	None
+	// The diagonals are only sides of hex cells
+	UpRight
+	DownRight
+	DownLeft
+	UpLeft
 )
 
-func Opposite(d Direction) Direction {
-	return (d + 2) % 4
+// NumDirections bounds the Direction values, for arrays indexed by them.
+const NumDirections = int(UpLeft) + 1
+
+var cellTypeNames = []string{"empty", "wall", "hole", "river", "estuary", "exit", "hospital", "armory", "dragon", "key", "stairs", "lake", "waterfall"}
+
+func (t CellType) String() string {
+	if t >= 0 && int(t) < len(cellTypeNames) {
+		return cellTypeNames[t]
+	}
+	return fmt.Sprintf("celltype(%d)", int(t))
+}
+
+// Flows reports whether cells of the type have a RiverDir.
+func (t CellType) Flows() bool {
+	return t == River || t == Estuary || t == Waterfall
+}
+
+// IsWater reports whether cells of the type belong to a river network.
+func (t CellType) IsWater() bool {
+	return t.Flows() || t == Lake
+}
+
+// RegisterCellType adds a cell type defined outside this package, named for
+// saves and drawn in the text format with symbol. It is meant to be called
+// while initialising a package, and panics if the name or symbol is taken.
+func RegisterCellType(name string, symbol byte) CellType {
+	if _, err := ParseCellType(name); err == nil {
+		panic(fmt.Sprintf("maze: cell type %q registered twice", name))
+	}
+	if _, ok := cellTypeForSymbol(symbol); ok || strings.IndexByte(reservedSymbols, symbol) >= 0 {
+		panic(fmt.Sprintf("maze: symbol %q of cell type %q is taken", symbol, name))
+	}
+	t := CellType(len(cellTypeNames))
+	cellTypeNames = append(cellTypeNames, name)
+	cellSymbols[t] = symbol
+	return t
+}
+
+// reservedSymbols are used by the text format for other things than cells.
+const reservedSymbols = " T+-|^>v<0123456789"
+
+// ParseCellType is the inverse of CellType.String.
+func ParseCellType(s string) (CellType, error) {
+	for i, name := range cellTypeNames {
+		if name == s {
+			return CellType(i), nil
+		}
+	}
+	return Empty, fmt.Errorf("unknown cell type %q", s)
+}
+
+var directionNames = []string{"up", "right", "down", "left", "none", "up-right", "down-right", "down-left", "up-left"}
+
+func (d Direction) String() string {
+	if d >= 0 && int(d) < len(directionNames) {
+		return directionNames[d]
+	}
+	return fmt.Sprintf("direction(%d)", int(d))
+}
+
+// ParseDirection is the inverse of Direction.String.
+func ParseDirection(s string) (Direction, error) {
+	for i, name := range directionNames {
+		if name == s {
+			return Direction(i), nil
+		}
+	}
+	return None, fmt.Errorf("unknown direction %q", s)
 }
@@ -0,0 +1,141 @@
+package maze
+
+import (
+	"fmt"
+	"slices"
+)
+
+// A maze can have several floors of the same size stacked on top of each
+// other. They are kept in one Grid, floor 0 first, each taking Rows/Floors
+// rows, so positions stay plain (row, col) pairs. The walls between the last
+// row of a floor and the first row of the next are always closed: floors
+// only connect through stairs, and through holes when HolesDrop is set.
+//
+// A Stairs cell leads to the stairs cell at the same place on the floor
+// directly above or below; every stairs cell has exactly one of those.
+
+// FloorCount returns how many floors the maze has, at least 1.
+func (m *Maze) FloorCount() int {
+	if m.Floors < 1 {
+		return 1
+	}
+	return m.Floors
+}
+
+// FloorRows returns the number of rows of one floor.
+func (m *Maze) FloorRows() int {
+	return m.Rows / m.FloorCount()
+}
+
+// FloorOf returns the floor a row belongs to.
+func (m *Maze) FloorOf(r int) int {
+	return r / m.FloorRows()
+}
+
+// Floor returns the grid of floor f on its own, sharing the maze's cells.
+func (m *Maze) Floor(f int) [][]*Cell {
+	n := m.FloorRows()
+	return m.Grid[f*n : (f+1)*n]
+}
+
+// StairsTo returns where the stairs at (r, c) lead.
+func (m *Maze) StairsTo(r, c int) (int, int, bool) {
+	if !m.InBounds(r, c) || m.Grid[r][c].Type != Stairs {
+		return 0, 0, false
+	}
+	for _, nr := range []int{r - m.FloorRows(), r + m.FloorRows()} {
+		if m.InBounds(nr, c) && m.Grid[nr][c].Type == Stairs {
+			return nr, c, true
+		}
+	}
+	return 0, 0, false
+}
+
+// DropTo returns the cell below a hole a player falls to when holes drop,
+// or false if the hole does not drop, e.g. on the lowest floor.
+func (m *Maze) DropTo(r, c int) (int, int, bool) {
+	nr := r + m.FloorRows()
+	if !m.HolesDrop || m.Grid[r][c].Type != Hole || !m.InBounds(nr, c) {
+		return 0, 0, false
+	}
+	return nr, c, true
+}
+
+// Stack builds a maze of several floors from single-floor mazes of the same
+// size, copying their cells. The treasure is taken from the first floor.
+func Stack(floors []*Maze) (*Maze, error) {
+	if len(floors) == 0 {
+		return nil, fmt.Errorf("no floors to stack")
+	}
+	first := floors[0]
+	m := CopyMaze(first)
+	m.Floors = len(floors)
+	m.Rows = first.Rows * len(floors)
+	for i, f := range floors[1:] {
+		if f.TopologyName != first.TopologyName {
+			return nil, fmt.Errorf("floor %d is %s, want %s", i+2, f.Topology().Name(), first.Topology().Name())
+		}
+		if f.Wrap != first.Wrap {
+			return nil, fmt.Errorf("floor %d wraps as a %s, want %s", i+2, f.Wrap, first.Wrap)
+		}
+		if f.Rows != first.Rows || f.Cols != first.Cols || f.FloorCount() != 1 {
+			return nil, fmt.Errorf("floor %d is %dx%d, want %dx%d", i+2, f.Rows, f.Cols, first.Rows, first.Cols)
+		}
+		m.Grid = append(m.Grid, CopyMaze(f).Grid...)
+	}
+	for r := 0; r < m.Rows; r++ {
+		for c := 0; c < m.Cols; c++ {
+			if !slices.Equal(m.Sides(r, c), first.Sides(r%first.Rows, c)) {
+				return nil, fmt.Errorf("cell (%d,%d) changes shape when its floor is stacked", r, c)
+			}
+		}
+	}
+	m.closeFloors()
+	return m, nil
+}
+
+// closeFloors closes the walls between floors.
+func (m *Maze) closeFloors() {
+	n := m.FloorRows()
+	for r := n - 1; r < m.Rows-1; r += n {
+		for c := 0; c < m.Cols; c++ {
+			for _, d := range m.Topology().Directions() {
+				if nr, _ := m.Neighbor(r, c, d); nr > r {
+					m.AddWall(r, c, d)
+				}
+			}
+		}
+	}
+}
+
+// checkFloors reports the first way the floors of m are inconsistent.
+func (m *Maze) checkFloors() error {
+	if m.Floors < 0 || m.Rows%m.FloorCount() != 0 {
+		return fmt.Errorf("%d rows cannot be split into %d floors", m.Rows, m.Floors)
+	}
+	n := m.FloorRows()
+	for r := 0; r < m.Rows; r++ {
+		for c := 0; c < m.Cols; c++ {
+			cell := m.Grid[r][c]
+			for _, d := range m.Topology().Directions() {
+				nr, nc := m.Neighbor(r, c, d)
+				if r%n == n-1 && nr > r && m.InGrid(nr, nc) && (!cell.Walls[d] || !m.Grid[nr][nc].Walls[m.Opposite(d)]) {
+					return fmt.Errorf("cell (%d,%d): wall between floors is open", r, c)
+				}
+			}
+			if cell.Type != Stairs {
+				continue
+			}
+			ends := 0
+			for _, nr := range []int{r - n, r + n} {
+				if m.InBounds(nr, c) && m.Grid[nr][c].Type == Stairs {
+					ends++
+				}
+			}
+			if ends != 1 {
+				return fmt.Errorf("cell (%d,%d): stairs lead to %d floors, want 1", r, c, ends)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package maze
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// The JSON form of a Maze is
+//
+//	{
+//	  "rows": 7, "cols": 7,
+//	  "cells": [[{"type": "empty", "walls": "UL"}, ...], ...],
+//	  "treasure": {"row": 3, "col": 4, "on_map": true, "start_row": 3, "start_col": 4},
+//	  "floors": 2, "holes_drop": true, "topology": "hex", "wrap": "torus"
+//	}
+//
+// cells is indexed [row][col]. walls lists the closed sides of a cell as a
+// subset of "URDL", with the diagonal sides of hex cells as "QEZC" laid out
+// as on a keyboard: Q up-left, E up-right, Z down-left and C down-right.
+// river_dir (a direction such as "up" or "down-left") is only written for
+// river, waterfall and estuary cells. key is the key number of key cells and
+// locked exits, and doors maps the sides that are locked doors to the number
+// of their key, e.g. {"R": 2}; both are left out when unused. floors and
+// holes_drop are left out for single-floor mazes; rows counts the rows of
+// every floor together. topology is left out for square cells, and wrap
+// (cylinder or torus) for mazes whose edges do not wrap.
+
+type mazeJSON struct {
+	Rows      int          `json:"rows"`
+	Cols      int          `json:"cols"`
+	Cells     [][]cellJSON `json:"cells"`
+	Treasure  treasureJSON `json:"treasure"`
+	Floors    int          `json:"floors,omitempty"`
+	HolesDrop bool         `json:"holes_drop,omitempty"`
+	Topology  string       `json:"topology,omitempty"`
+	Wrap      string       `json:"wrap,omitempty"`
+}
+
+type cellJSON struct {
+	Type     string         `json:"type"`
+	Walls    string         `json:"walls"`
+	RiverDir string         `json:"river_dir,omitempty"`
+	Key      int            `json:"key,omitempty"`
+	Doors    map[string]int `json:"doors,omitempty"`
+}
+
+type treasureJSON struct {
+	Row      int  `json:"row"`
+	Col      int  `json:"col"`
+	OnMap    bool `json:"on_map"`
+	StartRow int  `json:"start_row"`
+	StartCol int  `json:"start_col"`
+}
+
+var wallLetters = []struct {
+	dir    Direction
+	letter byte
+}{
+	{Up, 'U'},
+	{Right, 'R'},
+	{Down, 'D'},
+	{Left, 'L'},
+	{UpLeft, 'Q'},
+	{UpRight, 'E'},
+	{DownLeft, 'Z'},
+	{DownRight, 'C'},
+}
+
+func (m *Maze) MarshalJSON() ([]byte, error) {
+	out := mazeJSON{
+		Rows:  m.Rows,
+		Cols:  m.Cols,
+		Cells: make([][]cellJSON, m.Rows),
+		Treasure: treasureJSON{
+			Row:      m.TreasureRow,
+			Col:      m.TreasureCol,
+			OnMap:    m.TreasureOnMap,
+			StartRow: m.TreasureStartRow,
+			StartCol: m.TreasureStartCol,
+		},
+		HolesDrop: m.HolesDrop,
+		Topology:  m.TopologyName,
+	}
+	if m.Wrap != NoWrap {
+		out.Wrap = m.Wrap.String()
+	}
+	if m.FloorCount() > 1 {
+		out.Floors = m.FloorCount()
+	}
+	for r := 0; r < m.Rows; r++ {
+		out.Cells[r] = make([]cellJSON, m.Cols)
+		for c := 0; c < m.Cols; c++ {
+			cell := m.Grid[r][c]
+			var walls strings.Builder
+			for _, w := range wallLetters {
+				if cell.Walls[w.dir] {
+					walls.WriteByte(w.letter)
+				}
+			}
+			cj := cellJSON{Type: cell.Type.String(), Walls: walls.String()}
+			if cell.Type.Flows() {
+				cj.RiverDir = cell.RiverDir.String()
+			}
+			cj.Key = cell.Key
+			for _, w := range wallLetters {
+				if key := cell.Door(w.dir); key != 0 {
+					if cj.Doors == nil {
+						cj.Doors = map[string]int{}
+					}
+					cj.Doors[string(w.letter)] = key
+				}
+			}
+			out.Cells[r][c] = cj
+		}
+	}
+	return json.Marshal(out)
+}
+
+func (m *Maze) UnmarshalJSON(data []byte) error {
+	var in mazeJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	if in.Rows < 1 || in.Cols < 1 || len(in.Cells) != in.Rows {
+		return fmt.Errorf("maze has %d rows of cells, want %d", len(in.Cells), in.Rows)
+	}
+	topology, err := TopologyByName(in.Topology)
+	if err != nil {
+		return err
+	}
+	wrap, err := ParseWrap(in.Wrap)
+	if err != nil {
+		return err
+	}
+
+	grid := make([][]*Cell, in.Rows)
+	for r := range grid {
+		if len(in.Cells[r]) != in.Cols {
+			return fmt.Errorf("maze row %d has %d cells, want %d", r, len(in.Cells[r]), in.Cols)
+		}
+		grid[r] = make([]*Cell, in.Cols)
+		for c, cj := range in.Cells[r] {
+			t, err := ParseCellType(cj.Type)
+			if err != nil {
+				return fmt.Errorf("cell (%d,%d): %w", r, c, err)
+			}
+			cell := &Cell{Type: t, Walls: map[Direction]bool{}, Key: cj.Key}
+			for _, d := range topology.Directions() {
+				cell.Walls[d] = false
+			}
+			for _, w := range wallLetters {
+				if strings.IndexByte(cj.Walls, w.letter) >= 0 {
+					cell.Walls[w.dir] = true
+				}
+			}
+			if cj.Key < 0 || cj.Key > MaxKey {
+				return fmt.Errorf("cell (%d,%d): key %d out of range", r, c, cj.Key)
+			}
+			for side, key := range cj.Doors {
+				d, ok := wallDirection(side)
+				if !ok || key < 1 || key > MaxKey || !cell.Walls[d] {
+					return fmt.Errorf("cell (%d,%d): bad door %q: %d", r, c, side, key)
+				}
+				if cell.Doors == nil {
+					cell.Doors = map[Direction]int{}
+				}
+				cell.Doors[d] = key
+			}
+			if cj.RiverDir != "" {
+				if cell.RiverDir, err = ParseDirection(cj.RiverDir); err != nil {
+					return fmt.Errorf("cell (%d,%d): %w", r, c, err)
+				}
+			}
+			grid[r][c] = cell
+		}
+	}
+
+	*m = Maze{
+		Rows:             in.Rows,
+		Cols:             in.Cols,
+		Grid:             grid,
+		TreasureRow:      in.Treasure.Row,
+		TreasureCol:      in.Treasure.Col,
+		TreasureOnMap:    in.Treasure.OnMap,
+		TreasureStartRow: in.Treasure.StartRow,
+		TreasureStartCol: in.Treasure.StartCol,
+		Floors:           in.Floors,
+		HolesDrop:        in.HolesDrop,
+		TopologyName:     in.Topology,
+		Wrap:             wrap,
+		topology:         topologyNamed(in.Topology),
+	}
+	if err := m.checkFloors(); err != nil {
+		return err
+	}
+	if err := m.checkSides(); err != nil {
+		return err
+	}
+	return m.CheckSeams()
+}
+
+// wallDirection returns the side a letter of wallLetters stands for.
+func wallDirection(letter string) (Direction, bool) {
+	for _, w := range wallLetters {
+		if letter == string(w.letter) {
+			return w.dir, true
+		}
+	}
+	return None, false
+}
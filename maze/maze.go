@@ -3,117 +3,175 @@ package maze
 type Cell struct {
 	Type     CellType
 	Walls    map[Direction]bool
-	RiverDir Direction // Only used if Type == River
+	RiverDir Direction // Only used if Type.Flows()
+	// Key is the number of the key found on a Key cell, or of the key that
+	// unlocks an Exit cell; 0 for an unlocked exit
+	Key int
+	// Doors holds the key number of each wall side that is a locked door.
+	// A door is a wall to everything but a player holding its key.
+	Doors map[Direction]int
+}
+
+// Door returns the number of the key that opens the wall on side d, or 0 if
+// it is not a door.
+func (c *Cell) Door(d Direction) int {
+	if !c.Walls[d] {
+		return 0
+	}
+	return c.Doors[d]
 }
 
 type Maze struct {
-	Size             int
+	Rows             int
+	Cols             int
 	Grid             [][]*Cell
 	TreasureRow      int
 	TreasureCol      int
 	TreasureOnMap    bool
 	TreasureStartRow int
 	TreasureStartCol int
+	Floors           int    // see FloorCount; 0 for a single floor
+	HolesDrop        bool   // holes drop players to the floor below
+	TopologyName     string // see Topology; "" for square cells
+	Wrap             Wrap   // the edges that lead round to the opposite one
+
+	topology Topology // TopologyName resolved when the maze is built or loaded
 }
 
-// CreateMaze initializes an empty maze with border walls
-func CreateMaze(size int, treasureRow, treasureCol int) *Maze {
-	grid := make([][]*Cell, size)
-	for r := 0; r < size; r++ {
-		grid[r] = make([]*Cell, size)
-		for c := 0; c < size; c++ {
-			grid[r][c] = &Cell{
-				Walls: map[Direction]bool{Up: true, Right: true, Down: true, Left: true},
-				Type:  Empty,
+// CreateMaze initializes an empty maze of square cells with border walls
+func CreateMaze(rows, cols int, treasureRow, treasureCol int) *Maze {
+	return CreateMazeOn(Square{}, rows, cols)
+}
+
+// CreateMazeOn initializes a maze of the given topology with every wall
+// closed.
+func CreateMazeOn(t Topology, rows, cols int) *Maze {
+	grid := make([][]*Cell, rows)
+	for r := 0; r < rows; r++ {
+		grid[r] = make([]*Cell, cols)
+		for c := 0; c < cols; c++ {
+			walls := map[Direction]bool{}
+			for _, d := range t.Directions() {
+				walls[d] = true
 			}
+			grid[r][c] = &Cell{Walls: walls, Type: Empty}
 		}
 	}
 
-	m := &Maze{Grid: grid, Size: size}
-
-	// Add border walls
-	for r := 0; r < size; r++ {
-		m.AddWall(r, 0, Left)
-		m.AddWall(r, size-1, Right)
+	m := &Maze{Grid: grid, Rows: rows, Cols: cols, topology: t}
+	if t.Name() != DefaultTopology {
+		m.TopologyName = t.Name()
 	}
-	for c := 0; c < size; c++ {
-		m.AddWall(0, c, Up)
-		m.AddWall(size-1, c, Down)
+	return m
+}
+
+// ApplyMask takes the cells marked true out of the maze. A disabled cell has
+// type Wall and keeps all its walls, so nothing can enter or see through it.
+// It must be applied before carving.
+func (m *Maze) ApplyMask(mask [][]bool) {
+	for r := 0; r < m.Rows && r < len(mask); r++ {
+		for c := 0; c < m.Cols && c < len(mask[r]); c++ {
+			if mask[r][c] {
+				m.Grid[r][c].Type = Wall
+				for _, d := range m.Topology().Directions() {
+					m.AddWall(r, c, d)
+				}
+			}
+		}
 	}
+}
 
-	return m
+// InGrid reports whether (r, c) lies inside the grid, disabled or not.
+func (m *Maze) InGrid(r, c int) bool {
+	return r >= 0 && r < m.Rows && c >= 0 && c < m.Cols
 }
 
+// InBounds reports whether (r, c) is a playable cell of the maze.
 func (m *Maze) InBounds(r, c int) bool {
-	return r >= 0 && r < m.Size && c >= 0 && c < m.Size
+	return m.InGrid(r, c) && m.Grid[r][c].Type != Wall
 }
 
-func (m *Maze) AddWall(r, c int, dir Direction) {
+// IsDisabled reports whether (r, c) is inside the grid but masked out.
+func (m *Maze) IsDisabled(r, c int) bool {
+	return m.InGrid(r, c) && m.Grid[r][c].Type == Wall
+}
+
+// IsEdge reports whether a playable cell borders the outside of the maze, a
+// disabled cell or another floor, i.e. the outside of its own floor.
+func (m *Maze) IsEdge(r, c int) bool {
 	if !m.InBounds(r, c) {
+		return false
+	}
+	for _, d := range m.Sides(r, c) {
+		nr, nc := m.Neighbor(r, c, d)
+		if !m.InBounds(nr, nc) || m.FloorOf(nr) != m.FloorOf(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Maze) AddWall(r, c int, dir Direction) {
+	if !m.InGrid(r, c) {
 		return
 	}
 
 	m.Grid[r][c].Walls[dir] = true
-	nr, nc := Neighbor(r, c, dir)
-	if m.InBounds(nr, nc) {
-		m.Grid[nr][nc].Walls[Opposite(dir)] = true
+	nr, nc := m.Neighbor(r, c, dir)
+	if m.InGrid(nr, nc) {
+		m.Grid[nr][nc].Walls[m.Opposite(dir)] = true
 	}
 }
 
-func Neighbor(r, c int, dir Direction) (int, int) {
-	switch dir {
-	case Up:
-		return r - 1, c
-	case Down:
-		return r + 1, c
-	case Left:
-		return r, c - 1
-	case Right:
-		return r, c + 1
+// AddDoor puts a door opened by the given key on the wall between (r, c) and
+// its neighbour in direction dir, closing the wall on both sides.
+func (m *Maze) AddDoor(r, c int, dir Direction, key int) {
+	m.AddWall(r, c, dir)
+	setDoor := func(cell *Cell, d Direction) {
+		if cell.Doors == nil {
+			cell.Doors = map[Direction]int{}
+		}
+		cell.Doors[d] = key
+	}
+	setDoor(m.Grid[r][c], dir)
+	if nr, nc := m.Neighbor(r, c, dir); m.InGrid(nr, nc) {
+		setDoor(m.Grid[nr][nc], m.Opposite(dir))
 	}
-	return r, c
 }
 
-func Delta(dir Direction) (int, int) {
-	switch dir {
-	case Up:
-		return -1, 0
-	case Down:
-		return 1, 0
-	case Left:
-		return 0, -1
-	case Right:
-		return 0, 1
-	default:
-		return 0, 0
+func (m *Maze) RemoveWallBetween(r, c int, dir Direction) {
+	m.Grid[r][c].Walls[dir] = false
+	delete(m.Grid[r][c].Doors, dir)
+	nr, nc := m.Neighbor(r, c, dir)
+	if m.InBounds(nr, nc) {
+		m.Grid[nr][nc].Walls[m.Opposite(dir)] = false
+		delete(m.Grid[nr][nc].Doors, m.Opposite(dir))
 	}
 }
 
-func DirectionFromDelta(dr, dc int) Direction {
-	switch {
-	case dr == -1 && dc == 0:
-		return Up
-	case dr == 1 && dc == 0:
-		return Down
-	case dr == 0 && dc == -1:
-		return Left
-	case dr == 0 && dc == 1:
-		return Right
+// AddOneWay makes the wall between (r, c) and its neighbour in direction dir
+// a one-way passage: open from (r, c), closed coming back.
+func (m *Maze) AddOneWay(r, c int, dir Direction) {
+	m.RemoveWallBetween(r, c, dir)
+	if nr, nc := m.Neighbor(r, c, dir); m.InGrid(nr, nc) {
+		m.Grid[nr][nc].Walls[m.Opposite(dir)] = true
 	}
-	return Up // Default fallback
 }
 
-func (m *Maze) RemoveWallBetween(r, c int, dir Direction) {
-	m.Grid[r][c].Walls[dir] = false
-	nr, nc := Neighbor(r, c, dir)
-	if m.InBounds(nr, nc) {
-		m.Grid[nr][nc].Walls[Opposite(dir)] = false
+// IsOneWay reports whether side d of (r, c) is open from one side only,
+// and which: out of (r, c) if out is true, into it otherwise.
+func (m *Maze) IsOneWay(r, c int, d Direction) (oneWay, out bool) {
+	nr, nc := m.Neighbor(r, c, d)
+	if !m.InGrid(nr, nc) {
+		return false, false
 	}
+	here, there := m.Grid[r][c].Walls[d], m.Grid[nr][nc].Walls[m.Opposite(d)]
+	return here != there, !here
 }
 
 func FindExit(m *Maze) (row, col int, found bool) {
-	for r := 0; r < m.Size; r++ {
-		for c := 0; c < m.Size; c++ {
+	for r := 0; r < m.Rows; r++ {
+		for c := 0; c < m.Cols; c++ {
 			if m.Grid[r][c].Type == Exit {
 				return r, c, true
 			}
@@ -123,29 +181,47 @@ func FindExit(m *Maze) (row, col int, found bool) {
 }
 
 func CopyMaze(original *Maze) *Maze {
-	copyGrid := make([][]*Cell, original.Size)
-	for r := 0; r < original.Size; r++ {
-		copyGrid[r] = make([]*Cell, original.Size)
-		for c := 0; c < original.Size; c++ {
+	copyGrid := make([][]*Cell, original.Rows)
+	for r := 0; r < original.Rows; r++ {
+		copyGrid[r] = make([]*Cell, original.Cols)
+		for c := 0; c < original.Cols; c++ {
 			origCell := original.Grid[r][c]
 			copyWalls := make(map[Direction]bool)
 			for dir, hasWall := range origCell.Walls {
 				copyWalls[dir] = hasWall
 			}
 
+			var copyDoors map[Direction]int
+			for dir, key := range origCell.Doors {
+				if copyDoors == nil {
+					copyDoors = map[Direction]int{}
+				}
+				copyDoors[dir] = key
+			}
+
 			copyGrid[r][c] = &Cell{
 				Type:     origCell.Type,
 				Walls:    copyWalls,
 				RiverDir: origCell.RiverDir,
+				Key:      origCell.Key,
+				Doors:    copyDoors,
 			}
 		}
 	}
 
 	return &Maze{
-		Size:          original.Size,
-		Grid:          copyGrid,
-		TreasureRow:   original.TreasureRow,
-		TreasureCol:   original.TreasureCol,
-		TreasureOnMap: original.TreasureOnMap,
+		Rows:             original.Rows,
+		Cols:             original.Cols,
+		Grid:             copyGrid,
+		TreasureRow:      original.TreasureRow,
+		TreasureCol:      original.TreasureCol,
+		TreasureOnMap:    original.TreasureOnMap,
+		TreasureStartRow: original.TreasureStartRow,
+		TreasureStartCol: original.TreasureStartCol,
+		Floors:           original.Floors,
+		HolesDrop:        original.HolesDrop,
+		TopologyName:     original.TopologyName,
+		Wrap:             original.Wrap,
+		topology:         original.Topology(),
 	}
 }
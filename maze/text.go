@@ -0,0 +1,709 @@
+package maze
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// The text form of a maze is meant to be written and read by hand. It starts
+// with directives, one per line, followed by the grid:
+//
+//	# a 2x3 maze
+//	size 2 3
+//	start P1 0 0
+//	start P2 1 2
+//	+---+---+---+
+//	| . |T.   Rv|
+//	+   +---+   +
+//	  E   O   ~v|
+//	+---+---+---+
+//
+// Every cell is three characters wide. The middle one is the cell type:
+//
+//	. empty    # disabled   O hole      R river    ~ estuary
+//	E exit     H hospital   A armory    D dragon   K key
+//	S stairs   L lake       W waterfall
+//
+// Cell types added with RegisterCellType use the symbol they were given.
+//
+// The first character is T on the cell holding the treasure, and the last one
+// is the flow direction (^ > v <, and q e z c for the diagonal sides of hex
+// cells, laid out as on a keyboard) of river, waterfall and estuary cells, or
+// the number of a key cell's key. An exit with a number is locked and needs
+// that key.
+//
+// Walls sit between cells: | and --- block both ways, blanks are open. A wall
+// that only blocks one way is drawn as the way it lets you through: > or <
+// between cells side by side, ^^^ or vvv between cells above each other. A
+// locked door is drawn as the number of its key: 2 between cells side by
+// side, -2- between cells above each other.
+//
+// The directives are
+//
+//	size ROWS COLS        required, before the grid
+//	start ID ROW COL      a player's starting cell, in turn order
+//	treasure-start R C    where a dropped treasure returns to; defaults to the T cell
+//	treasure-off R C      the treasure is carried, last seen at (R, C)
+//	floors N              the rows are N floors of equal height, top floor
+//	                      first; the wall lines between them must be
+//	                      closed, except on a torus
+//	holes-drop            holes above the lowest floor drop to the floor below
+//	topology NAME         square (the default), hex or triangle
+//	wrap NAME             cylinder or torus: leaving by the left or right
+//	                      edge, and on a torus the top or bottom one, leads
+//	                      in on the opposite edge
+//
+// Stairs are drawn as S and lead to the stairs at the same place one floor
+// up or down.
+//
+// In a triangle maze every cell is a triangle, pointing up where row plus
+// column is even. The side a triangle lacks is drawn as a closed wall.
+//
+// In a hex maze every row is drawn two characters right of the one above,
+// and the wall line above it starts two characters left of it. Each piece
+// of a wall line, between two +, is one side of a hexagon: a hexagon's
+// down-left and down-right sides lie above the up-right side of the cell
+// below and left, and the up-left side of the cell below:
+//
+//	size 2 2
+//	topology hex
+//	+ +-+-+-+-+
+//	  | .   E |
+//	  +-+ +-+-+-+
+//	    |T.   . |
+//	    +-+-+-+-+ +
+//
+// The walls on an edge that wraps are drawn on both sides of the grid, which
+// must agree: on a cylinder the first and last wall of every row, on a torus
+// also the top and bottom wall lines. A torus of several floors draws every
+// floor with top and bottom wall lines of its own, so no wall line is shared
+// between floors.
+//
+// Blank lines and lines starting with # before the grid are ignored.
+
+// PlayerStart is a player's starting cell in a text maze.
+type PlayerStart struct {
+	ID       string
+	Row, Col int
+}
+
+var cellSymbols = map[CellType]byte{
+	Empty:     '.',
+	Wall:      '#',
+	Hole:      'O',
+	River:     'R',
+	Estuary:   '~',
+	Exit:      'E',
+	Hospital:  'H',
+	Armory:    'A',
+	Dragon:    'D',
+	Key:       'K',
+	Stairs:    'S',
+	Lake:      'L',
+	Waterfall: 'W',
+}
+
+var flowSymbols = map[Direction]byte{
+	Up:        '^',
+	Right:     '>',
+	Down:      'v',
+	Left:      '<',
+	UpLeft:    'q',
+	UpRight:   'e',
+	DownLeft:  'z',
+	DownRight: 'c',
+}
+
+// WriteText writes m and the player starts in the text format. ParseText
+// reads it back into an identical maze.
+func WriteText(w io.Writer, m *Maze, starts []PlayerStart) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "size %d %d\n", m.Rows, m.Cols)
+	if m.TopologyName != "" {
+		fmt.Fprintf(&b, "topology %s\n", m.TopologyName)
+	}
+	if m.Wrap != NoWrap {
+		fmt.Fprintf(&b, "wrap %s\n", m.Wrap)
+	}
+	if m.FloorCount() > 1 {
+		fmt.Fprintf(&b, "floors %d\n", m.FloorCount())
+	}
+	if m.HolesDrop {
+		b.WriteString("holes-drop\n")
+	}
+	if !m.TreasureOnMap {
+		fmt.Fprintf(&b, "treasure-off %d %d\n", m.TreasureRow, m.TreasureCol)
+	}
+	if !m.TreasureOnMap || m.TreasureStartRow != m.TreasureRow || m.TreasureStartCol != m.TreasureCol {
+		fmt.Fprintf(&b, "treasure-start %d %d\n", m.TreasureStartRow, m.TreasureStartCol)
+	}
+	for _, s := range starts {
+		fmt.Fprintf(&b, "start %s %d %d\n", s.ID, s.Row, s.Col)
+	}
+
+	t := m.Topology()
+	for _, l := range textLayout(t, m.Rows, m.FloorCount(), m.Wrap) {
+		b.WriteString(strings.Repeat(" ", l.indent))
+		if l.wall {
+			writeWallLine(&b, m, l)
+		} else {
+			writeCellLine(&b, m, l.row)
+		}
+		b.WriteByte('\n')
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeWallLine(b *strings.Builder, m *Maze, l textLine) {
+	width := segmentWidth(m.Topology())
+	b.WriteByte('+')
+	for _, seg := range wallSegments(m.Topology(), m.Wrap, m.Rows, m.Cols, l) {
+		a, u := seg[0], seg[1]
+		var above, below bool
+		if a.ok {
+			above = m.Grid[a.r][a.c].Walls[a.d]
+		}
+		if u.ok {
+			below = m.Grid[u.r][u.c].Walls[u.d]
+		}
+		inside := a.ok && u.ok
+		switch {
+		case inside && m.Grid[u.r][u.c].Door(u.d) != 0:
+			b.WriteString(doorText(width, m.Grid[u.r][u.c].Door(u.d)))
+		case above && below, !inside && (above || below):
+			b.WriteString(strings.Repeat("-", width))
+		case above:
+			b.WriteString(strings.Repeat("^", width))
+		case below:
+			b.WriteString(strings.Repeat("v", width))
+		default:
+			b.WriteString(strings.Repeat(" ", width))
+		}
+		b.WriteByte('+')
+	}
+}
+
+func writeCellLine(b *strings.Builder, m *Maze, r int) {
+	for c := 0; c <= m.Cols; c++ {
+		// Wall between columns lc and rc
+		lc, rc := c-1, c
+		if m.Wrap >= Cylinder {
+			lc, rc = (c+m.Cols-1)%m.Cols, c%m.Cols
+		}
+		inside := lc >= 0 && rc < m.Cols
+		var left, right bool
+		if lc >= 0 {
+			left = m.Grid[r][lc].Walls[Right]
+		}
+		if rc < m.Cols {
+			right = m.Grid[r][rc].Walls[Left]
+		}
+		switch {
+		case inside && m.Grid[r][rc].Door(Left) != 0:
+			b.WriteByte(byte('0' + m.Grid[r][rc].Door(Left)))
+		case left && right, !inside && (left || right):
+			b.WriteByte('|')
+		case left:
+			b.WriteByte('<')
+		case right:
+			b.WriteByte('>')
+		default:
+			b.WriteByte(' ')
+		}
+		if c < m.Cols {
+			b.WriteString(cellText(m, r, c))
+		}
+	}
+}
+
+// textLine is one line of the grid: either a wall line between the rows
+// above and below, which are -1 or the number of rows on a border that does
+// not wrap, or the cells of row.
+type textLine struct {
+	wall         bool
+	above, below int
+	row          int
+	indent       int
+}
+
+// textLayout lists the lines of the grid. On a torus every floor is drawn
+// on its own, with its top wall line drawn again below its last row. Hex
+// rows are shifted half a cell, two characters, right of the row above, and
+// the wall line above a hex row starts half a cell left of it.
+func textLayout(t Topology, rows, floors int, wrap Wrap) []textLine {
+	_, hex := t.(Hex)
+	indent := func(k int) int {
+		if hex {
+			return 2 * k
+		}
+		return 0
+	}
+	var lines []textLine
+	if wrap >= Torus {
+		n := rows / floors
+		for top := 0; top < rows; top += n {
+			for k := 0; k <= n; k++ {
+				lines = append(lines, textLine{wall: true, above: top + (k+n-1)%n, below: top + k%n, indent: indent(k)})
+				if k < n {
+					lines = append(lines, textLine{row: top + k, indent: indent(k + 1)})
+				}
+			}
+		}
+		return lines
+	}
+	for r := 0; r <= rows; r++ {
+		lines = append(lines, textLine{wall: true, above: r - 1, below: r, indent: indent(r)})
+		if r < rows {
+			lines = append(lines, textLine{row: r, indent: indent(r + 1)})
+		}
+	}
+	return lines
+}
+
+// textSide is side d of cell (r, c), if ok.
+type textSide struct {
+	r, c int
+	d    Direction
+	ok   bool
+}
+
+// segmentWidth is how many characters a piece of a wall line takes: a
+// square cell's top side is three, a hex cell's two upper sides one each.
+func segmentWidth(t Topology) int {
+	if _, hex := t.(Hex); hex {
+		return 1
+	}
+	return 3
+}
+
+// wallSegments lists the pieces of wall line l from left to right, each as
+// the side of the cell above and the side of the cell below it stands for.
+func wallSegments(t Topology, wrap Wrap, rows, cols int, l textLine) [][2]textSide {
+	aok, bok := l.above >= 0, l.below < rows
+	var segs [][2]textSide
+	if _, hex := t.(Hex); !hex {
+		for c := 0; c < cols; c++ {
+			segs = append(segs, [2]textSide{{l.above, c, Down, aok}, {l.below, c, Up, bok}})
+		}
+		return segs
+	}
+
+	// Below the down-left and down-right sides of every hex cell above lie
+	// the up-right side of the cell below and left, and the up-left side of
+	// the cell right below. Where the edges wrap, the first piece and the
+	// last are the same wall.
+	for s := 0; s <= 2*cols; s++ {
+		c := s / 2
+		if s%2 == 1 {
+			segs = append(segs, [2]textSide{{l.above, c, DownRight, aok}, {l.below, c, UpLeft, bok}})
+			continue
+		}
+		a := textSide{l.above, c, DownLeft, aok && c < cols}
+		u := textSide{l.below, c - 1, UpRight, bok && c > 0}
+		if wrap >= Cylinder {
+			a.c, a.ok = c%cols, aok
+			u.c, u.ok = (c+cols-1)%cols, bok
+		}
+		segs = append(segs, [2]textSide{a, u})
+	}
+	return segs
+}
+
+// doorText draws the door of a key as a wall piece.
+func doorText(width, key int) string {
+	dashes := strings.Repeat("-", width/2)
+	return dashes + string(rune('0'+key)) + dashes
+}
+
+func cellText(m *Maze, r, c int) string {
+	cell := m.Grid[r][c]
+	if cell.Type == Wall {
+		return "###"
+	}
+	text := []byte{' ', cellSymbols[cell.Type], ' '}
+	if m.TreasureOnMap && m.TreasureRow == r && m.TreasureCol == c {
+		text[0] = 'T'
+	}
+	if cell.Type.Flows() {
+		if sym, ok := flowSymbols[cell.RiverDir]; ok {
+			text[2] = sym
+		}
+	}
+	if cell.Key > 0 {
+		text[2] = byte('0' + cell.Key)
+	}
+	return string(text)
+}
+
+// ParseText reads a maze written in the text format.
+func ParseText(r io.Reader) (*Maze, []PlayerStart, error) {
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	h := textHeader{rows: -1, cols: -1}
+	var grid []string
+	var m *Maze
+	var layout []textLine
+
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if grid == nil {
+			trimmed := strings.TrimSpace(line)
+			if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+				continue
+			}
+			if !strings.HasPrefix(trimmed, "+") {
+				fields := strings.Fields(trimmed)
+				if err := h.parseDirective(fields); err != nil {
+					return nil, nil, fmt.Errorf("line %d: %w", lineNo, err)
+				}
+				continue
+			}
+			if h.rows < 0 {
+				return nil, nil, fmt.Errorf("line %d: grid before size directive", lineNo)
+			}
+			if h.floors > 1 && h.rows%h.floors != 0 {
+				return nil, nil, fmt.Errorf("line %d: %d rows cannot be split into %d floors", lineNo, h.rows, h.floors)
+			}
+			t, _ := TopologyByName(h.topology)
+			m = CreateMazeOn(t, h.rows, h.cols)
+			m.Floors, m.HolesDrop, m.Wrap = h.floors, h.holesDrop, h.wrap
+			layout = textLayout(t, h.rows, m.FloorCount(), h.wrap)
+			grid = make([]string, 0, len(layout))
+		}
+		if len(grid) == len(layout) {
+			if line != "" {
+				return nil, nil, fmt.Errorf("line %d: unexpected text after grid", lineNo)
+			}
+			continue
+		}
+		l := layout[len(grid)]
+		if strings.TrimSpace(line[:min(l.indent, len(line))]) != "" {
+			return nil, nil, fmt.Errorf("line %d: grid line must start after %d blanks", lineNo, l.indent)
+		}
+		line = line[min(l.indent, len(line)):]
+		width := 4*h.cols + 1
+		if l.wall {
+			width = len(wallSegments(m.Topology(), m.Wrap, m.Rows, m.Cols, l))*(segmentWidth(m.Topology())+1) + 1
+		}
+		if len(line) > width {
+			return nil, nil, fmt.Errorf("line %d: grid line is %d characters, want %d", lineNo, len(line), width)
+		}
+		grid = append(grid, line+strings.Repeat(" ", width-len(line)))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	if h.rows < 0 {
+		return nil, nil, fmt.Errorf("missing size directive")
+	}
+	if len(grid) != len(layout) {
+		return nil, nil, fmt.Errorf("grid has %d lines, want %d", len(grid), len(layout))
+	}
+
+	if err := parseGrid(m, grid, layout); err != nil {
+		return nil, nil, err
+	}
+	if err := m.checkFloors(); err != nil {
+		return nil, nil, err
+	}
+	if err := m.checkSides(); err != nil {
+		return nil, nil, err
+	}
+	if err := m.CheckSeams(); err != nil {
+		return nil, nil, err
+	}
+
+	if h.treasureOff != nil {
+		if m.TreasureOnMap {
+			return nil, nil, fmt.Errorf("treasure-off given but the grid has a T cell")
+		}
+		m.TreasureRow, m.TreasureCol = h.treasureOff[0], h.treasureOff[1]
+	}
+	m.TreasureStartRow, m.TreasureStartCol = m.TreasureRow, m.TreasureCol
+	if h.treasureStart != nil {
+		m.TreasureStartRow, m.TreasureStartCol = h.treasureStart[0], h.treasureStart[1]
+	}
+	starts := h.starts
+	for _, s := range starts {
+		if !m.InBounds(s.Row, s.Col) {
+			return nil, nil, fmt.Errorf("start of %s at (%d,%d) is not a playable cell", s.ID, s.Row, s.Col)
+		}
+	}
+	return m, starts, nil
+}
+
+// textHeader collects the directives before the grid.
+type textHeader struct {
+	rows, cols                 int
+	starts                     []PlayerStart
+	treasureStart, treasureOff []int
+	floors                     int
+	holesDrop                  bool
+	topology                   string
+	wrap                       Wrap
+}
+
+func (h *textHeader) parseDirective(fields []string) error {
+	ints := func(args []string) ([]int, error) {
+		out := make([]int, len(args))
+		for i, a := range args {
+			n, err := strconv.Atoi(a)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %q is not a number", fields[0], a)
+			}
+			out[i] = n
+		}
+		return out, nil
+	}
+
+	switch fields[0] {
+	case "size":
+		if len(fields) != 3 {
+			return fmt.Errorf("usage: size ROWS COLS")
+		}
+		n, err := ints(fields[1:])
+		if err != nil {
+			return err
+		}
+		if n[0] < 1 || n[1] < 1 {
+			return fmt.Errorf("size must be at least 1x1")
+		}
+		h.rows, h.cols = n[0], n[1]
+	case "start":
+		if len(fields) != 4 {
+			return fmt.Errorf("usage: start ID ROW COL")
+		}
+		n, err := ints(fields[2:])
+		if err != nil {
+			return err
+		}
+		for _, s := range h.starts {
+			if s.ID == fields[1] {
+				return fmt.Errorf("duplicate start for %s", s.ID)
+			}
+		}
+		h.starts = append(h.starts, PlayerStart{ID: fields[1], Row: n[0], Col: n[1]})
+	case "treasure-start", "treasure-off":
+		if len(fields) != 3 {
+			return fmt.Errorf("usage: %s ROW COL", fields[0])
+		}
+		n, err := ints(fields[1:])
+		if err != nil {
+			return err
+		}
+		if fields[0] == "treasure-start" {
+			h.treasureStart = n
+		} else {
+			h.treasureOff = n
+		}
+	case "floors":
+		if len(fields) != 2 {
+			return fmt.Errorf("usage: floors N")
+		}
+		n, err := ints(fields[1:])
+		if err != nil {
+			return err
+		}
+		if n[0] < 1 {
+			return fmt.Errorf("floors must be at least 1")
+		}
+		h.floors = n[0]
+	case "holes-drop":
+		if len(fields) != 1 {
+			return fmt.Errorf("usage: holes-drop")
+		}
+		h.holesDrop = true
+	case "topology":
+		if len(fields) != 2 {
+			return fmt.Errorf("usage: topology NAME")
+		}
+		if _, err := TopologyByName(fields[1]); err != nil {
+			return err
+		}
+		h.topology = fields[1]
+	case "wrap":
+		if len(fields) != 2 {
+			return fmt.Errorf("usage: wrap NAME")
+		}
+		w, err := ParseWrap(fields[1])
+		if err != nil {
+			return err
+		}
+		h.wrap = w
+	default:
+		return fmt.Errorf("unknown directive %q", fields[0])
+	}
+	return nil
+}
+
+// parseGrid sets the walls and cells of m from the lines of its grid, laid
+// out as layout and with the indentation taken off.
+func parseGrid(m *Maze, grid []string, layout []textLine) error {
+	rows, cols := m.Rows, m.Cols
+	width := segmentWidth(m.Topology())
+	full, blank := strings.Repeat("-", width), strings.Repeat(" ", width)
+	treasures := 0
+	wallLines := map[[2]int]string{}
+
+	for i, l := range layout {
+		line := grid[i]
+		if !l.wall {
+			if err := parseCellLine(m, line, l.row, i, &treasures); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// A wall line drawn twice, as on a torus, must be drawn the same
+		if prev, seen := wallLines[[2]int{l.above, l.below}]; seen && prev != line {
+			return fmt.Errorf("grid line %d: the bottom wall line of a torus floor must match its top one", i+1)
+		}
+		wallLines[[2]int{l.above, l.below}] = line
+		pieces := map[[2]textSide]string{}
+		for j, seg := range wallSegments(m.Topology(), m.Wrap, rows, cols, l) {
+			text := line[j*(width+1)+1 : j*(width+1)+1+width]
+			if prev, seen := pieces[seg]; seen && prev != text {
+				return fmt.Errorf("grid line %d: the last wall of a line must match the first where the edges wrap", i+1)
+			}
+			pieces[seg] = text
+			a, u := seg[0], seg[1]
+			var up, down bool // the wall of the cell above, and of the cell below
+			door := 0
+			switch {
+			case text == full:
+				up, down = true, true
+			case text == strings.Repeat("^", width):
+				up = true
+			case text == strings.Repeat("v", width):
+				down = true
+			case text[width/2] >= '1' && text[width/2] <= '0'+MaxKey && text == doorText(width, int(text[width/2]-'0')):
+				up, down, door = true, true, int(text[width/2]-'0')
+			case text == blank:
+			default:
+				return fmt.Errorf("grid line %d: bad wall %q", i+1, text)
+			}
+			if (!a.ok || !u.ok) && text != full && text != blank {
+				return fmt.Errorf("grid line %d: one-way wall or door %q on the border", i+1, text)
+			}
+			if a.ok {
+				m.Grid[a.r][a.c].Walls[a.d] = up
+			}
+			if u.ok {
+				m.Grid[u.r][u.c].Walls[u.d] = down
+			}
+			if door != 0 {
+				m.AddDoor(u.r, u.c, u.d, door)
+			}
+		}
+	}
+
+	if treasures > 1 {
+		return fmt.Errorf("grid has %d treasure cells, want at most 1", treasures)
+	}
+	return nil
+}
+
+// parseCellLine reads the cells of row r, and the walls between them, from
+// grid line i.
+func parseCellLine(m *Maze, line string, r, i int, treasures *int) error {
+	cols := m.Cols
+	if m.Wrap >= Cylinder && line[0] != line[4*cols] {
+		return fmt.Errorf("grid line %d: the last wall of a row must match the first where the edges wrap", i+1)
+	}
+	for c := 0; c <= cols; c++ {
+		lc, rc := c-1, c
+		if m.Wrap >= Cylinder {
+			lc, rc = (c+cols-1)%cols, c%cols
+		}
+		var left, right bool // Walls[Right] of the cell to the left, Walls[Left] of the cell to the right
+		door := 0
+		switch ch := line[4*c]; {
+		case ch == '|':
+			left, right = true, true
+		case ch == '<':
+			left = true
+		case ch == '>':
+			right = true
+		case ch >= '1' && ch <= '0'+MaxKey:
+			left, right, door = true, true, int(ch-'0')
+		case ch == ' ':
+		default:
+			return fmt.Errorf("grid line %d: bad wall %q before column %d", i+1, ch, c)
+		}
+		if (lc < 0 || rc == cols) && line[4*c] != '|' && line[4*c] != ' ' {
+			return fmt.Errorf("grid line %d: one-way wall or door %q on the border", i+1, line[4*c])
+		}
+		if lc >= 0 {
+			m.Grid[r][lc].Walls[Right] = left
+		}
+		if rc < cols {
+			m.Grid[r][rc].Walls[Left] = right
+		}
+		if door != 0 {
+			m.AddDoor(r, rc, Left, door)
+		}
+		if c == cols {
+			break
+		}
+
+		text := line[4*c+1 : 4*c+4]
+		cell := m.Grid[r][c]
+		if text == "###" {
+			cell.Type = Wall
+			continue
+		}
+		t, ok := cellTypeForSymbol(text[1])
+		if !ok {
+			return fmt.Errorf("cell (%d,%d): unknown cell symbol %q", r, c, text[1])
+		}
+		cell.Type = t
+		switch text[0] {
+		case 'T':
+			*treasures++
+			m.TreasureRow, m.TreasureCol, m.TreasureOnMap = r, c, true
+		case ' ':
+		default:
+			return fmt.Errorf("cell (%d,%d): unknown marker %q", r, c, text[0])
+		}
+		if t == Key || t == Exit {
+			switch k := text[2]; {
+			case k >= '1' && k <= '0'+MaxKey:
+				cell.Key = int(k - '0')
+			case k != ' ' || t == Key:
+				return fmt.Errorf("cell (%d,%d): bad key %q", r, c, k)
+			}
+		} else if text[2] != ' ' {
+			dir, ok := directionForSymbol(text[2])
+			if !ok || !t.Flows() {
+				return fmt.Errorf("cell (%d,%d): unexpected flow %q", r, c, text[2])
+			}
+			cell.RiverDir = dir
+		}
+	}
+	return nil
+}
+
+func cellTypeForSymbol(sym byte) (CellType, bool) {
+	for t, s := range cellSymbols {
+		if s == sym {
+			return t, true
+		}
+	}
+	return Empty, false
+}
+
+func directionForSymbol(sym byte) (Direction, bool) {
+	for d, s := range flowSymbols {
+		if s == sym {
+			return d, true
+		}
+	}
+	return None, false
+}
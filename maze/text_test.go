@@ -0,0 +1,170 @@
+package maze_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"maze-game/maze"
+	"maze-game/mazegen"
+)
+
+// TestTextRoundTrip writes generated mazes of several topologies, wraps,
+// shapes and numbers of floors as text and checks that they read back the same. Seeds
+// whose random layout does not fit are skipped, as the game retries them.
+func TestTextRoundTrip(t *testing.T) {
+	for _, topology := range maze.TopologyNames() {
+		for _, wrap := range maze.WrapNames() {
+			for _, shape := range mazegen.ShapeNames {
+				for floors := 1; floors <= 2; floors++ {
+					t.Run(fmt.Sprintf("%s/%s/%s/%d", topology, wrap, shape, floors), func(t *testing.T) {
+						tried := 0
+						for seed := int64(1); tried < 3 && seed <= 50; seed++ {
+							cfg := mazegen.MazeConfig{
+								Rows:                    6,
+								Cols:                    8,
+								Shape:                   shape,
+								Topology:                topology,
+								Wrap:                    wrap,
+								NumHoles:                2,
+								NumArmories:             1,
+								NumHospitals:            1,
+								NumDragons:              1,
+								RiverLength:             5,
+								MinTreasureExitDistance: 4,
+								ExtraOpenings:           5,
+								Seed:                    seed,
+								NumKeys:                 int(seed % 3),
+								LockedExit:              seed%2 == 0,
+								NumOneWay:               int(seed % 3),
+								Rivers:                  1,
+								Tributaries:             1,
+								Lakes:                   1,
+								Waterfalls:              1,
+								Floors:                  floors,
+								HolesDrop:               floors > 1,
+							}
+							m, err := mazegen.GenerateMaze(cfg)
+							if errors.Is(err, mazegen.ErrConfig) {
+								t.Fatalf("seed %d: generating: %v", seed, err)
+							} else if err != nil {
+								continue
+							}
+							tried++
+							starts := []maze.PlayerStart{{ID: "P1", Row: m.TreasureRow, Col: m.TreasureCol}}
+
+							var text bytes.Buffer
+							if err := maze.WriteText(&text, m, starts); err != nil {
+								t.Fatalf("seed %d: writing: %v", seed, err)
+							}
+							got, gotStarts, err := maze.ParseText(bytes.NewReader(text.Bytes()))
+							if err != nil {
+								t.Fatalf("seed %d: parsing: %v\n%s", seed, err, text.String())
+							}
+							if want, have := mazeJSON(t, m), mazeJSON(t, got); want != have {
+								t.Errorf("seed %d: maze changed:\n%s", seed, text.String())
+							}
+							if len(gotStarts) != 1 || gotStarts[0] != starts[0] {
+								t.Errorf("seed %d: starts are %v, want %v", seed, gotStarts, starts)
+							}
+						}
+						if tried < 3 {
+							t.Errorf("only %d of 50 seeds could be generated", tried)
+						}
+					})
+				}
+			}
+		}
+	}
+}
+
+func TestParseTextExample(t *testing.T) {
+	example := "size 2 3\n" +
+		"start P1 0 0\n" +
+		"start P2 1 2\n" +
+		"+---+---+---+\n" +
+		"| . |T.   Rv|\n" +
+		"+   +---+   +\n" +
+		"  E   O   ~v|\n" +
+		"+---+---+---+\n"
+	m, starts, err := maze.ParseText(strings.NewReader(example))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.TreasureRow != 0 || m.TreasureCol != 1 || m.Grid[1][0].Type != maze.Exit || m.Grid[0][2].RiverDir != maze.Down {
+		t.Errorf("parsed maze with the treasure at (%d,%d)", m.TreasureRow, m.TreasureCol)
+	}
+	if len(starts) != 2 || starts[1] != (maze.PlayerStart{ID: "P2", Row: 1, Col: 2}) {
+		t.Errorf("parsed starts %v", starts)
+	}
+	var text bytes.Buffer
+	if err := maze.WriteText(&text, m, starts); err != nil {
+		t.Fatal(err)
+	}
+	if text.String() != example {
+		t.Errorf("written back as\n%s", text.String())
+	}
+}
+
+func TestParseTextHexExample(t *testing.T) {
+	example := "size 2 2\n" +
+		"topology hex\n" +
+		"+ +-+-+-+-+\n" +
+		"  | .   E |\n" +
+		"  +-+ +-+-+-+\n" +
+		"    |T.   . |\n" +
+		"    +-+-+-+-+ +\n"
+	m, _, err := maze.ParseText(strings.NewReader(example))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Topology().Name() != "hex" || m.TreasureRow != 1 || m.TreasureCol != 0 || m.Grid[0][1].Type != maze.Exit {
+		t.Errorf("parsed %s maze with the treasure at (%d,%d)", m.Topology().Name(), m.TreasureRow, m.TreasureCol)
+	}
+	var text bytes.Buffer
+	if err := maze.WriteText(&text, m, nil); err != nil {
+		t.Fatal(err)
+	}
+	if text.String() != example {
+		t.Errorf("written back as\n%s", text.String())
+	}
+}
+
+func TestParseTextErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"no size", "+---+\n| . |\n+---+\n", "grid before size"},
+		{"bad size", "size 0 1\n", "at least 1x1"},
+		{"duplicate start", "size 1 1\nstart P1 0 0\nstart P1 0 0\n", "duplicate start"},
+		{"unknown directive", "size 1 1\ncolour red\n", "unknown directive"},
+		{"no floors", "size 1 1\nfloors 0\n", "at least 1"},
+		{"uneven floors", "size 3 1\nfloors 2\n+---+\n", "cannot be split"},
+		{"open wrapped wall", "size 1 3\nwrap cylinder\n+---+---+---+\n  .   .   . |\n+---+---+---+\n", "must match the first"},
+		{"short grid", "size 1 1\n+---+\n| . |\n", "grid has 2 lines"},
+		{"unknown cell", "size 1 1\n+---+\n| ? |\n+---+\n", "unknown cell symbol"},
+		{"one-way border", "size 1 1\n+---+\n> . |\n+---+\n", "on the border"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := maze.ParseText(strings.NewReader(tt.text))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func mazeJSON(t *testing.T, m *maze.Maze) string {
+	t.Helper()
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -0,0 +1,202 @@
+package maze
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// Topology is the shape of a maze's cells and how they sit next to each
+// other. Cells are always stored in a Rows x Cols grid; the topology decides
+// which sides a cell has and which cell lies across each of them.
+type Topology interface {
+	// Name is what Maze.TopologyName holds for it.
+	Name() string
+	// Directions lists every direction a side can face, clockwise from the
+	// top. Players move, shoot and look along these.
+	Directions() []Direction
+	// Sides lists the sides the cell at (r, c) has, in the order of
+	// Directions. A direction that is not a side is a wall that never opens.
+	Sides(r, c int) []Direction
+	// Delta returns the row and column step across side d.
+	Delta(d Direction) (int, int)
+	// Opposite returns the side of the neighbour that faces back across d.
+	Opposite(d Direction) Direction
+}
+
+// DefaultTopology is used when Maze.TopologyName is empty.
+const DefaultTopology = "square"
+
+var topologies = map[string]Topology{
+	"square":   Square{},
+	"hex":      Hex{},
+	"triangle": Triangle{},
+}
+
+// TopologyNames lists the topologies a maze can have.
+func TopologyNames() []string {
+	names := make([]string, 0, len(topologies))
+	for name := range topologies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TopologyByName looks up a topology. An empty name selects DefaultTopology.
+func TopologyByName(name string) (Topology, error) {
+	if name == "" {
+		name = DefaultTopology
+	}
+	t, ok := topologies[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown maze topology %q", name)
+	}
+	return t, nil
+}
+
+// Opposite returns the direction pointing the other way. It is the side
+// facing back in every built-in topology.
+func Opposite(d Direction) Direction {
+	return opposites[d]
+}
+
+var opposites = map[Direction]Direction{
+	Up:        Down,
+	Right:     Left,
+	Down:      Up,
+	Left:      Right,
+	UpRight:   DownLeft,
+	DownRight: UpLeft,
+	DownLeft:  UpRight,
+	UpLeft:    DownRight,
+}
+
+var squareDeltas = map[Direction][2]int{
+	Up:    {-1, 0},
+	Right: {0, 1},
+	Down:  {1, 0},
+	Left:  {0, -1},
+}
+
+var squareDirs = []Direction{Up, Right, Down, Left}
+
+// Square is the usual grid of square cells with four sides.
+type Square struct{}
+
+func (Square) Name() string                   { return "square" }
+func (Square) Directions() []Direction        { return squareDirs }
+func (Square) Sides(r, c int) []Direction     { return squareDirs }
+func (Square) Delta(d Direction) (int, int)   { return squareDeltas[d][0], squareDeltas[d][1] }
+func (Square) Opposite(d Direction) Direction { return Opposite(d) }
+
+// Hex is a grid of pointy-topped hexagons. Every row is shifted half a cell
+// to the right of the one above, so the board is a parallelogram and the
+// cell up-left of (r, c) is (r-1, c).
+type Hex struct{}
+
+var hexDeltas = map[Direction][2]int{
+	UpRight:   {-1, 1},
+	Right:     {0, 1},
+	DownRight: {1, 0},
+	DownLeft:  {1, -1},
+	Left:      {0, -1},
+	UpLeft:    {-1, 0},
+}
+
+var hexDirs = []Direction{UpRight, Right, DownRight, DownLeft, Left, UpLeft}
+
+func (Hex) Name() string                   { return "hex" }
+func (Hex) Directions() []Direction        { return hexDirs }
+func (Hex) Sides(r, c int) []Direction     { return hexDirs }
+func (Hex) Delta(d Direction) (int, int)   { return hexDeltas[d][0], hexDeltas[d][1] }
+func (Hex) Opposite(d Direction) Direction { return Opposite(d) }
+
+// Triangle is a grid of triangles pointing up and down in turn, (0, 0)
+// pointing up. A triangle pointing up has no top side and one pointing down
+// no bottom side.
+type Triangle struct{}
+
+var (
+	upTriangleSides   = []Direction{Right, Down, Left}
+	downTriangleSides = []Direction{Up, Right, Left}
+)
+
+// PointsUp reports whether the triangle at (r, c) points up.
+func (Triangle) PointsUp(r, c int) bool {
+	return (r+c)%2 == 0
+}
+
+func (Triangle) Name() string            { return "triangle" }
+func (Triangle) Directions() []Direction { return squareDirs }
+
+func (t Triangle) Sides(r, c int) []Direction {
+	if t.PointsUp(r, c) {
+		return upTriangleSides
+	}
+	return downTriangleSides
+}
+
+func (Triangle) Delta(d Direction) (int, int)   { return squareDeltas[d][0], squareDeltas[d][1] }
+func (Triangle) Opposite(d Direction) Direction { return Opposite(d) }
+
+// Topology returns the topology of the maze, square if its name is unknown.
+// It is resolved once when the maze is built, copied or read from JSON; a
+// maze put together any other way looks it up on every call.
+func (m *Maze) Topology() Topology {
+	if m.topology != nil {
+		return m.topology
+	}
+	return topologyNamed(m.TopologyName)
+}
+
+func topologyNamed(name string) Topology {
+	if t, err := TopologyByName(name); err == nil {
+		return t
+	}
+	return Square{}
+}
+
+// Sides lists the sides of the cell at (r, c).
+func (m *Maze) Sides(r, c int) []Direction {
+	return m.Topology().Sides(r, c)
+}
+
+// Neighbor returns the cell across side dir of (r, c), which may lie outside
+// the grid unless that edge wraps.
+func (m *Maze) Neighbor(r, c int, dir Direction) (int, int) {
+	dr, dc := m.Topology().Delta(dir)
+	return m.Wrap.Fold(r+dr, c+dc, r, m.Rows, m.Cols, m.FloorCount())
+}
+
+// Opposite returns the side of a neighbour facing back across side d.
+func (m *Maze) Opposite(d Direction) Direction {
+	return m.Topology().Opposite(d)
+}
+
+// DirectionTo returns the side of (r, c) that (nr, nc) lies across, or false
+// if they are not neighbours.
+func (m *Maze) DirectionTo(r, c, nr, nc int) (Direction, bool) {
+	for _, d := range m.Sides(r, c) {
+		if ar, ac := m.Neighbor(r, c, d); ar == nr && ac == nc {
+			return d, true
+		}
+	}
+	return None, false
+}
+
+// checkSides reports the first wall that is open although the cell has no
+// side there.
+func (m *Maze) checkSides() error {
+	for r := 0; r < m.Rows; r++ {
+		for c := 0; c < m.Cols; c++ {
+			sides := m.Sides(r, c)
+			for _, d := range m.Topology().Directions() {
+				if !m.Grid[r][c].Walls[d] && !slices.Contains(sides, d) {
+					return fmt.Errorf("cell (%d,%d) has no %s side, but the wall there is open", r, c, d)
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package maze
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Wrap says which edges of a maze lead round to the opposite edge. Walls on
+// a joined edge are ordinary walls between the cells on either side and can
+// be carved like any other.
+type Wrap int
+
+const (
+	NoWrap Wrap = iota
+	// Cylinder joins the left edge to the right one.
+	Cylinder
+	// Torus also joins the top edge of each floor to its bottom one.
+	Torus
+)
+
+var wrapNames = []string{"none", "cylinder", "torus"}
+
+func (w Wrap) String() string {
+	if w < 0 || int(w) >= len(wrapNames) {
+		return fmt.Sprintf("Wrap(%d)", int(w))
+	}
+	return wrapNames[w]
+}
+
+// WrapNames lists the names ParseWrap accepts.
+func WrapNames() []string {
+	return slices.Clone(wrapNames)
+}
+
+// ParseWrap looks up a wrap by name. An empty name is NoWrap.
+func ParseWrap(s string) (Wrap, error) {
+	if s == "" {
+		return NoWrap, nil
+	}
+	if i := slices.Index(wrapNames, s); i >= 0 {
+		return Wrap(i), nil
+	}
+	return NoWrap, fmt.Errorf("unknown wrap %q", s)
+}
+
+// Fold brings (r, c), one step away from a cell in row from, back onto a
+// grid of the given size across the edges w joins. Rows only wrap within the
+// floor of row from. Positions off an edge that is not joined are returned
+// as they are.
+func (w Wrap) Fold(r, c, from, rows, cols, floors int) (int, int) {
+	if w >= Cylinder && cols > 0 {
+		c = (c%cols + cols) % cols
+	}
+	if n := rows / max(floors, 1); w >= Torus && n > 0 {
+		top := from / n * n
+		r = top + ((r-top)%n+n)%n
+	}
+	return r, c
+}
+
+// Wraps reports whether side d of (r, c) lies on a joined edge, so the cell
+// across it is not the one next to it in the grid.
+func (m *Maze) Wraps(r, c int, d Direction) bool {
+	dr, dc := m.Topology().Delta(d)
+	nr, nc := m.Neighbor(r, c, d)
+	return nr != r+dr || nc != c+dc
+}
+
+// Distance counts the rows and columns between two cells as if they were on
+// the same floor, going round the joined edges where that is shorter, plus
+// one for each floor between them. Walls are not taken into account.
+func (m *Maze) Distance(r0, c0, r1, c1 int) int {
+	n := m.FloorRows()
+	f0, f1 := m.FloorOf(r0), m.FloorOf(r1)
+	dr, dc := abs((r1-f1*n)-(r0-f0*n)), abs(c1-c0)
+	if m.Wrap >= Cylinder {
+		dc = min(dc, m.Cols-dc)
+	}
+	if m.Wrap >= Torus {
+		dr = min(dr, n-dr)
+	}
+	return dr + dc + abs(f1-f0)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// CheckSeams reports the first side whose neighbour has no side facing back,
+// as happens when triangles meet across a joined edge pointing the same way.
+func (m *Maze) CheckSeams() error {
+	for r := 0; r < m.Rows; r++ {
+		for c := 0; c < m.Cols; c++ {
+			for _, d := range m.Sides(r, c) {
+				nr, nc := m.Neighbor(r, c, d)
+				if m.InGrid(nr, nc) && !slices.Contains(m.Sides(nr, nc), m.Opposite(d)) {
+					return fmt.Errorf("cell (%d,%d) has no side facing back to (%d,%d) in a %s maze", nr, nc, r, c, m.Wrap)
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,380 @@
+package mazegen
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+
+	"maze-game/maze"
+)
+
+// Carver turns a fully walled maze into a perfect maze by removing walls.
+type Carver interface {
+	Carve(m *maze.Maze, rng *rand.Rand)
+}
+
+// DefaultCarver is used when MazeConfig.Algorithm is empty.
+const DefaultCarver = "backtracker"
+
+var carvers = map[string]Carver{
+	"backtracker": RecursiveBacktracker{},
+	"prim":        Prim{},
+	"kruskal":     Kruskal{},
+	"wilson":      Wilson{},
+	"eller":       Eller{},
+	"growingtree": GrowingTree{NewestBias: 0.75},
+	"binarytree":  BinaryTree{},
+}
+
+// CarverNames lists the algorithms that can be chosen in MazeConfig.
+func CarverNames() []string {
+	names := make([]string, 0, len(carvers))
+	for name := range carvers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CarverByName looks up a carving algorithm. An empty name selects DefaultCarver.
+func CarverByName(name string) (Carver, error) {
+	if name == "" {
+		name = DefaultCarver
+	}
+	c, ok := carvers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown maze algorithm %q", name)
+	}
+	return c, nil
+}
+
+type cellPos struct{ r, c int }
+
+// openDirs lists the sides of p that lead to a playable cell.
+func openDirs(m *maze.Maze, p cellPos) []maze.Direction {
+	var dirs []maze.Direction
+	for _, d := range m.Sides(p.r, p.c) {
+		if nr, nc := m.Neighbor(p.r, p.c, d); m.InBounds(nr, nc) {
+			dirs = append(dirs, d)
+		}
+	}
+	return dirs
+}
+
+// forwardDirs lists the sides of p that lead to a playable cell later in
+// row-major order, so that going through every cell lists each inner wall
+// once.
+func forwardDirs(m *maze.Maze, p cellPos) []maze.Direction {
+	var dirs []maze.Direction
+	for _, d := range openDirs(m, p) {
+		if nr, nc := m.Neighbor(p.r, p.c, d); nr > p.r || (nr == p.r && nc > p.c) {
+			dirs = append(dirs, d)
+		}
+	}
+	return dirs
+}
+
+// sideTowards returns the side of p across which the playable cell (r, c)
+// lies, going round the edges the maze joins, e.g. from column 0 to column -1.
+func sideTowards(m *maze.Maze, p cellPos, r, c int) (maze.Direction, bool) {
+	r, c = m.Wrap.Fold(r, c, p.r, m.Rows, m.Cols, m.FloorCount())
+	d, ok := m.DirectionTo(p.r, p.c, r, c)
+	return d, ok && m.InBounds(r, c)
+}
+
+// RecursiveBacktracker carves with a randomised depth-first search, giving
+// long winding corridors with few dead ends.
+type RecursiveBacktracker struct{}
+
+func (RecursiveBacktracker) Carve(m *maze.Maze, rng *rand.Rand) {
+	carveMaze(m, rng)
+}
+
+// Prim grows the maze from a random cell by connecting random frontier cells,
+// giving many short dead ends.
+type Prim struct{}
+
+func (Prim) Carve(m *maze.Maze, rng *rand.Rand) {
+	inMaze := make([][]bool, m.Rows)
+	for i := range inMaze {
+		inMaze[i] = make([]bool, m.Cols)
+	}
+	onFrontier := make(map[cellPos]bool)
+	var frontier []cellPos
+
+	add := func(r, c int) {
+		inMaze[r][c] = true
+		for _, d := range m.Sides(r, c) {
+			nr, nc := m.Neighbor(r, c, d)
+			p := cellPos{nr, nc}
+			if m.InBounds(nr, nc) && !inMaze[nr][nc] && !onFrontier[p] {
+				onFrontier[p] = true
+				frontier = append(frontier, p)
+			}
+		}
+	}
+
+	start := randomOpenCell(m, rng)
+	add(start.r, start.c)
+	for len(frontier) > 0 {
+		i := rng.Intn(len(frontier))
+		p := frontier[i]
+		frontier[i] = frontier[len(frontier)-1]
+		frontier = frontier[:len(frontier)-1]
+
+		var in []maze.Direction
+		for _, d := range m.Sides(p.r, p.c) {
+			nr, nc := m.Neighbor(p.r, p.c, d)
+			if m.InBounds(nr, nc) && inMaze[nr][nc] {
+				in = append(in, d)
+			}
+		}
+		m.RemoveWallBetween(p.r, p.c, in[rng.Intn(len(in))])
+		add(p.r, p.c)
+	}
+}
+
+// Kruskal removes walls in random order whenever they separate two
+// unconnected regions, giving an even spread of short branches.
+type Kruskal struct{}
+
+func (Kruskal) Carve(m *maze.Maze, rng *rand.Rand) {
+	cols := m.Cols
+	parent := make([]int, m.Rows*cols)
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	type edge struct {
+		r, c int
+		dir  maze.Direction
+	}
+	var edges []edge
+	for _, p := range openCells(m) {
+		for _, d := range forwardDirs(m, p) {
+			edges = append(edges, edge{p.r, p.c, d})
+		}
+	}
+	rng.Shuffle(len(edges), func(i, j int) { edges[i], edges[j] = edges[j], edges[i] })
+
+	for _, e := range edges {
+		nr, nc := m.Neighbor(e.r, e.c, e.dir)
+		a, b := find(e.r*cols+e.c), find(nr*cols+nc)
+		if a != b {
+			parent[a] = b
+			m.RemoveWallBetween(e.r, e.c, e.dir)
+		}
+	}
+}
+
+// Wilson carves with loop-erased random walks, producing a uniformly random
+// spanning tree with no directional bias.
+type Wilson struct{}
+
+func (Wilson) Carve(m *maze.Maze, rng *rand.Rand) {
+	inMaze := make([][]bool, m.Rows)
+	for i := range inMaze {
+		inMaze[i] = make([]bool, m.Cols)
+	}
+	cells := openCells(m)
+	first := cells[rng.Intn(len(cells))]
+	inMaze[first.r][first.c] = true
+	remaining := len(cells) - 1
+
+	// A cell with no playable neighbours can never be walked out of
+	for _, p := range cells {
+		if !inMaze[p.r][p.c] && len(openDirs(m, p)) == 0 {
+			inMaze[p.r][p.c] = true
+			remaining--
+		}
+	}
+
+	// exit records the direction last taken out of each cell on the walk;
+	// overwriting it is what erases loops.
+	exit := make(map[cellPos]maze.Direction)
+
+	for remaining > 0 {
+		var start cellPos
+		for {
+			start = cells[rng.Intn(len(cells))]
+			if !inMaze[start.r][start.c] {
+				break
+			}
+		}
+
+		for k := range exit {
+			delete(exit, k)
+		}
+		p := start
+		for !inMaze[p.r][p.c] {
+			dirs := openDirs(m, p)
+			d := dirs[rng.Intn(len(dirs))]
+			exit[p] = d
+			p.r, p.c = m.Neighbor(p.r, p.c, d)
+		}
+
+		p = start
+		for !inMaze[p.r][p.c] {
+			d := exit[p]
+			inMaze[p.r][p.c] = true
+			remaining--
+			m.RemoveWallBetween(p.r, p.c, d)
+			p.r, p.c = m.Neighbor(p.r, p.c, d)
+		}
+	}
+}
+
+// Eller builds the maze one row at a time, randomly joining sets
+// horizontally and dropping at least one passage per set to the next row.
+// Sets whose cells have no side straight down, as with some triangles, are
+// joined later by connectRegions. On a cylinder rows are joined across the
+// seam too; on a torus they start at a random row and go round, so that the
+// passages down cross it as well.
+type Eller struct{}
+
+func (Eller) Carve(m *maze.Maze, rng *rand.Rand) {
+	set := make([]int, m.Cols)
+	next := 1
+
+	first := 0
+	if m.Wrap >= maze.Torus {
+		first = rng.Intn(m.Rows)
+	}
+	for i := 0; i < m.Rows; i++ {
+		r := (first + i) % m.Rows
+		last := i == m.Rows-1
+
+		// Disabled cells belong to no set; new cells get a fresh one
+		for c := range set {
+			if !m.InBounds(r, c) {
+				set[c] = 0
+			} else if set[c] == 0 {
+				set[c] = next
+				next++
+			}
+		}
+
+		// Join adjacent cells of different sets
+		for c := 0; c < m.Cols; c++ {
+			nc := (c + 1) % m.Cols
+			right, ok := sideTowards(m, cellPos{r, c}, r, c+1)
+			if !ok || set[c] == 0 || set[nc] == 0 || set[c] == set[nc] || (!last && rng.Intn(2) == 0) {
+				continue
+			}
+			m.RemoveWallBetween(r, c, right)
+			old := set[nc]
+			for i := range set {
+				if set[i] == old {
+					set[i] = set[c]
+				}
+			}
+		}
+		if last {
+			break
+		}
+
+		// Every set needs at least one passage down where the row below allows it
+		members := make(map[int][]int)
+		var order []int
+		for c, s := range set {
+			if _, ok := sideTowards(m, cellPos{r, c}, r+1, c); s == 0 || !ok {
+				continue
+			}
+			if _, ok := members[s]; !ok {
+				order = append(order, s)
+			}
+			members[s] = append(members[s], c)
+		}
+		below := make([]int, m.Cols)
+		for _, s := range order {
+			cols := members[s]
+			rng.Shuffle(len(cols), func(i, j int) { cols[i], cols[j] = cols[j], cols[i] })
+			drops := 1 + rng.Intn(len(cols))
+			for _, c := range cols[:drops] {
+				down, _ := sideTowards(m, cellPos{r, c}, r+1, c)
+				m.RemoveWallBetween(r, c, down)
+				below[c] = s
+			}
+		}
+		set = below
+	}
+}
+
+// GrowingTree keeps a list of active cells and extends either the newest one
+// or a random one. NewestBias is the chance of picking the newest: 1 behaves
+// like the backtracker, 0 like Prim.
+type GrowingTree struct {
+	NewestBias float64
+}
+
+func (g GrowingTree) Carve(m *maze.Maze, rng *rand.Rand) {
+	visited := make([][]bool, m.Rows)
+	for i := range visited {
+		visited[i] = make([]bool, m.Cols)
+	}
+
+	start := randomOpenCell(m, rng)
+	visited[start.r][start.c] = true
+	active := []cellPos{start}
+
+	for len(active) > 0 {
+		i := len(active) - 1
+		if rng.Float64() >= g.NewestBias {
+			i = rng.Intn(len(active))
+		}
+		p := active[i]
+
+		carved := false
+		sides := m.Sides(p.r, p.c)
+		for _, j := range rng.Perm(len(sides)) {
+			dir := sides[j]
+			nr, nc := m.Neighbor(p.r, p.c, dir)
+			if m.InBounds(nr, nc) && !visited[nr][nc] {
+				m.RemoveWallBetween(p.r, p.c, dir)
+				visited[nr][nc] = true
+				active = append(active, cellPos{nr, nc})
+				carved = true
+				break
+			}
+		}
+		if !carved {
+			active = append(active[:i], active[i+1:]...)
+		}
+	}
+}
+
+// BinaryTree opens each cell either upwards or leftwards. It is the easiest
+// layout to learn: the top row and left column are always straight corridors.
+// Upwards is towards the cell in the same column of the row above. Where the
+// edges wrap, the corridors run through a random cell instead and the cells
+// next to the seam open across it.
+type BinaryTree struct{}
+
+func (BinaryTree) Carve(m *maze.Maze, rng *rand.Rand) {
+	var r0, c0 int
+	if m.Wrap >= maze.Cylinder {
+		c0 = rng.Intn(m.Cols)
+	}
+	if m.Wrap >= maze.Torus {
+		r0 = rng.Intn(m.Rows)
+	}
+	for _, p := range openCells(m) {
+		var options []maze.Direction
+		if d, ok := sideTowards(m, p, p.r-1, p.c); ok && p.r != r0 {
+			options = append(options, d)
+		}
+		if d, ok := sideTowards(m, p, p.r, p.c-1); ok && p.c != c0 {
+			options = append(options, d)
+		}
+		if len(options) > 0 {
+			m.RemoveWallBetween(p.r, p.c, options[rng.Intn(len(options))])
+		}
+	}
+}
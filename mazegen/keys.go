@@ -0,0 +1,145 @@
+package mazegen
+
+import (
+	"fmt"
+	"math/rand"
+
+	"maze-game/maze"
+)
+
+// placeKeysAndDoors puts numKeys locked doors into the maze, and key k
+// where it can be reached from a random root cell holding only keys below
+// k, so every door can be opened in order. Each door cuts off a small part
+// of the maze that holds no key. With lockedExit, one more key is placed
+// and the exit needs it.
+func placeKeysAndDoors(m *maze.Maze, numKeys int, lockedExit bool, rng *rand.Rand) error {
+	total := numKeys
+	if lockedExit {
+		total++
+	}
+	if total > maze.MaxKey {
+		return configErrorf("cannot place %d keys, at most %d fit", total, maze.MaxKey)
+	}
+	if total == 0 {
+		return nil
+	}
+
+	root := randomOpenCell(m, rng)
+	for k := 1; k <= numKeys; k++ {
+		if !placeDoor(m, root, k, rng) {
+			return fmt.Errorf("no wall left to put door %d on", k)
+		}
+		if err := placeKey(m, root, k, rng); err != nil {
+			return err
+		}
+	}
+	if lockedExit {
+		exitRow, exitCol, found := maze.FindExit(m)
+		if !found {
+			return configErrorf("cannot lock the exit without an exit")
+		}
+		if err := placeKey(m, root, total, rng); err != nil {
+			return err
+		}
+		m.Grid[exitRow][exitCol].Key = total
+	}
+	return nil
+}
+
+// placeDoor tries the open walls inside the part reachable from root
+// without key k in random order, and keeps the first one that cuts off
+// between 2 cells and a third of that part without cutting off a key.
+func placeDoor(m *maze.Maze, root cellPos, k int, rng *rand.Rand) bool {
+	region := reach(m, root, k-1)
+	type edge struct {
+		r, c int
+		dir  maze.Direction
+	}
+	var edges []edge
+	for _, p := range openCells(m) {
+		if !region[p] || isRiver(m.Grid[p.r][p.c].Type) {
+			continue
+		}
+		for _, d := range forwardDirs(m, p) {
+			nr, nc := m.Neighbor(p.r, p.c, d)
+			if !m.Grid[p.r][p.c].Walls[d] && !isRiver(m.Grid[nr][nc].Type) {
+				edges = append(edges, edge{p.r, p.c, d})
+			}
+		}
+	}
+	rng.Shuffle(len(edges), func(i, j int) { edges[i], edges[j] = edges[j], edges[i] })
+
+	for _, e := range edges {
+		m.AddDoor(e.r, e.c, e.dir, k)
+		after := reach(m, root, k-1)
+		cut := len(region) - len(after)
+		if cut >= 2 && cut <= len(region)/3 && !cutsOffKey(m, region, after) {
+			return true
+		}
+		m.RemoveWallBetween(e.r, e.c, e.dir)
+	}
+	return false
+}
+
+func isRiver(t maze.CellType) bool {
+	return t.IsWater()
+}
+
+// cutsOffKey reports whether a key lies in region but not in after.
+func cutsOffKey(m *maze.Maze, region, after map[cellPos]bool) bool {
+	for p := range region {
+		if !after[p] && m.Grid[p.r][p.c].Type == maze.Key {
+			return true
+		}
+	}
+	return false
+}
+
+// placeKey puts key k on an empty cell reachable from root without it.
+func placeKey(m *maze.Maze, root cellPos, k int, rng *rand.Rand) error {
+	region := reach(m, root, k-1)
+	var cells []cellPos
+	for _, p := range emptyCells(m) {
+		if region[p] && !(m.TreasureOnMap && p.r == m.TreasureRow && p.c == m.TreasureCol) {
+			cells = append(cells, p)
+		}
+	}
+	if len(cells) == 0 {
+		return fmt.Errorf("no empty cell left for key %d", k)
+	}
+	p := cells[rng.Intn(len(cells))]
+	m.Grid[p.r][p.c].Type = maze.Key
+	m.Grid[p.r][p.c].Key = k
+	return nil
+}
+
+// reach returns the cells that can be walked to from root through open
+// walls and the doors of keys 1 to keys, taking stairs and dropping holes.
+func reach(m *maze.Maze, root cellPos, keys int) map[cellPos]bool {
+	seen := map[cellPos]bool{root: true}
+	queue := []cellPos{root}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		cell := m.Grid[p.r][p.c]
+		if r, c, ok := m.StairsTo(p.r, p.c); ok && !seen[cellPos{r, c}] {
+			seen[cellPos{r, c}] = true
+			queue = append(queue, cellPos{r, c})
+		}
+		if r, c, ok := m.DropTo(p.r, p.c); ok && !seen[cellPos{r, c}] {
+			seen[cellPos{r, c}] = true
+			queue = append(queue, cellPos{r, c})
+		}
+		for _, d := range m.Sides(p.r, p.c) {
+			if door := cell.Door(d); cell.Walls[d] && (door == 0 || door > keys) {
+				continue
+			}
+			nr, nc := m.Neighbor(p.r, p.c, d)
+			if n := (cellPos{nr, nc}); m.InBounds(nr, nc) && !seen[n] {
+				seen[n] = true
+				queue = append(queue, n)
+			}
+		}
+	}
+	return seen
+}
@@ -1,51 +1,197 @@
 package mazegen
 
 import (
+	"errors"
+	"fmt"
 	"math/rand"
 	"time"
 
 	"maze-game/maze"
 )
 
-func init() {
-	rand.Seed(time.Now().UnixNano())
-}
-
 type MazeConfig struct {
-	Size                    int
+	Size                    int // shorthand for a square board
+	Rows                    int // overrides Size when set
+	Cols                    int // overrides Size when set
+	Shape                   string
+	Topology                string   // cell shape, see maze.TopologyNames; empty for square
+	Wrap                    string   // edges leading round to the opposite one, see maze.WrapNames; empty for none
+	Mask                    [][]bool // disabled cells; overrides Shape when set
 	NumHoles                int
 	NumArmories             int
 	NumHospitals            int
 	NumDragons              int
 	RiverLength             int
+	Rivers                  int  // separate rivers, each with its own estuary; 0 for 1
+	Tributaries             int  // shorter rivers flowing into another one
+	Lakes                   int  // river cells where the flow stops
+	Waterfalls              int  // river cells that carry you on for free
+	NumKeys                 int  // locked doors, each with its key
+	NumOneWay               int  // passages that can only be walked one way
+	LockedExit              bool // the exit needs a key of its own
+	Floors                  int  // floors of Rows x Cols joined by stairs; 0 for 1
+	HolesDrop               bool // holes drop players to the floor below
 	ExtraOpenings           int
 	MinTreasureExitDistance int
+	Seed                    int64  // 0 picks a seed from the clock
+	Algorithm               string // carver name, see CarverNames; empty for DefaultCarver
+	MaxAttempts             int    // mazes game may generate before giving up; 0 for DefaultMaxAttempts
 }
 
-// GenerateMaze creates a solvable maze with given features.
-func GenerateMaze(cfg MazeConfig) *maze.Maze {
-	m := maze.CreateMaze(cfg.Size, 0, 0)
-	carveMaze(m)
-	openUpMaze(m, cfg.ExtraOpenings)
+// ErrConfig marks generation errors caused by the config itself rather than
+// an unlucky random layout, so retrying with other random draws cannot help.
+var ErrConfig = errors.New("invalid maze config")
 
-	placeRandomEdgeCellOfType(m, maze.Exit)
+func configErrorf(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrConfig, fmt.Sprintf(format, args...))
+}
 
-	for i := 0; i < cfg.NumHoles; i++ {
-		placeRandomCellOfType(m, maze.Hole)
+// DefaultMaxAttempts bounds how many candidate mazes are generated and
+// validated before generation fails.
+const DefaultMaxAttempts = 1000
+
+// Dimensions returns the board's rows and columns, falling back to Size.
+func (cfg MazeConfig) Dimensions() (rows, cols int) {
+	rows, cols = cfg.Rows, cfg.Cols
+	if rows <= 0 {
+		rows = cfg.Size
 	}
-	for i := 0; i < cfg.NumHospitals; i++ {
-		placeRandomCellOfType(m, maze.Hospital)
+	if cols <= 0 {
+		cols = cfg.Size
 	}
-	for i := 0; i < cfg.NumArmories; i++ {
-		placeRandomCellOfType(m, maze.Armory)
+	return rows, cols
+}
+
+// NewRand returns a generator for seed. A zero seed is replaced by one taken
+// from the clock; the seed actually used is returned so it can be reported
+// and the maze reproduced later.
+func NewRand(seed int64) (*rand.Rand, int64) {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
 	}
-	for i := 0; i < cfg.NumDragons; i++ {
-		placeRandomCellOfType(m, maze.Dragon)
+	return rand.New(rand.NewSource(seed)), seed
+}
+
+// GenerateMaze creates a solvable maze with given features. The same config,
+// including Seed, always produces the same maze.
+func GenerateMaze(cfg MazeConfig) (*maze.Maze, error) {
+	rng, _ := NewRand(cfg.Seed)
+	return GenerateMazeWithRand(cfg, rng)
+}
+
+// GenerateMazeWithRand is GenerateMaze drawing from rng instead of cfg.Seed,
+// so callers can generate several mazes from one seeded stream. The error
+// names the first constraint that could not be met.
+func GenerateMazeWithRand(cfg MazeConfig, rng *rand.Rand) (*maze.Maze, error) {
+	rows, cols := cfg.Dimensions()
+	if rows < 1 || cols < 1 {
+		return nil, configErrorf("maze of %dx%d cells is too small", rows, cols)
+	}
+	carver, err := CarverByName(cfg.Algorithm)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrConfig, err)
+	}
+	topology, err := maze.TopologyByName(cfg.Topology)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrConfig, err)
+	}
+	wrap, err := maze.ParseWrap(cfg.Wrap)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrConfig, err)
+	}
+	// Narrower than three cells, a cell would meet the same neighbour on
+	// both sides of a seam, or itself
+	if wrap >= maze.Cylinder && cols < 3 || wrap >= maze.Torus && rows < 3 {
+		return nil, configErrorf("a %s maze of %dx%d cells is too small", wrap, rows, cols)
+	}
+	// Stacked on an odd number of rows, the triangles of the next floor
+	// would point the other way
+	if _, ok := topology.(maze.Triangle); ok && cfg.Floors > 1 && rows%2 == 1 {
+		return nil, configErrorf("triangle mazes with several floors need an even number of rows, not %d", rows)
+	}
+	mask := cfg.Mask
+	if mask == nil {
+		if mask, err = ShapeMask(cfg.Shape, rows, cols); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrConfig, err)
+		}
 	}
 
-	placeTreasure(m, cfg.MinTreasureExitDistance)
+	// Every floor is carved on its own and the floors joined by stairs
+	floors := make([]*maze.Maze, max(cfg.Floors, 1))
+	for i := range floors {
+		if floors[i], err = carveFloor(topology, wrap, rows, cols, mask, carver, cfg.ExtraOpenings, rng); err != nil {
+			return nil, err
+		}
+	}
+	m, err := maze.Stack(floors)
+	if err != nil {
+		return nil, err
+	}
+	m.HolesDrop = cfg.HolesDrop
+	if err := placeStairs(m, rng); err != nil {
+		return nil, err
+	}
 
-	placeSmartRiver(m, cfg.RiverLength)
+	if err := placeRandomEdgeCellOfType(m, maze.Exit, rng); err != nil {
+		return nil, err
+	}
+
+	features := []struct {
+		t     maze.CellType
+		count int
+	}{
+		{maze.Hole, cfg.NumHoles},
+		{maze.Hospital, cfg.NumHospitals},
+		{maze.Armory, cfg.NumArmories},
+		{maze.Dragon, cfg.NumDragons},
+	}
+	for _, f := range features {
+		for i := 0; i < f.count; i++ {
+			if err := placeRandomCellOfType(m, f.t, rng); err != nil {
+				return nil, fmt.Errorf("placing %s %d of %d: %w", cellTypeNames[f.t], i+1, f.count, err)
+			}
+		}
+	}
+
+	if err := placeTreasure(m, cfg.MinTreasureExitDistance, rng); err != nil {
+		return nil, err
+	}
+
+	if err := placeRivers(m, cfg, rng); err != nil {
+		return nil, err
+	}
+
+	if err := placeKeysAndDoors(m, cfg.NumKeys, cfg.LockedExit, rng); err != nil {
+		return nil, err
+	}
+
+	if err := placeOneWays(m, cfg.NumOneWay, rng); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
 
-	return m
+// carveFloor carves one floor of the maze.
+func carveFloor(topology maze.Topology, wrap maze.Wrap, rows, cols int, mask [][]bool, carver Carver, extraOpenings int, rng *rand.Rand) (*maze.Maze, error) {
+	m := maze.CreateMazeOn(topology, rows, cols)
+	m.Wrap = wrap
+	// Triangles only meet across a seam on an even number of columns, or
+	// rows for a torus
+	if err := m.CheckSeams(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrConfig, err)
+	}
+	m.ApplyMask(mask)
+	if regions := countRegions(m); regions == 0 {
+		return nil, configErrorf("mask disables every cell of the maze")
+	} else if regions > 1 {
+		return nil, configErrorf("mask splits the maze into %d separate regions", regions)
+	}
+
+	carver.Carve(m, rng)
+	connectRegions(m, rng)
+	if err := openUpMaze(m, extraOpenings, rng); err != nil {
+		return nil, err
+	}
+	return m, nil
 }
@@ -0,0 +1,133 @@
+package mazegen
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"maze-game/maze"
+)
+
+func testConfig(seed int64) MazeConfig {
+	return MazeConfig{
+		Size:                    7,
+		NumHoles:                2,
+		NumArmories:             1,
+		NumHospitals:            1,
+		NumDragons:              1,
+		RiverLength:             6,
+		MinTreasureExitDistance: 5,
+		Seed:                    seed,
+	}
+}
+
+// generate returns the maze of cfg as JSON, or the error it failed with.
+func generate(t *testing.T, cfg MazeConfig) string {
+	t.Helper()
+	m, err := GenerateMaze(cfg)
+	if errors.Is(err, ErrConfig) {
+		t.Fatalf("seed %d: %v", cfg.Seed, err)
+	} else if err != nil {
+		return "error: " + err.Error()
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestSameSeedSameMaze(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(*MazeConfig)
+	}{
+		{"default", func(*MazeConfig) {}},
+		{"small", func(cfg *MazeConfig) { cfg.Size, cfg.RiverLength, cfg.MinTreasureExitDistance = 5, 4, 3 }},
+		{"open", func(cfg *MazeConfig) { cfg.ExtraOpenings = 10 }},
+		{"rectangle", func(cfg *MazeConfig) { cfg.Rows, cfg.Cols = 5, 9 }},
+		{"shape", func(cfg *MazeConfig) { cfg.Shape = "ring" }},
+		{"keys", func(cfg *MazeConfig) { cfg.NumKeys, cfg.LockedExit = 2, true }},
+		{"floors", func(cfg *MazeConfig) { cfg.Floors, cfg.HolesDrop = 2, true }},
+		{"hex", func(cfg *MazeConfig) { cfg.Topology = "hex" }},
+		{"triangle", func(cfg *MazeConfig) { cfg.Topology = "triangle" }},
+		{"torus", func(cfg *MazeConfig) { cfg.Wrap = "torus" }},
+		{"rivers", func(cfg *MazeConfig) { cfg.Tributaries, cfg.Lakes = 1, 1 }},
+		{"one-way", func(cfg *MazeConfig) { cfg.NumOneWay = 2 }},
+	}
+	for _, tt := range tests {
+		for _, algorithm := range CarverNames() {
+			t.Run(tt.name+"/"+algorithm, func(t *testing.T) {
+				seen := map[string]bool{}
+				for seed := int64(1); seed <= 5; seed++ {
+					cfg := testConfig(seed)
+					cfg.Algorithm = algorithm
+					tt.change(&cfg)
+					first, second := generate(t, cfg), generate(t, cfg)
+					if first != second {
+						t.Fatalf("seed %d gave two different results", seed)
+					}
+					seen[first] = true
+				}
+				if len(seen) < 2 {
+					t.Errorf("5 seeds gave %d different results", len(seen))
+				}
+			})
+		}
+	}
+}
+
+func TestNewRand(t *testing.T) {
+	if _, seed := NewRand(42); seed != 42 {
+		t.Errorf("NewRand(42) used seed %d", seed)
+	}
+	if _, seed := NewRand(0); seed == 0 {
+		t.Error("NewRand(0) did not pick a seed")
+	}
+	a, _ := NewRand(7)
+	b, _ := NewRand(7)
+	for i := 0; i < 10; i++ {
+		if x, y := a.Int63(), b.Int63(); x != y {
+			t.Fatalf("draw %d: %d and %d from the same seed", i, x, y)
+		}
+	}
+}
+
+// TestCarversMakePerfectMazes checks that every carver, with the regions it
+// leaves joined up as GenerateMaze does, links all cells by exactly one path
+// on every topology and wrap.
+func TestCarversMakePerfectMazes(t *testing.T) {
+	for _, algorithm := range CarverNames() {
+		carver, _ := CarverByName(algorithm)
+		for _, topology := range maze.TopologyNames() {
+			for _, wrap := range maze.WrapNames() {
+				t.Run(algorithm+"/"+topology+"/"+wrap, func(t *testing.T) {
+					top, _ := maze.TopologyByName(topology)
+					w, _ := maze.ParseWrap(wrap)
+					for seed := int64(1); seed <= 3; seed++ {
+						m := maze.CreateMazeOn(top, 6, 6)
+						m.Wrap = w
+						rng, _ := NewRand(seed)
+						carver.Carve(m, rng)
+						connectRegions(m, rng)
+
+						cells, passages := openCells(m), 0
+						for _, p := range cells {
+							for _, d := range forwardDirs(m, p) {
+								if !m.Grid[p.r][p.c].Walls[d] {
+									passages++
+								}
+							}
+						}
+						if reached := len(reach(m, cells[0], 0)); reached != len(cells) {
+							t.Errorf("seed %d: %d of %d cells reached", seed, reached, len(cells))
+						}
+						if passages != len(cells)-1 {
+							t.Errorf("seed %d: %d passages between %d cells", seed, passages, len(cells))
+						}
+					}
+				})
+			}
+		}
+	}
+}
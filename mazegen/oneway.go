@@ -0,0 +1,68 @@
+package mazegen
+
+import (
+	"fmt"
+	"math/rand"
+
+	"maze-game/maze"
+)
+
+// placeOneWays makes n walls one-way, open from one cell and closed from the
+// other: either a closed wall opened from one side, like a ledge to drop
+// off, or an open passage that closes behind you. A wall is only made
+// one-way between cells that could walk to each other without keys before,
+// and still can afterwards, so it never strands a player or gets round a
+// door.
+func placeOneWays(m *maze.Maze, n int, rng *rand.Rand) error {
+	if n == 0 {
+		return nil
+	}
+	type edge struct {
+		r, c int
+		dir  maze.Direction
+	}
+	var edges []edge
+	for _, p := range openCells(m) {
+		if isRiver(m.Grid[p.r][p.c].Type) {
+			continue
+		}
+		for _, d := range m.Sides(p.r, p.c) {
+			// Floors only connect through stairs and holes, so the walls
+			// between them stay closed both ways
+			nr, nc := m.Neighbor(p.r, p.c, d)
+			if m.InBounds(nr, nc) && m.FloorOf(nr) == m.FloorOf(p.r) && !isRiver(m.Grid[nr][nc].Type) && m.Grid[p.r][p.c].Door(d) == 0 {
+				edges = append(edges, edge{p.r, p.c, d})
+			}
+		}
+	}
+	rng.Shuffle(len(edges), func(i, j int) { edges[i], edges[j] = edges[j], edges[i] })
+
+	placed := 0
+	for _, e := range edges {
+		if placed == n {
+			break
+		}
+		from := cellPos{e.r, e.c}
+		nr, nc := m.Neighbor(e.r, e.c, e.dir)
+		to := cellPos{nr, nc}
+		// Either side of the wall may already have been made one-way
+		if oneWay, _ := m.IsOneWay(e.r, e.c, e.dir); oneWay || !reach(m, from, 0)[to] {
+			continue
+		}
+		wasOpen := !m.Grid[e.r][e.c].Walls[e.dir]
+		m.AddOneWay(e.r, e.c, e.dir)
+		if reach(m, to, 0)[from] {
+			placed++
+			continue
+		}
+		if wasOpen {
+			m.RemoveWallBetween(e.r, e.c, e.dir)
+		} else {
+			m.AddWall(e.r, e.c, e.dir)
+		}
+	}
+	if placed < n {
+		return fmt.Errorf("only %d of %d one-way passages fit", placed, n)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package mazegen
+
+import (
+	"fmt"
+	"math/rand"
+
+	"maze-game/maze"
+)
+
+// placeRivers lays out the river system: cfg.Rivers rivers of at least
+// cfg.RiverLength cells, each ending in its own estuary, then tributaries
+// flowing into them, then lakes and waterfalls made from river cells. Every
+// estuary and lake lies on the carved maze, so it stays connected to the
+// rest of it; whether the treasure can still be reached from each is left
+// to the game's setup checks, as for the single river.
+func placeRivers(m *maze.Maze, cfg MazeConfig, rng *rand.Rand) error {
+	rivers := max(cfg.Rivers, 1)
+	tributaryLength := max(2, cfg.RiverLength/2)
+
+	// Each river has at least RiverLength-1 cells above its estuary, so
+	// this many always fit
+	if fit := rivers*(cfg.RiverLength-1) + cfg.Tributaries*tributaryLength; cfg.Lakes+cfg.Waterfalls > 0 && cfg.Lakes+cfg.Waterfalls > fit {
+		return configErrorf("cannot turn %d river cells into lakes and waterfalls, at most %d fit", cfg.Lakes+cfg.Waterfalls, fit)
+	}
+	for i := 0; i < rivers; i++ {
+		if err := placeSmartRiver(m, cfg.RiverLength, rng); err != nil {
+			if rivers == 1 {
+				return err
+			}
+			return fmt.Errorf("placing river %d of %d: %w", i+1, rivers, err)
+		}
+	}
+
+	for i := 0; i < cfg.Tributaries; i++ {
+		if !placeTributary(m, tributaryLength, rng) {
+			return fmt.Errorf("tributary %d of %d cannot fit: no run of %d empty cells flows into a river", i+1, cfg.Tributaries, tributaryLength)
+		}
+	}
+
+	convertRiverCells(m, maze.Lake, cfg.Lakes, rng)
+	convertRiverCells(m, maze.Waterfall, cfg.Waterfalls, rng)
+	return nil
+}
+
+// placeTributary grows a river of length cells upstream from a random river
+// cell, through empty cells with no wall between them, and reports whether
+// one fitted.
+func placeTributary(m *maze.Maze, length int, rng *rand.Rand) bool {
+	var mouths []cellPos
+	for _, p := range openCells(m) {
+		if m.Grid[p.r][p.c].Type == maze.River {
+			mouths = append(mouths, p)
+		}
+	}
+
+	for attempt := 0; attempt < 1000 && len(mouths) > 0; attempt++ {
+		p := mouths[rng.Intn(len(mouths))]
+		path := []cellPos{p}
+		used := map[cellPos]bool{p: true}
+		for len(path) <= length {
+			sides := m.Sides(p.r, p.c)
+			next, found := cellPos{}, false
+			for _, j := range rng.Perm(len(sides)) {
+				d := sides[j]
+				nr, nc := m.Neighbor(p.r, p.c, d)
+				n := cellPos{nr, nc}
+				if m.InBounds(nr, nc) && m.Grid[nr][nc].Type == maze.Empty && !used[n] &&
+					!m.Grid[p.r][p.c].Walls[d] && !m.Grid[nr][nc].Walls[m.Opposite(d)] {
+					next, found = n, true
+					break
+				}
+			}
+			if !found {
+				break
+			}
+			path = append(path, next)
+			used[next] = true
+			p = next
+		}
+		if len(path) <= length {
+			continue
+		}
+
+		// Each new cell flows into the one it was grown from
+		for i := 1; i < len(path); i++ {
+			r, c := path[i].r, path[i].c
+			m.Grid[r][c].Type = maze.River
+			m.Grid[r][c].RiverDir, _ = m.DirectionTo(r, c, path[i-1].r, path[i-1].c)
+		}
+		return true
+	}
+	return false
+}
+
+// convertRiverCells turns n random river cells into t. Lakes stop the flow,
+// so they lose their direction.
+func convertRiverCells(m *maze.Maze, t maze.CellType, n int, rng *rand.Rand) {
+	if n == 0 {
+		return
+	}
+	var cells []cellPos
+	for _, p := range openCells(m) {
+		if m.Grid[p.r][p.c].Type == maze.River {
+			cells = append(cells, p)
+		}
+	}
+	rng.Shuffle(len(cells), func(i, j int) { cells[i], cells[j] = cells[j], cells[i] })
+	for _, p := range cells[:min(n, len(cells))] {
+		m.Grid[p.r][p.c].Type = t
+		if t == maze.Lake {
+			m.Grid[p.r][p.c].RiverDir = maze.None
+		}
+	}
+}
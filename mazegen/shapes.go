@@ -0,0 +1,145 @@
+package mazegen
+
+import (
+	"fmt"
+	"math/rand"
+
+	"maze-game/maze"
+)
+
+// ShapeNames lists the board outlines that can be chosen in MazeConfig.
+var ShapeNames = []string{"rect", "l", "ring", "cross"}
+
+// ShapeMask returns the mask of disabled cells for a named board outline.
+// A true entry takes that cell out of the maze.
+func ShapeMask(shape string, rows, cols int) ([][]bool, error) {
+	mask := make([][]bool, rows)
+	for r := range mask {
+		mask[r] = make([]bool, cols)
+	}
+
+	switch shape {
+	case "", "rect":
+	case "l":
+		// Drop the top-right quadrant
+		for r := 0; r < rows/2; r++ {
+			for c := (cols + 1) / 2; c < cols; c++ {
+				mask[r][c] = true
+			}
+		}
+	case "ring":
+		// Drop the middle, leaving a band a third of the board wide
+		br, bc := (rows+2)/3, (cols+2)/3
+		for r := br; r < rows-br; r++ {
+			for c := bc; c < cols-bc; c++ {
+				mask[r][c] = true
+			}
+		}
+	case "cross":
+		// Drop the four corners, leaving a plus sign
+		br, bc := rows/3, cols/3
+		for r := 0; r < rows; r++ {
+			for c := 0; c < cols; c++ {
+				if (r < br || r >= rows-br) && (c < bc || c >= cols-bc) {
+					mask[r][c] = true
+				}
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown maze shape %q", shape)
+	}
+	return mask, nil
+}
+
+// openCells lists every playable cell of the maze in row-major order.
+func openCells(m *maze.Maze) []cellPos {
+	var cells []cellPos
+	for r := 0; r < m.Rows; r++ {
+		for c := 0; c < m.Cols; c++ {
+			if m.InBounds(r, c) {
+				cells = append(cells, cellPos{r, c})
+			}
+		}
+	}
+	return cells
+}
+
+func randomOpenCell(m *maze.Maze, rng *rand.Rand) cellPos {
+	cells := openCells(m)
+	return cells[rng.Intn(len(cells))]
+}
+
+// countRegions counts the groups of playable cells that touch each other,
+// ignoring inner walls.
+func countRegions(m *maze.Maze) int {
+	seen := make(map[cellPos]bool)
+	regions := 0
+	for _, start := range openCells(m) {
+		if seen[start] {
+			continue
+		}
+		regions++
+		seen[start] = true
+		stack := []cellPos{start}
+		for len(stack) > 0 {
+			p := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			for _, d := range openDirs(m, p) {
+				nr, nc := m.Neighbor(p.r, p.c, d)
+				if n := (cellPos{nr, nc}); !seen[n] {
+					seen[n] = true
+					stack = append(stack, n)
+				}
+			}
+		}
+	}
+	return regions
+}
+
+// connectRegions knocks down walls between playable cells until every cell is
+// reachable. Carvers that work row by row can leave a masked board split in
+// pieces; on an already connected maze this does nothing.
+func connectRegions(m *maze.Maze, rng *rand.Rand) {
+	cells := openCells(m)
+	index := make(map[cellPos]int, len(cells))
+	for i, p := range cells {
+		index[p] = i
+	}
+	parent := make([]int, len(cells))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	type edge struct {
+		p   cellPos
+		dir maze.Direction
+	}
+	var closed []edge
+	for _, p := range cells {
+		for _, d := range forwardDirs(m, p) {
+			nr, nc := m.Neighbor(p.r, p.c, d)
+			if m.Grid[p.r][p.c].Walls[d] {
+				closed = append(closed, edge{p, d})
+			} else {
+				parent[find(index[p])] = find(index[cellPos{nr, nc}])
+			}
+		}
+	}
+
+	rng.Shuffle(len(closed), func(i, j int) { closed[i], closed[j] = closed[j], closed[i] })
+	for _, e := range closed {
+		nr, nc := m.Neighbor(e.p.r, e.p.c, e.dir)
+		a, b := find(index[e.p]), find(index[cellPos{nr, nc}])
+		if a != b {
+			parent[a] = b
+			m.RemoveWallBetween(e.p.r, e.p.c, e.dir)
+		}
+	}
+}
@@ -1,136 +1,137 @@
 package mazegen
 
 import (
+	"fmt"
 	"math/rand"
 
 	"maze-game/maze"
 )
 
-func carveMaze(m *maze.Maze) {
-	size := m.Size
-	visited := make([][]bool, size)
+func carveMaze(m *maze.Maze, rng *rand.Rand) {
+	visited := make([][]bool, m.Rows)
 	for i := range visited {
-		visited[i] = make([]bool, size)
+		visited[i] = make([]bool, m.Cols)
 	}
 
 	var dfs func(r, c int)
 	dfs = func(r, c int) {
 		visited[r][c] = true
-		dirs := rand.Perm(4)
-		for _, d := range dirs {
-			dr, dc := maze.Delta(maze.Direction(d))
-			nr, nc := r+dr, c+dc
+		sides := m.Sides(r, c)
+		for _, i := range rng.Perm(len(sides)) {
+			d := sides[i]
+			nr, nc := m.Neighbor(r, c, d)
 			if m.InBounds(nr, nc) && !visited[nr][nc] {
-				m.RemoveWallBetween(r, c, maze.Direction(d))
+				m.RemoveWallBetween(r, c, d)
 				dfs(nr, nc)
 			}
 		}
 	}
 
-	dfs(0, 0)
+	start := openCells(m)[0]
+	dfs(start.r, start.c)
 }
 
-func placeRandomCellOfType(m *maze.Maze, t maze.CellType) {
-	for {
-		r := rand.Intn(m.Size)
-		c := rand.Intn(m.Size)
-		if m.Grid[r][c].Type == maze.Empty {
-			m.Grid[r][c].Type = t
-			return
-		}
-	}
+// cellTypeNames is used in generation errors.
+var cellTypeNames = map[maze.CellType]string{
+	maze.Hole:     "hole",
+	maze.Exit:     "exit",
+	maze.Hospital: "hospital",
+	maze.Armory:   "armory",
+	maze.Dragon:   "dragon",
+	maze.Key:      "key",
+	maze.Stairs:   "stairs",
 }
 
-func placeRandomEdgeCellOfType(m *maze.Maze, t maze.CellType) {
-	for {
-		var r, c int
-		edge := rand.Intn(4) // 0=top row, 1=bottom row, 2=left col, 3=right col
-
-		switch edge {
-		case 0: // top row
-			r = 0
-			c = rand.Intn(m.Size)
-		case 1: // bottom row
-			r = m.Size - 1
-			c = rand.Intn(m.Size)
-		case 2: // left column
-			r = rand.Intn(m.Size)
-			c = 0
-		case 3: // right column
-			r = rand.Intn(m.Size)
-			c = m.Size - 1
-		}
-
-		if m.Grid[r][c].Type == maze.Empty {
-			m.Grid[r][c].Type = t
-			return
+func emptyCells(m *maze.Maze) []cellPos {
+	var cells []cellPos
+	for _, p := range openCells(m) {
+		if m.Grid[p.r][p.c].Type == maze.Empty {
+			cells = append(cells, p)
 		}
 	}
+	return cells
 }
 
-func placeTreasure(m *maze.Maze, minDist int) {
-	type point struct{ r, c int }
+func placeRandomCellOfType(m *maze.Maze, t maze.CellType, rng *rand.Rand) error {
+	cells := emptyCells(m)
+	if len(cells) == 0 {
+		return configErrorf("no empty cell left for the %s", cellTypeNames[t])
+	}
+	p := cells[rng.Intn(len(cells))]
+	m.Grid[p.r][p.c].Type = t
+	return nil
+}
 
-	var exit point
-	found := false
-	for r := 0; r < m.Size && !found; r++ {
-		for c := 0; c < m.Size; c++ {
-			if m.Grid[r][c].Type == maze.Exit {
-				exit = point{r, c}
-				found = true
-				break
-			}
+// placeRandomEdgeCellOfType places t on an empty cell on the outline of the
+// maze, which for masked boards includes cells next to disabled ones.
+func placeRandomEdgeCellOfType(m *maze.Maze, t maze.CellType, rng *rand.Rand) error {
+	var edges []cellPos
+	for _, p := range emptyCells(m) {
+		if m.IsEdge(p.r, p.c) {
+			edges = append(edges, p)
 		}
 	}
+	// A torus has no edge unless the mask leaves one
+	if len(edges) == 0 && m.Wrap == maze.Torus {
+		edges = emptyCells(m)
+	}
+	if len(edges) == 0 {
+		return configErrorf("no empty edge cell left for the %s", cellTypeNames[t])
+	}
+	p := edges[rng.Intn(len(edges))]
+	m.Grid[p.r][p.c].Type = t
+	return nil
+}
 
+func placeTreasure(m *maze.Maze, minDist int, rng *rand.Rand) error {
+	exitRow, exitCol, found := maze.FindExit(m)
 	if !found {
-		// fallback: no exit found, place treasure randomly
-		for {
-			r := rand.Intn(m.Size)
-			c := rand.Intn(m.Size)
-			if m.Grid[r][c].Type == maze.Empty {
-				m.TreasureRow = r
-				m.TreasureCol = c
-				m.TreasureOnMap = true
-				return
-			}
-		}
+		return configErrorf("cannot place the treasure without an exit")
 	}
 
 	// Find a valid position at least minDist away from the exit
-	for tries := 0; tries < 1000; tries++ {
-		r := rand.Intn(m.Size)
-		c := rand.Intn(m.Size)
-
-		if m.Grid[r][c].Type == maze.Empty &&
-			abs(r-exit.r)+abs(c-exit.c) >= minDist {
-
-			m.TreasureRow = r
-			m.TreasureCol = c
-			m.TreasureOnMap = true
-			m.TreasureStartRow = r
-			m.TreasureStartCol = c
-			return
+	var candidates []cellPos
+	for _, p := range emptyCells(m) {
+		if m.Distance(p.r, p.c, exitRow, exitCol) >= minDist {
+			candidates = append(candidates, p)
 		}
 	}
+	if len(candidates) == 0 {
+		return fmt.Errorf("no empty cell at least %d steps from the exit for the treasure", minDist)
+	}
+
+	p := candidates[rng.Intn(len(candidates))]
+	m.TreasureRow = p.r
+	m.TreasureCol = p.c
+	m.TreasureOnMap = true
+	m.TreasureStartRow = p.r
+	m.TreasureStartCol = p.c
+	return nil
 }
 
-func abs(n int) int {
-	if n < 0 {
-		return -n
+func openUpMaze(m *maze.Maze, extraOpenings int, rng *rand.Rand) error {
+	closed := 0
+	for _, p := range openCells(m) {
+		for _, d := range forwardDirs(m, p) {
+			if m.Grid[p.r][p.c].Walls[d] {
+				closed++
+			}
+		}
+	}
+	if extraOpenings > closed {
+		return configErrorf("cannot add %d extra openings, only %d inner walls are left", extraOpenings, closed)
 	}
-	return n
-}
 
-func openUpMaze(m *maze.Maze, extraOpenings int) {
-	size := m.Size
 	for i := 0; i < extraOpenings; {
-		r := rand.Intn(size)
-		c := rand.Intn(size)
-		dirs := rand.Perm(4)
-		for _, d := range dirs {
-			dir := maze.Direction(d)
-			nr, nc := maze.Neighbor(r, c, dir)
+		r := rng.Intn(m.Rows)
+		c := rng.Intn(m.Cols)
+		if !m.InBounds(r, c) {
+			continue
+		}
+		sides := m.Sides(r, c)
+		for _, j := range rng.Perm(len(sides)) {
+			dir := sides[j]
+			nr, nc := m.Neighbor(r, c, dir)
 			if !m.InBounds(nr, nc) {
 				continue
 			}
@@ -141,14 +142,22 @@ func openUpMaze(m *maze.Maze, extraOpenings int) {
 			}
 		}
 	}
+	return nil
 }
 
-func placeSmartRiver(m *maze.Maze, length int) {
-	dirs := []maze.Direction{maze.Up, maze.Right, maze.Down, maze.Left}
+func placeSmartRiver(m *maze.Maze, length int, rng *rand.Rand) error {
+	if length < 2 {
+		return configErrorf("river of length %d is too short, it needs at least 2 cells", length)
+	}
+	if free := len(emptyCells(m)); length > free {
+		return configErrorf("river of length %d cannot fit, only %d empty cells are left", length, free)
+	}
+
+	dirs := m.Topology().Directions()
 
 	for attempt := 0; attempt < 100000; attempt++ {
-		startR := rand.Intn(m.Size)
-		startC := rand.Intn(m.Size)
+		startR := rng.Intn(m.Rows)
+		startC := rng.Intn(m.Cols)
 
 		if m.Grid[startR][startC].Type != maze.Empty {
 			continue
@@ -158,23 +167,22 @@ func placeSmartRiver(m *maze.Maze, length int) {
 		used := map[[2]int]bool{
 			{startR, startC}: true,
 		}
-		dir := dirs[rand.Intn(4)]
+		dir := dirs[rng.Intn(len(dirs))]
 		r, c := startR, startC
 
 		for i := 1; i < length+2; i++ {
 			// Occasionally change direction
-			if rand.Float64() < 0.5 {
-				dir = dirs[rand.Intn(4)]
+			if rng.Float64() < 0.5 {
+				dir = dirs[rng.Intn(len(dirs))]
 			}
 
-			dr, dc := maze.Delta(dir)
-			nr, nc := r+dr, c+dc
+			nr, nc := m.Neighbor(r, c, dir)
 			nextPos := [2]int{nr, nc}
 
 			if !m.InBounds(nr, nc) ||
 				m.Grid[nr][nc].Type != maze.Empty ||
 				m.Grid[r][c].Walls[dir] ||
-				m.Grid[nr][nc].Walls[maze.Opposite(dir)] ||
+				m.Grid[nr][nc].Walls[m.Opposite(dir)] ||
 				used[nextPos] {
 				break
 			}
@@ -195,13 +203,37 @@ func placeSmartRiver(m *maze.Maze, length int) {
 			if i == len(path)-1 {
 				m.Grid[r][c].Type = maze.Estuary
 				pr, pc := path[i-1][0], path[i-1][1]
-				m.Grid[r][c].RiverDir = maze.DirectionFromDelta(r-pr, c-pc)
+				m.Grid[r][c].RiverDir, _ = m.DirectionTo(pr, pc, r, c)
 			} else {
 				m.Grid[r][c].Type = maze.River
 				nr, nc := path[i+1][0], path[i+1][1]
-				m.Grid[r][c].RiverDir = maze.DirectionFromDelta(nr-r, nc-c)
+				m.Grid[r][c].RiverDir, _ = m.DirectionTo(r, c, nr, nc)
 			}
 		}
-		return
+		return nil
+	}
+	return fmt.Errorf("river of length %d cannot fit: no unbroken run of empty cells that long was found", length)
+}
+
+// placeStairs joins every floor to the one below with a staircase, on a
+// place that is empty on both.
+func placeStairs(m *maze.Maze, rng *rand.Rand) error {
+	n := m.FloorRows()
+	for f := 0; f+1 < m.FloorCount(); f++ {
+		var cells []cellPos
+		for r := f * n; r < (f+1)*n; r++ {
+			for c := 0; c < m.Cols; c++ {
+				if m.InBounds(r, c) && m.Grid[r][c].Type == maze.Empty && m.Grid[r+n][c].Type == maze.Empty {
+					cells = append(cells, cellPos{r, c})
+				}
+			}
+		}
+		if len(cells) == 0 {
+			return configErrorf("no place left for stairs from floor %d to %d", f+1, f+2)
+		}
+		p := cells[rng.Intn(len(cells))]
+		m.Grid[p.r][p.c].Type = maze.Stairs
+		m.Grid[p.r+n][p.c].Type = maze.Stairs
 	}
+	return nil
 }
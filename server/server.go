@@ -0,0 +1,347 @@
+// Package server hosts games over TCP so that every player can play from
+// their own client. The server is the game master: it only accepts commands
+// from the player whose turn it is, tells each player the outcome of their
+// own moves and announces to everyone what is public.
+//
+// The protocol is line based. A client joins a session by name; the first
+// client to name a session creates it:
+//
+//	JOIN <session> <player>
+//
+// Everything else the client sends is a game command for Game.PerformAction
+// (UP, DOWN, LEFT, RIGHT, SHOOT <dir>), or QUIT to leave. Disconnecting
+// counts as leaving, and a player who leaves a running game is eliminated.
+//
+// The server sends
+//
+//	WELCOME <player> <session>
+//	WAITING <joined> <needed>    until the session is full
+//	START <player> ...           the players in turn order
+//	TURN <player>
+//	YOU <message>                outcome of your own command
+//	EVENT <message>              something you learn from another player's turn
+//	END <winner> <reason>        winner is - when nobody won
+//	ERR <message>
+package server
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+
+	"maze-game/game"
+)
+
+// Server runs any number of sessions, each with a fixed number of players.
+type Server struct {
+	// Players is the number of players a session waits for before starting.
+	Players int
+	// NewGame creates the game for a full session, with the players' names in
+	// join order.
+	NewGame func(names []string) (*game.Game, error)
+
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+func New(players int, newGame func(names []string) (*game.Game, error)) *Server {
+	return &Server{Players: players, NewGame: newGame, sessions: map[string]*session{}}
+}
+
+// ListenAndServe listens on the TCP address and serves clients until the
+// listener fails.
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts clients on l until it is closed.
+func (s *Server) Serve(l net.Listener) error {
+	defer l.Close()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.handle(conn)
+	}
+}
+
+// clientBuffer is how many lines may queue for a client before it is
+// dropped as too slow.
+const clientBuffer = 64
+
+type client struct {
+	name   string
+	conn   net.Conn
+	out    chan string
+	closed bool
+}
+
+func newClient(conn net.Conn) *client {
+	c := &client{conn: conn, out: make(chan string, clientBuffer)}
+	go func() {
+		w := bufio.NewWriter(conn)
+		for line := range c.out {
+			w.WriteString(line + "\n")
+			if len(c.out) == 0 {
+				w.Flush()
+			}
+		}
+		w.Flush()
+		conn.Close()
+	}()
+	return c
+}
+
+// send queues a line for the client. Callers must hold the session lock, or
+// own the client before it joins one.
+func (c *client) send(format string, args ...any) {
+	if c.closed {
+		return
+	}
+	select {
+	case c.out <- fmt.Sprintf(format, args...):
+	default:
+		log.Printf("dropping slow client %s", c.name)
+		c.close()
+	}
+}
+
+func (c *client) close() {
+	if !c.closed {
+		c.closed = true
+		close(c.out)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	c := newClient(conn)
+	scanner := bufio.NewScanner(conn)
+
+	var sess *session
+	for sess == nil {
+		if !scanner.Scan() {
+			c.close()
+			return
+		}
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 || strings.ToUpper(fields[0]) != "JOIN" {
+			c.send("ERR expected JOIN <session> <player>")
+			continue
+		}
+		joined, err := s.join(fields[1], fields[2], c)
+		if err != nil {
+			c.send("ERR %v", err)
+			continue
+		}
+		sess = joined
+	}
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if strings.ToUpper(line) == "QUIT" {
+			break
+		}
+		if sess.act(c, line) {
+			s.drop(sess)
+		}
+	}
+	if sess.leave(c) {
+		s.drop(sess)
+	}
+}
+
+func (s *Server) join(name, player string, c *client) (*session, error) {
+	s.mu.Lock()
+	sess, ok := s.sessions[name]
+	if !ok {
+		sess = &session{name: name, server: s}
+		s.sessions[name] = sess
+	}
+	s.mu.Unlock()
+
+	err := sess.join(player, c)
+	if sess.ended() {
+		s.drop(sess)
+	}
+	return sess, err
+}
+
+// drop forgets a finished session so its name can be used again.
+func (s *Server) drop(sess *session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sessions[sess.name] == sess {
+		delete(s.sessions, sess.name)
+	}
+}
+
+type session struct {
+	name   string
+	server *Server
+
+	mu      sync.Mutex
+	clients []*client
+	game    *game.Game
+	over    bool
+}
+
+func (sess *session) ended() bool {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	return sess.over
+}
+
+func (sess *session) join(player string, c *client) error {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	if sess.over {
+		return fmt.Errorf("session %s has ended", sess.name)
+	}
+	if sess.game != nil {
+		return fmt.Errorf("session %s has already started", sess.name)
+	}
+	for _, other := range sess.clients {
+		if other.name == player {
+			return fmt.Errorf("name %s is taken in session %s", player, sess.name)
+		}
+	}
+
+	c.name = player
+	sess.clients = append(sess.clients, c)
+	c.send("WELCOME %s %s", player, sess.name)
+	sess.broadcast("WAITING %d %d", len(sess.clients), sess.server.Players)
+	if len(sess.clients) == sess.server.Players {
+		sess.start()
+	}
+	return nil
+}
+
+func (sess *session) start() {
+	names := make([]string, len(sess.clients))
+	for i, c := range sess.clients {
+		names[i] = c.name
+	}
+
+	g, err := sess.server.NewGame(names)
+	if err != nil {
+		sess.broadcast("ERR could not create game: %v", err)
+		sess.end()
+		return
+	}
+	g.ShowVisibilityMessages = true
+	g.AddEndRule(game.EndRule{Kind: game.EndLastPlayerStanding})
+	sess.game = g
+
+	sess.broadcast("START %s", strings.Join(names, " "))
+	sess.broadcast("TURN %s", g.CurrentPlayer().ID)
+}
+
+// act runs a command for c and reports whether the game ended.
+func (sess *session) act(c *client, cmd string) bool {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	g := sess.game
+	switch {
+	case sess.over:
+		c.send("ERR game is over")
+		return false
+	case g == nil:
+		c.send("ERR waiting for %d more players", sess.server.Players-len(sess.clients))
+		return false
+	case g.CurrentPlayer().ID != c.name:
+		c.send("ERR not your turn, waiting for %s", g.CurrentPlayer().ID)
+		return false
+	}
+
+	out, err := g.PerformAction(cmd)
+	if err != nil {
+		c.send("ERR %v", err)
+		return false
+	}
+	for _, other := range sess.clients {
+		if other == c {
+			other.send("YOU %s", out.Message())
+		} else if msg := out.MessageFor(other.name); msg != "" {
+			other.send("EVENT %s", msg)
+		}
+	}
+	return sess.afterTurn(out.UsedTurn)
+}
+
+// leave removes c from the session, eliminating their player if the game is
+// running, and reports whether the game ended.
+func (sess *session) leave(c *client) bool {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	c.close()
+	if sess.over {
+		return false
+	}
+	if sess.game == nil {
+		for i, other := range sess.clients {
+			if other == c {
+				sess.clients = append(sess.clients[:i], sess.clients[i+1:]...)
+				break
+			}
+		}
+		sess.broadcast("WAITING %d %d", len(sess.clients), sess.server.Players)
+		return len(sess.clients) == 0
+	}
+
+	wasCurrent := sess.game.CurrentPlayer().ID == c.name
+	if err := sess.game.Eliminate(c.name); err != nil {
+		return false
+	}
+	sess.broadcast("EVENT %s left the game.", c.name)
+	return sess.afterTurn(wasCurrent)
+}
+
+// afterTurn announces the end of the game or, if the turn moved on, whose
+// turn it is. It reports whether the game ended.
+func (sess *session) afterTurn(turnChanged bool) bool {
+	g := sess.game
+	if g.IsOver() {
+		winner := g.Winner
+		if winner == "" {
+			winner = "-"
+		}
+		sess.broadcast("END %s %s", winner, g.EndReason)
+		sess.end()
+		return true
+	}
+	if turnChanged {
+		sess.broadcast("TURN %s", g.CurrentPlayer().ID)
+	}
+	return false
+}
+
+func (sess *session) end() {
+	sess.over = true
+	for _, c := range sess.clients {
+		c.close()
+	}
+}
+
+func (sess *session) broadcast(format string, args ...any) {
+	for _, c := range sess.clients {
+		c.send(format, args...)
+	}
+}
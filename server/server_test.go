@@ -0,0 +1,101 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"maze-game/game"
+)
+
+type testClient struct {
+	t    *testing.T
+	conn net.Conn
+	in   *bufio.Scanner
+}
+
+func dial(t *testing.T, addr string) *testClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &testClient{t: t, conn: conn, in: bufio.NewScanner(conn)}
+}
+
+func (c *testClient) send(line string) {
+	c.t.Helper()
+	if _, err := fmt.Fprintln(c.conn, line); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// readUntil returns the lines the client receives up to and including the
+// first that starts with prefix.
+func (c *testClient) readUntil(prefix string) []string {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var lines []string
+	for c.in.Scan() {
+		lines = append(lines, c.in.Text())
+		if strings.HasPrefix(c.in.Text(), prefix) {
+			return lines
+		}
+	}
+	c.t.Fatalf("no %q line after %q: %v", prefix, lines, c.in.Err())
+	return nil
+}
+
+func startServer(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New(2, func(names []string) (*game.Game, error) {
+		cfg := game.DefaultConfig(5)
+		cfg.Seed = 1
+		return game.NewGameFromConfig(context.Background(), cfg, 2, names)
+	})
+	go s.Serve(l)
+	t.Cleanup(func() { l.Close() })
+	return l.Addr().String()
+}
+
+func TestSession(t *testing.T) {
+	addr := startServer(t)
+	a, b := dial(t, addr), dial(t, addr)
+	a.send("JOIN s A")
+	a.readUntil("WAITING 1 2")
+	b.send("JOIN s B")
+	for _, c := range []*testClient{a, b} {
+		c.readUntil("START A B")
+		c.readUntil("TURN A")
+	}
+
+	b.send("SKIP")
+	if lines := b.readUntil("ERR"); !strings.HasPrefix(lines[len(lines)-1], "ERR not your turn") {
+		t.Errorf("B acting out of turn got %q", lines)
+	}
+
+	a.send("SKIP")
+	if lines := a.readUntil("TURN B"); !strings.HasPrefix(lines[0], "YOU ") {
+		t.Errorf("A was told %q about their own turn", lines)
+	}
+	lines := b.readUntil("TURN B")
+	if want := []string{"EVENT A skipped their turn.", "TURN B"}; strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("B was told %q about A's turn, want %q", lines, want)
+	}
+
+	// A player who disconnects is out, leaving the other the winner
+	b.conn.Close()
+	lines = a.readUntil("END")
+	if lines[0] != "EVENT B left the game." || lines[len(lines)-1] != "END A last player standing" {
+		t.Errorf("A was told %q after B left", lines)
+	}
+}
@@ -0,0 +1,69 @@
+package sim
+
+import (
+	"fmt"
+
+	"maze-game/game"
+)
+
+// Grid lists the values to try for each setting. Variants runs through every
+// combination; an empty list keeps the default.
+type Grid struct {
+	Sizes         []int
+	Holes         []int
+	RiverLengths  []int // 0 for the board size plus 2
+	RiverPushes   []int
+	ExtraOpenings []int
+	Dragons       []int
+	// TreasureDistances are minimum treasure to exit distances, -1 for the
+	// board size minus 2.
+	TreasureDistances []int
+}
+
+// Variants returns one variant per combination, starting from
+// game.DefaultConfig. Later settings vary fastest.
+func (gr Grid) Variants() []Variant {
+	sizes := orDefault(gr.Sizes, 7)
+	var variants []Variant
+	for _, size := range sizes {
+		def := game.DefaultConfig(size)
+		for _, holes := range orDefault(gr.Holes, def.NumHoles) {
+			for _, river := range orDefault(gr.RiverLengths, 0) {
+				for _, push := range orDefault(gr.RiverPushes, 2) {
+					for _, openings := range orDefault(gr.ExtraOpenings, def.ExtraOpenings) {
+						for _, dragons := range orDefault(gr.Dragons, def.NumDragons) {
+							for _, dist := range orDefault(gr.TreasureDistances, -1) {
+								cfg := def
+								cfg.NumHoles = holes
+								cfg.RiverLength = river
+								if river == 0 {
+									cfg.RiverLength = size + 2
+								}
+								cfg.ExtraOpenings = openings
+								cfg.NumDragons = dragons
+								cfg.MinTreasureExitDistance = dist
+								if dist < 0 {
+									cfg.MinTreasureExitDistance = size - 2
+								}
+								variants = append(variants, Variant{
+									Name: fmt.Sprintf("size=%d holes=%d river=%d push=%d openings=%d dragons=%d treasure=%d",
+										size, cfg.NumHoles, cfg.RiverLength, push, openings, dragons, cfg.MinTreasureExitDistance),
+									Config:          cfg,
+									RiverMoveLength: push,
+								})
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	return variants
+}
+
+func orDefault(values []int, def int) []int {
+	if len(values) == 0 {
+		return []int{def}
+	}
+	return values
+}
@@ -0,0 +1,206 @@
+package sim
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Summary totals the games of one variant. Per-seat slices are indexed by
+// seat.
+type Summary struct {
+	Variant     Variant
+	Games       int // games played, not counting Failed
+	Failed      int // games whose maze could not be generated
+	Wins        []int
+	NoWinner    int
+	Turns       int // summed over all games played
+	DragonHits  []int
+	MonsterHits []int
+	ShotsFired  []int
+	ShotsHit    []int
+}
+
+// WinRate is the share of games played that seat won.
+func (s Summary) WinRate(seat int) float64 {
+	if s.Games == 0 {
+		return 0
+	}
+	return float64(s.Wins[seat]) / float64(s.Games)
+}
+
+func (s Summary) AverageTurns() float64 {
+	if s.Games == 0 {
+		return 0
+	}
+	return float64(s.Turns) / float64(s.Games)
+}
+
+// Summarize totals results by variant, in the order of variants.
+func Summarize(variants []Variant, seats int, results []Result) []Summary {
+	summaries := make([]Summary, len(variants))
+	index := map[string]int{}
+	for i, v := range variants {
+		summaries[i] = Summary{
+			Variant:     v,
+			Wins:        make([]int, seats),
+			DragonHits:  make([]int, seats),
+			MonsterHits: make([]int, seats),
+			ShotsFired:  make([]int, seats),
+			ShotsHit:    make([]int, seats),
+		}
+		index[v.Name] = i
+	}
+	for _, r := range results {
+		s := &summaries[index[r.Variant]]
+		if r.Error != "" {
+			s.Failed++
+			continue
+		}
+		s.Games++
+		s.Turns += r.Turns
+		if r.Winner >= 0 {
+			s.Wins[r.Winner]++
+		} else {
+			s.NoWinner++
+		}
+		for seat := 0; seat < seats; seat++ {
+			s.DragonHits[seat] += r.DragonHits[seat]
+			s.MonsterHits[seat] += r.MonsterHits[seat]
+			s.ShotsFired[seat] += r.ShotsFired[seat]
+			s.ShotsHit[seat] += r.ShotsHit[seat]
+		}
+	}
+	return summaries
+}
+
+type summaryJSON struct {
+	Variant       string    `json:"variant"`
+	Size          int       `json:"size"`
+	Holes         int       `json:"holes"`
+	RiverLength   int       `json:"river_length"`
+	RiverPush     int       `json:"river_push"`
+	ExtraOpenings int       `json:"extra_openings"`
+	Dragons       int       `json:"dragons"`
+	TreasureDist  int       `json:"treasure_distance"`
+	Games         int       `json:"games"`
+	Failed        int       `json:"failed"`
+	Wins          []int     `json:"wins"`
+	WinRates      []float64 `json:"win_rates"`
+	NoWinner      int       `json:"no_winner"`
+	AverageTurns  float64   `json:"average_turns"`
+	DragonHits    []int     `json:"dragon_hits"`
+	MonsterHits   []int     `json:"monster_hits"`
+	ShotsFired    []int     `json:"shots_fired"`
+	ShotsHit      []int     `json:"shots_hit"`
+}
+
+func (s Summary) toJSON() summaryJSON {
+	cfg := s.Variant.Config
+	out := summaryJSON{
+		Variant:       s.Variant.Name,
+		Size:          cfg.Size,
+		Holes:         cfg.NumHoles,
+		RiverLength:   cfg.RiverLength,
+		RiverPush:     s.Variant.RiverMoveLength,
+		ExtraOpenings: cfg.ExtraOpenings,
+		Dragons:       cfg.NumDragons,
+		TreasureDist:  cfg.MinTreasureExitDistance,
+		Games:         s.Games,
+		Failed:        s.Failed,
+		Wins:          s.Wins,
+		NoWinner:      s.NoWinner,
+		AverageTurns:  s.AverageTurns(),
+		DragonHits:    s.DragonHits,
+		MonsterHits:   s.MonsterHits,
+		ShotsFired:    s.ShotsFired,
+		ShotsHit:      s.ShotsHit,
+	}
+	for seat := range s.Wins {
+		out.WinRates = append(out.WinRates, s.WinRate(seat))
+	}
+	return out
+}
+
+// WriteJSON writes the summaries as a JSON array.
+func WriteJSON(w io.Writer, summaries []Summary) error {
+	out := make([]summaryJSON, len(summaries))
+	for i, s := range summaries {
+		out[i] = s.toJSON()
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// WriteCSV writes one row per summary, with a group of columns per seat.
+func WriteCSV(w io.Writer, summaries []Summary, seats int) error {
+	cw := csv.NewWriter(w)
+	header := []string{"size", "holes", "river_length", "river_push", "extra_openings", "dragons",
+		"treasure_distance", "games", "failed", "no_winner", "average_turns"}
+	for seat := 1; seat <= seats; seat++ {
+		for _, col := range []string{"wins", "win_rate", "dragon_hits", "monster_hits", "shots_fired", "shots_hit"} {
+			header = append(header, fmt.Sprintf("s%d_%s", seat, col))
+		}
+	}
+	cw.Write(header)
+
+	for _, s := range summaries {
+		j := s.toJSON()
+		row := ints(j.Size, j.Holes, j.RiverLength, j.RiverPush, j.ExtraOpenings, j.Dragons,
+			j.TreasureDist, j.Games, j.Failed, j.NoWinner)
+		row = append(row, strconv.FormatFloat(j.AverageTurns, 'f', 2, 64))
+		for seat := 0; seat < seats; seat++ {
+			row = append(row, strconv.Itoa(j.Wins[seat]), strconv.FormatFloat(j.WinRates[seat], 'f', 4, 64))
+			row = append(row, ints(j.DragonHits[seat], j.MonsterHits[seat], j.ShotsFired[seat], j.ShotsHit[seat])...)
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteResultsJSON writes every game as a JSON array.
+func WriteResultsJSON(w io.Writer, results []Result) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(results)
+}
+
+// WriteResultsCSV writes one row per game.
+func WriteResultsCSV(w io.Writer, results []Result, seats int) error {
+	cw := csv.NewWriter(w)
+	header := []string{"variant", "seed", "winner", "end_reason", "turns"}
+	for seat := 1; seat <= seats; seat++ {
+		for _, col := range []string{"dragon_hits", "monster_hits", "shots_fired", "shots_hit"} {
+			header = append(header, fmt.Sprintf("s%d_%s", seat, col))
+		}
+	}
+	header = append(header, "error")
+	cw.Write(header)
+
+	for _, r := range results {
+		winner := ""
+		if r.Winner >= 0 {
+			winner = fmt.Sprintf("S%d", r.Winner+1)
+		}
+		row := []string{r.Variant, strconv.FormatInt(r.Seed, 10), winner, r.EndReason, strconv.Itoa(r.Turns)}
+		for seat := 0; seat < seats; seat++ {
+			row = append(row, ints(r.DragonHits[seat], r.MonsterHits[seat], r.ShotsFired[seat], r.ShotsHit[seat])...)
+		}
+		row = append(row, r.Error)
+		cw.Write(row)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func ints(values ...int) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = strconv.Itoa(v)
+	}
+	return out
+}
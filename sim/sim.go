@@ -0,0 +1,193 @@
+// Package sim plays many bot games without a window to compare maze settings.
+// Games run in parallel, and every game is reproducible from its seed.
+package sim
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sync"
+	"time"
+
+	"maze-game/bot"
+	"maze-game/game"
+	"maze-game/mazegen"
+)
+
+// Variant is one set of game settings to simulate.
+type Variant struct {
+	Name            string
+	Config          mazegen.MazeConfig
+	RiverMoveLength int
+}
+
+// Options control a simulation run.
+type Options struct {
+	Variants []Variant
+	// Seats lists the bot kind playing each seat, in turn order.
+	Seats []string
+	// Games is the number of games played per variant.
+	Games int
+	// Seed is the seed of the first game; game i of every variant uses Seed+i,
+	// so variants are compared on the same seeds. 0 picks a random base seed.
+	Seed int64
+	// MaxTurns ends a game after this many turns, as game.TurnLimit does.
+	// Bots can wander forever, so it is required.
+	MaxTurns int
+	// Workers is the number of games played at once; 0 uses every CPU.
+	Workers int
+	// Monsters are placed in every game.
+	Monsters []game.MonsterSpec
+}
+
+// Result is the record of one game. Per-seat slices are indexed by seat.
+type Result struct {
+	Variant     string `json:"variant"`
+	Seed        int64  `json:"seed"`
+	Winner      int    `json:"winner"` // seat of the winner, -1 for none
+	EndReason   string `json:"end_reason"`
+	Turns       int    `json:"turns"`
+	DragonHits  []int  `json:"dragon_hits"`
+	MonsterHits []int  `json:"monster_hits"`
+	ShotsFired  []int  `json:"shots_fired"`
+	ShotsHit    []int  `json:"shots_hit"`
+	Error       string `json:"error,omitempty"` // set when no maze could be generated
+}
+
+// Run plays every game and returns the results ordered by variant, then seed.
+func Run(ctx context.Context, opts Options) ([]Result, error) {
+	if len(opts.Seats) == 0 {
+		return nil, fmt.Errorf("no seats to play")
+	}
+	if opts.MaxTurns <= 0 {
+		return nil, fmt.Errorf("a turn limit is required")
+	}
+	if opts.Games <= 0 {
+		return nil, fmt.Errorf("games per variant must be at least 1")
+	}
+	// Summarize tells variants apart by name
+	named := map[string]bool{}
+	for _, v := range opts.Variants {
+		if named[v.Name] {
+			return nil, fmt.Errorf("duplicate variant %q", v.Name)
+		}
+		named[v.Name] = true
+	}
+	for _, kind := range opts.Seats {
+		if _, err := bot.New(kind, nil); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Seed == 0 {
+		opts.Seed = time.Now().UnixNano()
+	}
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	results := make([]Result, len(opts.Variants)*opts.Games)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				v := opts.Variants[i/opts.Games]
+				results[i] = playGame(ctx, v, opts, opts.Seed+int64(i%opts.Games))
+			}
+		}()
+	}
+
+	var err error
+	for i := range results {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func playGame(ctx context.Context, v Variant, opts Options, seed int64) Result {
+	seats := len(opts.Seats)
+	res := Result{
+		Variant:     v.Name,
+		Seed:        seed,
+		Winner:      -1,
+		DragonHits:  make([]int, seats),
+		MonsterHits: make([]int, seats),
+		ShotsFired:  make([]int, seats),
+		ShotsHit:    make([]int, seats),
+	}
+
+	names := make([]string, seats)
+	seatOf := map[string]int{}
+	for i := range names {
+		names[i] = fmt.Sprintf("S%d", i+1)
+		seatOf[names[i]] = i
+	}
+
+	cfg := v.Config
+	cfg.Seed = seed
+	g, err := game.NewGameFromConfig(ctx, cfg, v.RiverMoveLength, names)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	res.Seed = g.Seed
+	if err := g.PlaceMonsters(opts.Monsters); err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	if err := g.AddEndRule(game.EndRule{Kind: game.EndTurnLimit, N: opts.MaxTurns}); err != nil {
+		res.Error = err.Error()
+		return res
+	}
+
+	bots := map[string]bot.Bot{}
+	for i, kind := range opts.Seats {
+		bots[names[i]], _ = bot.New(kind, rand.New(rand.NewSource(g.Seed*int64(seats)+int64(i))))
+		bot.UseTopology(bots[names[i]], g.Maze.Topology())
+	}
+
+	for !g.IsOver() {
+		out, _, err := bot.PlayTurn(g, bots)
+		if err != nil {
+			res.Error = err.Error()
+			return res
+		}
+		seat := seatOf[out.PlayerID]
+		for _, e := range out.Events {
+			switch e.Kind {
+			case game.EventDamage:
+				// Dragons hurt the player entering them, monsters the
+				// player they attack
+				if e.Monster == "" {
+					res.DragonHits[seat]++
+				} else {
+					res.MonsterHits[seatOf[e.Target]]++
+				}
+			case game.EventShotHit, game.EventShotMonster:
+				res.ShotsFired[seat]++
+				res.ShotsHit[seat]++
+			case game.EventShotMiss:
+				res.ShotsFired[seat]++
+			}
+		}
+	}
+
+	res.Turns = g.Turn
+	res.EndReason = g.EndReason
+	if g.Winner != "" {
+		res.Winner = seatOf[g.Winner]
+	}
+	return res
+}
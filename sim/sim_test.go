@@ -0,0 +1,94 @@
+package sim
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testOptions() Options {
+	return Options{
+		Variants: Grid{Sizes: []int{5}, Dragons: []int{0, 1}}.Variants(),
+		Seats:    []string{"explorer", "random"},
+		Games:    4,
+		Seed:     7,
+		MaxTurns: 60,
+		Workers:  3,
+	}
+}
+
+func TestRunIsReproducible(t *testing.T) {
+	first, err := Run(context.Background(), testOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Run(context.Background(), testOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) != 8 {
+		t.Fatalf("got %d results, want 8", len(first))
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Error("two runs with the same seed gave different results")
+	}
+	for i, r := range first {
+		if r.Error != "" {
+			t.Errorf("game %d: %s", i, r.Error)
+		}
+		if want := int64(7 + i%4); r.Seed != want {
+			t.Errorf("game %d played seed %d, want %d", i, r.Seed, want)
+		}
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	variants := []Variant{{Name: "a"}, {Name: "b"}}
+	results := []Result{
+		{Variant: "a", Winner: 0, Turns: 10, DragonHits: []int{1, 0}, MonsterHits: []int{0, 2}, ShotsFired: []int{1, 1}, ShotsHit: []int{1, 0}},
+		{Variant: "a", Winner: -1, Turns: 20, DragonHits: []int{0, 1}, MonsterHits: []int{0, 0}, ShotsFired: []int{2, 0}, ShotsHit: []int{0, 0}},
+		{Variant: "a", Error: "no maze"},
+		{Variant: "b", Winner: 1, Turns: 6, DragonHits: []int{0, 0}, MonsterHits: []int{0, 0}, ShotsFired: []int{0, 3}, ShotsHit: []int{0, 2}},
+	}
+	got := Summarize(variants, 2, results)
+	want := []Summary{
+		{Variant: variants[0], Games: 2, Failed: 1, Wins: []int{1, 0}, NoWinner: 1, Turns: 30,
+			DragonHits: []int{1, 1}, MonsterHits: []int{0, 2}, ShotsFired: []int{3, 1}, ShotsHit: []int{1, 0}},
+		{Variant: variants[1], Games: 1, Wins: []int{0, 1}, Turns: 6,
+			DragonHits: []int{0, 0}, MonsterHits: []int{0, 0}, ShotsFired: []int{0, 3}, ShotsHit: []int{0, 2}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if rate := got[0].WinRate(0); rate != 0.5 {
+		t.Errorf("win rate %v, want 0.5", rate)
+	}
+	if avg := got[0].AverageTurns(); avg != 15 {
+		t.Errorf("average turns %v, want 15", avg)
+	}
+}
+
+func TestRunChecksOptions(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(*Options)
+		want   string
+	}{
+		{"no seats", func(o *Options) { o.Seats = nil }, "no seats"},
+		{"no turn limit", func(o *Options) { o.MaxTurns = 0 }, "turn limit"},
+		{"no games", func(o *Options) { o.Games = 0 }, "at least 1"},
+		{"unknown bot", func(o *Options) { o.Seats = []string{"oracle"} }, "oracle"},
+		{"duplicate variant", func(o *Options) { o.Variants[1].Name = o.Variants[0].Name }, "duplicate variant"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := testOptions()
+			tt.change(&opts)
+			_, err := Run(context.Background(), opts)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -3,21 +3,45 @@ package ui
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
+	"strconv"
 	"strings"
 
+	"maze-game/bot"
 	"maze-game/game"
 	"maze-game/maze"
 )
 
 func RunCLI(g *game.Game) {
+	RunCLIWithBots(g, nil, game.NewGame)
+}
+
+// RunCLIWithBots runs the terminal game with some players, keyed by ID,
+// controlled by bots. Bots play their turns without waiting for input.
+// REGEN replaces the game with one from newGame.
+func RunCLIWithBots(g *game.Game, bots map[string]bot.Bot, newGame func() (*game.Game, error)) {
 	scanner := bufio.NewScanner(os.Stdin)
 
-	fmt.Println("Game started. Enter commands like: UP, DOWN, LEFT, RIGHT, SHOOT <direction>, SHOW or EXIT")
+	fmt.Printf("Game started. Enter commands like: %s, SHOOT <direction>, SHOW, NOTES, UNDO, REDO, TREE, GOTO <id> or EXIT\n", strings.Join(g.MoveCommands(), ", "))
+	fmt.Printf("Maze seed: %d\n", g.Seed)
 	ShowMap(g)
 
 	for {
 		p := g.CurrentPlayer()
+		if _, ok := bots[p.ID]; ok {
+			out, _, err := bot.PlayTurn(g, bots)
+			if err != nil {
+				fmt.Println("Error:", err)
+				break
+			}
+			fmt.Printf("%s (bot) > %s\n", p.ID, out.Command)
+			if !showOutcome(g, out) {
+				break
+			}
+			continue
+		}
+
 		status := ""
 		if p.Hurt {
 			status = " (hurt)"
@@ -30,8 +54,8 @@ func RunCLI(g *game.Game) {
 		input := strings.TrimSpace(scanner.Text())
 		parts := strings.Fields(input)
 
-		var res string
-		var nextPlayer string
+		var out game.Outcome
+		var err error
 
 		if len(parts) == 1 {
 			cmd := strings.ToUpper(parts[0])
@@ -56,138 +80,216 @@ func RunCLI(g *game.Game) {
 					break
 				}
 				loadFile := scanner.Text()
-				newGame, err := game.LoadFromFile(loadFile)
+				loaded, err := game.LoadFromFile(loadFile)
 				if err != nil {
 					fmt.Println("Error loading game:", err)
 				} else {
-					*g = *newGame
+					replaceGame(g, loaded, bots)
 					fmt.Println("Game loaded.")
 					ShowMap(g)
 				}
 				continue
 			case "REGEN":
 				fmt.Print("Regenerating maze... ")
-				g = game.NewGame()
-				fmt.Println("Maze regenerated.")
+				regenerated, err := newGame()
+				if err != nil {
+					fmt.Println("Error regenerating maze:", err)
+					continue
+				}
+				replaceGame(g, regenerated, bots)
+				fmt.Printf("Maze regenerated with seed %d.\n", g.Seed)
 				ShowMap(g)
 				continue
 			case "SHOW":
 				ShowMap(g)
 				continue
+			case "NOTES":
+				k, _ := g.Knowledge(p.ID)
+				WriteKnowledge(os.Stdout, k, p)
+				continue
+			case "UNDO", "REDO":
+				undo := g.Undo
+				if cmd == "REDO" {
+					undo = g.Redo
+				}
+				if err := undo(); err != nil {
+					fmt.Println("Error:", err)
+				} else {
+					fmt.Printf("Turn %d, %s to play.\n", g.Turn, g.CurrentPlayer().ID)
+				}
+				continue
+			case "TREE":
+				WriteTimeline(os.Stdout, g.Timeline())
+				continue
 			case "EXIT":
 				fmt.Println("Exiting game.")
 				return
-			case "SKIP":
-				g.NextPlayer()
-				continue
-			case "UP", "DOWN", "LEFT", "RIGHT":
-				res, nextPlayer = g.PerformAction(cmd)
 			default:
-				fmt.Println("Unknown command. Use UP, DOWN, LEFT, RIGHT, SHOW, SHOOT <direction> or EXIT")
-				continue
+				if cmd != "SKIP" && !g.IsDirection(cmd) {
+					fmt.Printf("Unknown command. Use %s, SHOW, NOTES, SHOOT <direction> or EXIT\n", strings.Join(g.MoveCommands(), ", "))
+					continue
+				}
+				out, err = g.PerformAction(cmd)
 			}
 		} else if len(parts) == 2 {
 			cmd := strings.ToUpper(parts[0])
 			dir := strings.ToUpper(parts[1])
 
-			if cmd == "SHOOT" && (dir == "UP" || dir == "DOWN" || dir == "LEFT" || dir == "RIGHT") {
-				res, nextPlayer = g.PerformAction(fmt.Sprintf("SHOOT %s", dir))
+			if cmd == "SHOOT" && g.IsDirection(dir) {
+				out, err = g.PerformAction(fmt.Sprintf("SHOOT %s", dir))
+			} else if cmd == "GOTO" {
+				id, convErr := strconv.Atoi(dir)
+				if convErr == nil {
+					convErr = g.GoTo(id)
+				}
+				if convErr != nil {
+					fmt.Println("Error:", convErr)
+				} else {
+					fmt.Printf("Turn %d, %s to play.\n", g.Turn, g.CurrentPlayer().ID)
+				}
+				continue
 			} else {
-				fmt.Println("Invalid shoot command. Use SHOOT <UP|DOWN|LEFT|RIGHT>")
+				fmt.Printf("Invalid shoot command. Use SHOOT <%s>\n", strings.Join(g.MoveCommands(), "|"))
 				continue
 			}
 		} else {
-			fmt.Println("Invalid input. Use UP, DOWN, LEFT, RIGHT, SHOOT <direction>, SHOW or EXIT")
+			fmt.Printf("Invalid input. Use %s, SHOOT <direction>, SHOW or EXIT\n", strings.Join(g.MoveCommands(), ", "))
 			continue
 		}
 
-		fmt.Println(res)
+		if err != nil {
+			fmt.Println("Error:", err)
+			continue
+		}
 
-		// Check for game end conditions
-		lowerRes := strings.ToLower(res)
-		if strings.Contains(lowerRes, "game over") || strings.Contains(lowerRes, "you win") {
-			fmt.Println("Game ended.")
-			ShowMap(g)
+		bot.Deliver(bots, out)
+		if !showOutcome(g, out) {
 			break
 		}
+	}
+}
 
-		fmt.Printf("Next turn: %s\n", nextPlayer)
+// replaceGame puts other in place of g, keeping its subscribers, and tells
+// the bots about the new maze.
+func replaceGame(g, other *game.Game, bots map[string]bot.Bot) {
+	g.Replace(other)
+	for _, b := range bots {
+		bot.UseTopology(b, g.Maze.Topology())
 	}
 }
 
-func ShowMap(g *game.Game) {
-	m := g.GetMaze()
-	players := g.GetPlayers()
-	size := m.Size
-
-	// Print top boundary
-	fmt.Print("+")
-	for c := 0; c < size; c++ {
-		fmt.Print("---+")
+// showOutcome prints the result of a turn and reports whether the game goes on.
+func showOutcome(g *game.Game, out game.Outcome) bool {
+	fmt.Println(out.Message())
+	// Players caught by monsters at the end of the round
+	told := map[string]bool{out.PlayerID: true}
+	for _, e := range out.Events {
+		if e.Kind == game.EventMonsterAttack && !told[e.Target] {
+			told[e.Target] = true
+			fmt.Printf("%s: %s\n", e.Target, out.MessageFor(e.Target))
+		}
 	}
-	fmt.Println()
-
-	for r := 0; r < size; r++ {
-		line := "|"
-		bottomLine := "+"
-
-		for c := 0; c < size; c++ {
-			cell := m.Grid[r][c]
-
-			// Determine cell content: player or treasure or cell type
-			cellChar := "   " // 3 spaces default
-
-			// Check for player in cell
-			for _, p := range players {
-				if p.Row == r && p.Col == c {
-					label := strings.ToUpper(p.ID)
-					if p.Hurt {
-						label = strings.ToLower(label)
-					}
-					// Make sure label is exactly 3 chars, padded or trimmed
-					if len(label) > 3 {
-						label = label[:3]
-					} else {
-						label = fmt.Sprintf("%-3s", label)
-					}
-					cellChar = label
-					break
-				}
-			}
 
-			// If no player and treasure is here
-			if strings.TrimSpace(cellChar) == "" && m.TreasureOnMap && m.TreasureRow == r && m.TreasureCol == c {
-				cellChar = " T "
-			}
+	// Check for game end conditions
+	if g.IsOver() {
+		fmt.Println("Game ended.")
+		if g.Winner != "" {
+			fmt.Printf("%s wins: %s.\n", g.Winner, g.EndReason)
+		}
+		ShowMap(g)
+		return false
+	}
+
+	fmt.Printf("Next turn: %s\n", out.NextPlayer)
+	return true
+}
+
+func ShowMap(g *game.Game) {
+	WriteMap(os.Stdout, g.GetMaze(), g.GetPlayers(), g.Monsters)
+}
+
+// WriteMap draws the maze with the players and monsters on it, followed by
+// their status. Stunned monsters are shown in lower case. Each floor of a
+// maze with several is drawn on its own under a heading. A one-way wall is
+// drawn as the way it lets you through, as in the text format.
+func WriteMap(w io.Writer, m *maze.Maze, players []*game.Player, monsters []*game.Monster) {
+	cellText := func(r, c int) string {
+		cell := m.Grid[r][c]
 
-			// If still empty, use cell symbol (single char) centered in 3 spaces
-			if strings.TrimSpace(cellChar) == "" {
-				sym := cellSymbol(*cell)
-				cellChar = fmt.Sprintf(" %s ", sym)
+		// Determine cell content: player or treasure or cell type
+		cellChar := "   " // 3 spaces default
+		if m.IsDisabled(r, c) {
+			cellChar = "###"
+		}
+
+		// Check for player in cell
+		for _, p := range players {
+			if p.Row == r && p.Col == c {
+				label := strings.ToUpper(p.ID)
+				if p.Hurt {
+					label = strings.ToLower(label)
+				}
+				// Make sure label is exactly 3 chars, padded or trimmed
+				if len(label) > 3 {
+					label = label[:3]
+				} else {
+					label = fmt.Sprintf("%-3s", label)
+				}
+				cellChar = label
+				break
 			}
+		}
 
-			// Right wall
-			rightWall := " "
-			if cell.Walls[maze.Right] {
-				rightWall = "|"
+		// Check for a monster in cell
+		for _, mon := range monsters {
+			if strings.TrimSpace(cellChar) == "" && !mon.Dead && mon.Row == r && mon.Col == c {
+				label := strings.ToUpper(mon.ID)
+				if mon.Stunned > 0 {
+					label = strings.ToLower(label)
+				}
+				if len(label) > 3 {
+					label = label[:3]
+				}
+				cellChar = fmt.Sprintf("%-3s", label)
 			}
+		}
 
-			line += cellChar + rightWall
+		// If no player and treasure is here
+		if strings.TrimSpace(cellChar) == "" && m.TreasureOnMap && m.TreasureRow == r && m.TreasureCol == c {
+			cellChar = " T "
+		}
 
-			// Bottom wall (3 dashes or spaces)
-			bottomWall := "   "
-			if cell.Walls[maze.Down] {
-				bottomWall = "---"
+		// If still empty, use cell symbol (single char) centered in 3 spaces,
+		// followed by the key number of keys and locked exits
+		if strings.TrimSpace(cellChar) == "" {
+			sym := cellSymbol(*cell)
+			cellChar = fmt.Sprintf(" %s ", sym)
+			if cell.Key > 0 {
+				cellChar = fmt.Sprintf(" %s%d", sym, cell.Key)
 			}
-			bottomLine += bottomWall + "+"
 		}
+		return cellChar
+	}
 
-		fmt.Println(line)
-		fmt.Println(bottomLine)
+	// Walls, or the key number of a door
+	wall := func(r, c int, d maze.Direction) byte {
+		cell := m.Grid[r][c]
+		if door := cell.Door(d); door > 0 {
+			return byte('0' + door%10)
+		} else if oneWay, out := m.IsOneWay(r, c, d); oneWay && out {
+			return markOut
+		} else if oneWay {
+			return markIn
+		} else if cell.Walls[d] {
+			return markClosed
+		}
+		return markOpen
 	}
 
+	writeGrid(w, textGrid{rows: m.Rows, cols: m.Cols, floors: m.FloorCount(), topology: m.Topology(), cell: cellText, wall: wall})
+
 	// Player info summary (unchanged)
-	fmt.Println("\nPlayers:")
+	fmt.Fprintln(w, "\nPlayers:")
 	for _, p := range players {
 		id := p.ID
 		status := ""
@@ -200,39 +302,39 @@ func ShowMap(g *game.Game) {
 		if p.Bullet {
 			status += " (has bullet)"
 		}
-		fmt.Printf("- %s%s\n", id, status)
+		if p.Keys != 0 {
+			status += fmt.Sprintf(" (keys %s)", p.Keys)
+		}
+		fmt.Fprintf(w, "- %s%s\n", id, status)
+	}
+
+	if len(monsters) > 0 {
+		fmt.Fprintln(w, "\nMonsters:")
+	}
+	for _, mon := range monsters {
+		status := ""
+		if mon.Dead {
+			status = " (dead)"
+		} else if mon.Stunned > 0 {
+			status = fmt.Sprintf(" (stunned for %d rounds)", mon.Stunned)
+		}
+		fmt.Fprintf(w, "- %s: %s, %s%s\n", mon.ID, mon.Behavior, mon.Damage, status)
+	}
+}
+
+// writeFloorHeading starts floor f of a maze with the given number of
+// floors; single floors get no heading.
+func writeFloorHeading(w io.Writer, floors, f int) {
+	if floors < 2 {
+		return
 	}
+	if f > 0 {
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "Floor %d of %d:\n", f+1, floors)
 }
 
 // Helper for cell type
 func cellSymbol(cell maze.Cell) string {
-	switch cell.Type {
-	case maze.Hospital:
-		return "H"
-	case maze.Exit:
-		return "E"
-	case maze.Dragon:
-		return "D"
-	case maze.Hole:
-		return "O"
-	case maze.Armory:
-		return "A"
-	case maze.River:
-		switch cell.RiverDir {
-		case maze.Up:
-			return "↑"
-		case maze.Down:
-			return "↓"
-		case maze.Left:
-			return "←"
-		case maze.Right:
-			return "→"
-		default:
-			return "~" // fallback
-		}
-	case maze.Estuary:
-		return "~"
-	default:
-		return "."
-	}
+	return game.CellBehaviorOf(cell.Type).Symbol(cell)
 }
@@ -0,0 +1,83 @@
+package ui
+
+import (
+	"math"
+	"slices"
+
+	"maze-game/maze"
+)
+
+// Point is a position on a picture of a maze.
+type Point struct {
+	X, Y float64
+}
+
+// Geometry lays the cells of a maze out on a picture. Square cells are
+// CellSize wide and high, hexagons CellSize wide and triangles CellSize
+// along each side.
+type Geometry struct {
+	Topology maze.Topology
+	CellSize float64
+}
+
+// Outline returns the corners of the cell at (r, c), clockwise, so that
+// the side from corner i to the next is the i-th of the cell's Sides.
+func (g Geometry) Outline(r, c int) []Point {
+	s := g.CellSize
+	switch t := g.Topology.(type) {
+	case maze.Hex:
+		h := s * 2 / math.Sqrt(3)
+		x, y := float64(c)*s+float64(r)*s/2, float64(r)*h*3/4
+		return []Point{
+			{x + s/2, y}, {x + s, y + h/4}, {x + s, y + h*3/4},
+			{x + s/2, y + h}, {x, y + h*3/4}, {x, y + h/4},
+		}
+	case maze.Triangle:
+		h := s * math.Sqrt(3) / 2
+		x, y := float64(c)*s/2, float64(r)*h
+		if t.PointsUp(r, c) {
+			return []Point{{x + s/2, y}, {x + s, y + h}, {x, y + h}}
+		}
+		return []Point{{x, y}, {x + s, y}, {x + s/2, y + h}}
+	}
+	x, y := float64(c)*s, float64(r)*s
+	return []Point{{x, y}, {x + s, y}, {x + s, y + s}, {x, y + s}}
+}
+
+// Side returns the ends of side d of the cell at (r, c), or false if the
+// cell has no such side.
+func (g Geometry) Side(r, c int, d maze.Direction) (Point, Point, bool) {
+	i := slices.Index(g.Topology.Sides(r, c), d)
+	if i < 0 {
+		return Point{}, Point{}, false
+	}
+	corners := g.Outline(r, c)
+	return corners[i], corners[(i+1)%len(corners)], true
+}
+
+// Centre returns the middle of the cell at (r, c).
+func (g Geometry) Centre(r, c int) Point {
+	var p Point
+	corners := g.Outline(r, c)
+	for _, q := range corners {
+		p.X += q.X / float64(len(corners))
+		p.Y += q.Y / float64(len(corners))
+	}
+	return p
+}
+
+// Bounds returns the top-left and bottom-right corners of the box around
+// rows r0 to r1-1 of a maze with the given number of columns.
+func (g Geometry) Bounds(r0, r1, cols int) (Point, Point) {
+	lo := Point{math.Inf(1), math.Inf(1)}
+	hi := Point{math.Inf(-1), math.Inf(-1)}
+	for r := r0; r < r1; r++ {
+		for _, c := range []int{0, cols - 1} {
+			for _, p := range g.Outline(r, c) {
+				lo.X, lo.Y = min(lo.X, p.X), min(lo.Y, p.Y)
+				hi.X, hi.Y = max(hi.X, p.X), max(hi.Y, p.Y)
+			}
+		}
+	}
+	return lo, hi
+}
@@ -0,0 +1,170 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"maze-game/maze"
+)
+
+// Wall marks for textGrid.wall; a digit from 1 to 9 marks a door. A
+// one-way wall is marked from the side of the cell asked about: markOut if
+// it lets you out of the cell, markIn if it lets you in.
+const (
+	markOpen    = ' '
+	markClosed  = '#'
+	markUnknown = '.'
+	markOut     = 'o'
+	markIn      = 'i'
+)
+
+// textGrid is what a text map shows: three characters in each cell and a
+// mark on each side.
+type textGrid struct {
+	rows, cols, floors int
+	topology           maze.Topology
+	cell               func(r, c int) string
+	wall               func(r, c int, d maze.Direction) byte
+}
+
+// writeGrid draws square and triangle grids as boxes, a triangle having
+// one side that is always closed, and hex grids as rows of hexagons each
+// shifted half a cell right of the one above.
+func writeGrid(w io.Writer, g textGrid) {
+	floorRows := g.rows / max(g.floors, 1)
+	for f := 0; f < max(g.floors, 1); f++ {
+		writeFloorHeading(w, g.floors, f)
+		top, bottom := f*floorRows, (f+1)*floorRows
+		if _, ok := g.topology.(maze.Hex); ok {
+			writeHexRows(w, g, top, bottom)
+		} else {
+			writeBoxRows(w, g, top, bottom)
+		}
+	}
+}
+
+func writeBoxRows(w io.Writer, g textGrid, top, bottom int) {
+	horizontal := func(r, c int, d maze.Direction) string {
+		switch m := g.wall(r, c, d); m {
+		case markClosed:
+			return "---"
+		case markOpen:
+			return "   "
+		case markUnknown:
+			return " . "
+		case markOut, markIn:
+			return strings.Repeat(string(g.arrow(d, m)), 3)
+		default:
+			return "-" + string(m) + "-"
+		}
+	}
+	vertical := func(r, c int, d maze.Direction) string {
+		switch m := g.wall(r, c, d); m {
+		case markClosed:
+			return "|"
+		case markUnknown:
+			return ":"
+		case markOut, markIn:
+			return string(g.arrow(d, m))
+		default:
+			return string(m)
+		}
+	}
+
+	line := "+"
+	for c := 0; c < g.cols; c++ {
+		line += horizontal(top, c, maze.Up) + "+"
+	}
+	fmt.Fprintln(w, line)
+	for r := top; r < bottom; r++ {
+		line := vertical(r, 0, maze.Left)
+		below := "+"
+		for c := 0; c < g.cols; c++ {
+			line += g.cell(r, c) + vertical(r, c, maze.Right)
+			below += horizontal(r, c, maze.Down) + "+"
+		}
+		fmt.Fprintln(w, line)
+		fmt.Fprintln(w, below)
+	}
+}
+
+// arrow returns the way a one-way wall on side d, marked markOut or markIn,
+// lets you through: ^ v < or >, as in the text format.
+func (g textGrid) arrow(d maze.Direction, mark byte) byte {
+	if mark == markIn {
+		d = g.topology.Opposite(d)
+	}
+	switch d {
+	case maze.Up, maze.UpLeft, maze.UpRight:
+		return '^'
+	case maze.Down, maze.DownLeft, maze.DownRight:
+		return 'v'
+	case maze.Left:
+		return '<'
+	}
+	return '>'
+}
+
+// hexGlyphs are the characters of closed walls on each side of a hexagon.
+var hexGlyphs = map[maze.Direction]byte{
+	maze.UpRight:   '\\',
+	maze.Right:     '|',
+	maze.DownRight: '/',
+	maze.DownLeft:  '\\',
+	maze.Left:      '|',
+	maze.UpLeft:    '/',
+}
+
+// writeHexRows puts cell (r, c) at column 2*(r-top)+4*c, its left wall
+// there and its content in the three characters after. The lines between
+// rows hold the slanted walls, shared by the rows above and below.
+func writeHexRows(w io.Writer, g textGrid, top, bottom int) {
+	glyph := func(r, c int, d maze.Direction) byte {
+		switch m := g.wall(r, c, d); m {
+		case markClosed:
+			return hexGlyphs[d]
+		case markUnknown:
+			if d == maze.Left || d == maze.Right {
+				return ':'
+			}
+			return '.'
+		case markOut, markIn:
+			return g.arrow(d, m)
+		default:
+			return m
+		}
+	}
+	width := 2*(bottom-top) + 4*g.cols + 2
+	// slants draws the walls below row r, or above row r+1 where row r
+	// has no cell
+	slants := func(r int) {
+		line := []byte(strings.Repeat(" ", width))
+		if r+1 < bottom {
+			x := 2 * (r + 1 - top)
+			for c := 0; c < g.cols; c++ {
+				line[x+4*c+1] = glyph(r+1, c, maze.UpLeft)
+				line[x+4*c+3] = glyph(r+1, c, maze.UpRight)
+			}
+		}
+		if r >= top {
+			x := 2 * (r - top)
+			for c := 0; c < g.cols; c++ {
+				line[x+4*c+1] = glyph(r, c, maze.DownLeft)
+				line[x+4*c+3] = glyph(r, c, maze.DownRight)
+			}
+		}
+		fmt.Fprintln(w, strings.TrimRight(string(line), " "))
+	}
+
+	slants(top - 1)
+	for r := top; r < bottom; r++ {
+		line := strings.Repeat(" ", 2*(r-top))
+		for c := 0; c < g.cols; c++ {
+			line += string(glyph(r, c, maze.Left)) + g.cell(r, c)
+		}
+		line += string(glyph(r, g.cols-1, maze.Right))
+		fmt.Fprintln(w, line)
+		slants(r)
+	}
+}
@@ -0,0 +1,355 @@
+package ui
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"maze-game/game"
+	"maze-game/maze"
+
+	"golang.org/x/image/vector"
+)
+
+var cellColors = map[maze.CellType]color.RGBA{
+	maze.Empty:     {240, 236, 222, 255},
+	maze.Wall:      {60, 60, 60, 255},
+	maze.Hole:      {90, 70, 50, 255},
+	maze.River:     {90, 150, 220, 255},
+	maze.Estuary:   {40, 90, 180, 255},
+	maze.Exit:      {90, 200, 90, 255},
+	maze.Hospital:  {240, 240, 255, 255},
+	maze.Armory:    {170, 170, 170, 255},
+	maze.Dragon:    {210, 60, 40, 255},
+	maze.Key:       {240, 236, 222, 255},
+	maze.Stairs:    {200, 180, 140, 255},
+	maze.Lake:      {60, 120, 200, 255},
+	maze.Waterfall: {150, 200, 240, 255},
+}
+
+// keyColors tell keys apart; a key, its doors and a locked exit share one.
+var keyColors = []color.RGBA{
+	{200, 140, 20, 255},
+	{40, 150, 160, 255},
+	{190, 60, 150, 255},
+	{100, 160, 40, 255},
+	{70, 80, 200, 255},
+}
+
+func keyColor(key int) color.RGBA {
+	return keyColors[(key-1)%len(keyColors)]
+}
+
+var (
+	wallColor     = color.RGBA{20, 20, 20, 255}
+	oneWayColor   = color.RGBA{150, 150, 150, 255}
+	treasureColor = color.RGBA{240, 200, 30, 255}
+	playerColor   = color.RGBA{120, 40, 160, 255}
+	hurtColor     = color.RGBA{230, 120, 200, 255}
+	monsterColor  = color.RGBA{30, 120, 40, 255}
+	stunnedColor  = color.RGBA{150, 190, 150, 255}
+)
+
+// RenderImage draws the maze as a flat picture without the game's sprites,
+// so it works without a display. Players are drawn as squares, the treasure
+// as a smaller square, and river cells get a mark on their downstream side.
+// Keys are drawn as a bar in the colour of their doors, and a locked exit
+// gets one in a corner. One-way walls are drawn pale, with a wedge pointing
+// the way they let you through.
+func RenderImage(m *maze.Maze, players []*game.Player, monsters []*game.Monster, cellSize int) *image.RGBA {
+	cv := newCanvas(m.Topology(), m.Rows, m.Cols, cellSize)
+
+	for r := 0; r < m.Rows; r++ {
+		for c := 0; c < m.Cols; c++ {
+			cell := m.Grid[r][c]
+			cv.tile(r, c, cellColors[cell.Type])
+
+			if cell.Type.Flows() {
+				cv.arrow(r, c, cell.RiverDir)
+			}
+			if cell.Type == maze.Key {
+				cv.bar(r, c, keyColor(cell.Key))
+			} else if cell.Key > 0 {
+				cv.corner(r, c, cv.wall, cellSize/3, keyColor(cell.Key))
+			}
+			if m.TreasureOnMap && m.TreasureRow == r && m.TreasureCol == c {
+				cv.box(r, c, cellSize/4, treasureColor)
+			}
+		}
+	}
+
+	for _, mon := range monsters {
+		if mon.Dead {
+			continue
+		}
+		col := monsterColor
+		if mon.Stunned > 0 {
+			col = stunnedColor
+		}
+		cv.box(mon.Row, mon.Col, cellSize/5, col)
+	}
+
+	for _, p := range players {
+		col := playerColor
+		if p.Hurt {
+			col = hurtColor
+		}
+		cv.box(p.Row, p.Col, cellSize/3, col)
+	}
+
+	for r := 0; r < m.Rows; r++ {
+		for c := 0; c < m.Cols; c++ {
+			cell := m.Grid[r][c]
+			for _, d := range m.Sides(r, c) {
+				if !cell.Walls[d] {
+					continue
+				}
+				col := wallColor
+				if door := cell.Door(d); door > 0 {
+					col = keyColor(door)
+				}
+				if oneWay, _ := m.IsOneWay(r, c, d); oneWay {
+					cv.side(r, c, d, oneWayColor)
+					cv.wedge(r, c, d)
+					continue
+				}
+				cv.side(r, c, d, col)
+			}
+		}
+	}
+	return cv.img
+}
+
+var (
+	unknownColor = color.RGBA{0, 0, 0, 120}
+	maybeColor   = color.RGBA{210, 60, 40, 110}
+)
+
+// RenderKnowledge draws a player's own map like RenderImage: cells the player
+// has not identified are dark, tinted red where the dragon might be, and only
+// the walls the player found are drawn. A red corner marks where the dragon
+// saw the player.
+func RenderKnowledge(k *game.Knowledge, p *game.Player, cellSize int) *image.RGBA {
+	cv := newCanvas(k.Topology, k.Rows, k.Cols, cellSize)
+
+	for r := 0; r < k.Rows; r++ {
+		for c := 0; c < k.Cols; c++ {
+			cell := k.Cells[r][c]
+			switch {
+			case cell.Identified:
+				cv.tile(r, c, cellColors[cell.Type])
+			case k.MaybeDragon(r, c):
+				cv.tile(r, c, maybeColor)
+			default:
+				cv.tile(r, c, unknownColor)
+			}
+		}
+	}
+	for _, s := range k.Sightings {
+		if s.Kind == game.EventDragonSeen {
+			cv.corner(s.Row, s.Col, 0, cellSize/4, cellColors[maze.Dragon])
+		}
+	}
+
+	col := playerColor
+	if p.Hurt {
+		col = hurtColor
+	}
+	cv.box(p.Row, p.Col, cellSize/3, col)
+
+	for r := 0; r < k.Rows; r++ {
+		for c := 0; c < k.Cols; c++ {
+			for _, d := range cv.geo.Topology.Sides(r, c) {
+				if k.Wall(r, c, d) == game.WallClosed {
+					cv.side(r, c, d, wallColor)
+				}
+			}
+		}
+	}
+	return cv.img
+}
+
+// CropFloor cuts floor f out of an image drawn by RenderImage or
+// RenderKnowledge of a maze with the given topology, size and number of
+// floors.
+func CropFloor(img *image.RGBA, t maze.Topology, rows, cols, floors, f, cellSize int) *image.RGBA {
+	wall := max(cellSize/10, 1)
+	rowsPerFloor := rows / max(floors, 1)
+	lo, hi := Geometry{t, float64(cellSize)}.Bounds(f*rowsPerFloor, (f+1)*rowsPerFloor, cols)
+	return img.SubImage(image.Rect(0, int(lo.Y), img.Bounds().Dx(), int(math.Ceil(hi.Y))+wall)).(*image.RGBA)
+}
+
+// canvas draws cells of a topology on an image. Square cells are filled as
+// rectangles, other shapes as polygons with walls drawn along their sides.
+type canvas struct {
+	img    *image.RGBA
+	geo    Geometry
+	size   int
+	wall   int
+	square bool
+}
+
+func newCanvas(t maze.Topology, rows, cols, cellSize int) *canvas {
+	if t == nil {
+		t = maze.Square{}
+	}
+	cv := &canvas{geo: Geometry{t, float64(cellSize)}, size: cellSize, wall: max(cellSize/10, 1)}
+	_, cv.square = t.(maze.Square)
+	_, hi := cv.geo.Bounds(0, rows, cols)
+	cv.img = image.NewRGBA(image.Rect(0, 0, int(math.Ceil(hi.X))+cv.wall, int(math.Ceil(hi.Y))+cv.wall))
+	return cv
+}
+
+func (cv *canvas) fill(x0, y0, x1, y1 int, c color.Color) {
+	draw.Draw(cv.img, image.Rect(x0, y0, x1, y1), &image.Uniform{c}, image.Point{}, draw.Src)
+}
+
+// polygon fills the shape with the given corners. Corners are in geometry
+// coordinates, which start half a wall into the image so the outer walls
+// fit.
+func (cv *canvas) polygon(corners []Point, c color.Color) {
+	off := float64(cv.wall) / 2
+	lo := image.Point{math.MaxInt, math.MaxInt}
+	hi := image.Point{math.MinInt, math.MinInt}
+	for _, p := range corners {
+		lo.X, lo.Y = min(lo.X, int(math.Floor(p.X+off))), min(lo.Y, int(math.Floor(p.Y+off)))
+		hi.X, hi.Y = max(hi.X, int(math.Ceil(p.X+off))), max(hi.Y, int(math.Ceil(p.Y+off)))
+	}
+	b := cv.img.Bounds()
+	lo.X, lo.Y = max(lo.X, b.Min.X), max(lo.Y, b.Min.Y)
+	hi.X, hi.Y = min(hi.X, b.Max.X), min(hi.Y, b.Max.Y)
+	z := vector.NewRasterizer(hi.X-lo.X, hi.Y-lo.Y)
+	for i, p := range corners {
+		x, y := float32(p.X+off-float64(lo.X)), float32(p.Y+off-float64(lo.Y))
+		if i == 0 {
+			z.MoveTo(x, y)
+		} else {
+			z.LineTo(x, y)
+		}
+	}
+	z.ClosePath()
+	z.Draw(cv.img, image.Rectangle{lo, hi}, &image.Uniform{c}, image.Point{})
+}
+
+// box fills a square inset from the sides of the cell at (r, c).
+func (cv *canvas) box(r, c, inset int, col color.Color) {
+	if cv.square {
+		x, y := c*cv.size, r*cv.size
+		cv.fill(x+inset, y+inset, x+cv.size-inset, y+cv.size-inset, col)
+		return
+	}
+	cv.mark(cv.geo.Centre(r, c), cv.inner()*(float64(cv.size)/2-float64(inset)), col)
+}
+
+// mark fills a square of the given half width around p.
+func (cv *canvas) mark(p Point, half float64, col color.Color) {
+	cv.polygon([]Point{{p.X - half, p.Y - half}, {p.X + half, p.Y - half}, {p.X + half, p.Y + half}, {p.X - half, p.Y + half}}, col)
+}
+
+// inner is how much smaller than half a cell's width the circle inside the
+// cell is.
+func (cv *canvas) inner() float64 {
+	a, b, _ := cv.geo.Side(0, 0, cv.geo.Topology.Sides(0, 0)[0])
+	centre := cv.geo.Centre(0, 0)
+	return math.Hypot((a.X+b.X)/2-centre.X, (a.Y+b.Y)/2-centre.Y) / (float64(cv.size) / 2)
+}
+
+func (cv *canvas) tile(r, c int, col color.Color) {
+	if cv.square {
+		x, y := c*cv.size, r*cv.size
+		cv.fill(x, y, x+cv.size, y+cv.size, col)
+		return
+	}
+	cv.polygon(cv.geo.Outline(r, c), col)
+}
+
+// bar draws a key across the middle of the cell.
+func (cv *canvas) bar(r, c int, col color.Color) {
+	q := cv.size / 6
+	if cv.square {
+		x, y := c*cv.size, r*cv.size
+		cv.fill(x+q, y+cv.size/2-q, x+cv.size-q, y+cv.size/2+q, col)
+		return
+	}
+	p, w := cv.geo.Centre(r, c), cv.inner()*(float64(cv.size)/2-float64(q))
+	cv.polygon([]Point{{p.X - w, p.Y - float64(q)}, {p.X + w, p.Y - float64(q)}, {p.X + w, p.Y + float64(q)}, {p.X - w, p.Y + float64(q)}}, col)
+}
+
+// corner fills a square from inset to end in the top-left corner of square
+// cells, and one as wide between the first corner and the centre of others.
+func (cv *canvas) corner(r, c, inset, end int, col color.Color) {
+	if cv.square {
+		x, y := c*cv.size, r*cv.size
+		cv.fill(x+inset, y+inset, x+end, y+end, col)
+		return
+	}
+	a, centre := cv.geo.Outline(r, c)[0], cv.geo.Centre(r, c)
+	cv.mark(Point{a.X + (centre.X-a.X)/2, a.Y + (centre.Y-a.Y)/2}, float64(end-inset)/2, col)
+}
+
+// arrow marks the side d a river flows out of.
+func (cv *canvas) arrow(r, c int, d maze.Direction) {
+	if cv.square {
+		dr, dc := cv.geo.Topology.Delta(d)
+		x, y := c*cv.size, r*cv.size
+		mx, my := x+cv.size/2+dc*cv.size/3, y+cv.size/2+dr*cv.size/3
+		cv.fill(mx-cv.wall, my-cv.wall, mx+cv.wall, my+cv.wall, wallColor)
+		return
+	}
+	a, b, ok := cv.geo.Side(r, c, d)
+	if !ok {
+		return
+	}
+	centre := cv.geo.Centre(r, c)
+	cv.mark(Point{centre.X + ((a.X+b.X)/2-centre.X)*2/3, centre.Y + ((a.Y+b.Y)/2-centre.Y)*2/3}, float64(cv.wall), wallColor)
+}
+
+// wedge marks a one-way wall on side d of the cell at (r, c), closed from
+// this cell, with a wedge inside the cell pointing away from the side.
+func (cv *canvas) wedge(r, c int, d maze.Direction) {
+	a, b, ok := cv.geo.Side(r, c, d)
+	if !ok {
+		return
+	}
+	centre := cv.geo.Centre(r, c)
+	m := Point{(a.X + b.X) / 2, (a.Y + b.Y) / 2}
+	// From the side a third of the way to the centre, as wide as that at
+	// its base
+	dx, dy := (centre.X-m.X)/3, (centre.Y-m.Y)/3
+	base := Point{m.X + dx/4, m.Y + dy/4}
+	cv.polygon([]Point{
+		{base.X - dy/2, base.Y + dx/2}, {m.X + dx, m.Y + dy}, {base.X + dy/2, base.Y - dx/2},
+	}, wallColor)
+}
+
+// side draws the wall on side d of the cell at (r, c).
+func (cv *canvas) side(r, c int, d maze.Direction, col color.Color) {
+	if cv.square {
+		x, y, s, w := c*cv.size, r*cv.size, cv.size, cv.wall
+		switch d {
+		case maze.Up:
+			cv.fill(x, y, x+s+w, y+w, col)
+		case maze.Down:
+			cv.fill(x, y+s, x+s+w, y+s+w, col)
+		case maze.Left:
+			cv.fill(x, y, x+w, y+s+w, col)
+		case maze.Right:
+			cv.fill(x+s, y, x+s+w, y+s+w, col)
+		}
+		return
+	}
+	a, b, ok := cv.geo.Side(r, c, d)
+	if !ok {
+		return
+	}
+	// A band half a wall wide on each side of the line, and as much longer
+	// at each end so neighbouring walls meet
+	l := math.Hypot(b.X-a.X, b.Y-a.Y)
+	h := float64(cv.wall) / 2
+	ux, uy := (b.X-a.X)/l*h, (b.Y-a.Y)/l*h
+	cv.polygon([]Point{
+		{a.X - ux - uy, a.Y - uy + ux}, {b.X + ux - uy, b.Y + uy + ux},
+		{b.X + ux + uy, b.Y + uy - ux}, {a.X - ux + uy, a.Y - uy - ux},
+	}, col)
+}
@@ -0,0 +1,80 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"maze-game/game"
+	"maze-game/maze"
+)
+
+// WriteKnowledge draws a player's own map of the maze. Cells never visited are
+// blank, walls not yet found are dotted, and ! marks where the dragon saw the
+// player. Other cells of the maze are left out, so the map gives nothing away.
+func WriteKnowledge(w io.Writer, k *game.Knowledge, p *game.Player) {
+	sighted := map[[2]int]bool{}
+	for _, s := range k.Sightings {
+		if s.Kind == game.EventDragonSeen {
+			sighted[[2]int{s.Row, s.Col}] = true
+		}
+	}
+
+	cellText := func(r, c int) string {
+		cell := k.Cells[r][c]
+		content := "   "
+		switch {
+		case p.Row == r && p.Col == c:
+			content = fmt.Sprintf("%-3.3s", strings.ToUpper(p.ID))
+		case cell.Identified:
+			content = fmt.Sprintf(" %s ", cellSymbol(maze.Cell{Type: cell.Type, RiverDir: maze.None}))
+		case k.MaybeDragon(r, c):
+			content = " ? "
+		}
+		if sighted[[2]int{r, c}] && content[0] == ' ' {
+			content = "!" + content[1:]
+		}
+		return content
+	}
+	wall := func(r, c int, d maze.Direction) byte {
+		switch k.Wall(r, c, d) {
+		case game.WallClosed:
+			return markClosed
+		case game.WallOpen:
+			return markOpen
+		}
+		return markUnknown
+	}
+
+	writeGrid(w, textGrid{rows: k.Rows, cols: k.Cols, floors: k.Floors, topology: k.Topology, cell: cellText, wall: wall})
+	fmt.Fprintln(w, "? could be the dragon, ! the dragon saw you here")
+}
+
+// WriteTimeline prints the tree of a game's states. Each line is a run of
+// moves without branches, as ID:COMMAND, and the branches leaving its last
+// move follow indented. * marks the current state.
+func WriteTimeline(w io.Writer, t *game.Timeline) {
+	writeBranch(w, t, t.Root(), "")
+}
+
+func writeBranch(w io.Writer, t *game.Timeline, n *game.TimelineNode, indent string) {
+	var labels []string
+	for {
+		label := fmt.Sprintf("%d:%s", n.ID, n.Command)
+		if n.Parent == nil {
+			label = fmt.Sprintf("%d:start", n.ID)
+		}
+		if n == t.Current() {
+			label = "*" + label
+		}
+		labels = append(labels, label)
+		if len(n.Children) != 1 {
+			break
+		}
+		n = n.Children[0]
+	}
+	fmt.Fprintln(w, indent+strings.Join(labels, " "))
+	for _, child := range n.Children {
+		writeBranch(w, t, child, indent+"  ")
+	}
+}